@@ -5,11 +5,13 @@ go 1.18
 require (
 	github.com/gorilla/websocket v1.5.1
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/crypto v0.24.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
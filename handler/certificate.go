@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lunnik9/rdp/rdp"
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// trustStore keeps server certificates accepted by browser users, RDP_KNOWN_HOSTS overrides the path.
+var trustStore = rdp.NewFileTrustStore(knownHostsPath())
+
+func knownHostsPath() string {
+	if path := os.Getenv("RDP_KNOWN_HOSTS"); path != "" {
+		return path
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "known_hosts"
+	}
+
+	return filepath.Join(configDir, "rdp-html5", "known_hosts")
+}
+
+type certificatePrompt struct {
+	Type        string `json:"type"`
+	Host        string `json:"host"`
+	Subject     string `json:"subject"`
+	Issuer      string `json:"issuer"`
+	NotAfter    string `json:"notAfter"`
+	Fingerprint string `json:"fingerprint"`
+	Error       string `json:"error"`
+	Changed     bool   `json:"changed"`
+}
+
+type certificateAnswer struct {
+	Accept bool `json:"accept"`
+}
+
+func newCertificatePolicy(wsConn *websocket.Conn, answers <-chan []byte) rdp.CertificatePolicy {
+	return rdp.CertificatePolicy{
+		VerifyChain:    true,
+		VerifyHostname: true,
+		TrustStore:     trustStore,
+		Prompt: func(host string, chain []*x509.Certificate, err error) bool {
+			accept, promptErr := promptCertificate(wsConn, answers, host, chain, err)
+			if promptErr != nil {
+				log.Println(fmt.Errorf("certificate prompt: %w", promptErr))
+
+				return false
+			}
+
+			return accept
+		},
+	}
+}
+
+// promptCertificate asks the browser user to accept the server certificate which failed verification.
+func promptCertificate(
+	wsConn *websocket.Conn,
+	answers <-chan []byte,
+	host string,
+	chain []*x509.Certificate,
+	verifyErr error,
+) (bool, error) {
+	prompt := certificatePrompt{
+		Type:        "certificate",
+		Host:        host,
+		Subject:     chain[0].Subject.String(),
+		Issuer:      chain[0].Issuer.String(),
+		NotAfter:    chain[0].NotAfter.String(),
+		Fingerprint: rdp.CertificateFingerprint(chain[0]),
+		Error:       verifyErr.Error(),
+		Changed:     errors.Is(verifyErr, rdp.ErrCertificateChanged),
+	}
+
+	if err := wsConn.WriteJSON(prompt); err != nil {
+		return false, err
+	}
+
+	data, ok := <-answers
+	if !ok {
+		return false, errWebSocketClosed
+	}
+
+	var answer certificateAnswer
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return false, err
+	}
+
+	return answer.Accept, nil
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,7 +12,6 @@ import (
 
 	"github.com/lunnik9/rdp/rdp"
 	"github.com/lunnik9/rdp/rdp/fastpath"
-	"github.com/lunnik9/rdp/rdp/pdu"
 )
 
 const (
@@ -21,8 +19,15 @@ const (
 	webSocketWriteBufferSize = 8192 * 2
 )
 
+// desktopSize tells the browser the desktop size after the server reactivated the session.
+type desktopSize struct {
+	Type   string `json:"type"`
+	Width  uint16 `json:"width"`
+	Height uint16 `json:"height"`
+}
+
 type rdpConn interface {
-	GetUpdate() (*fastpath.UpdatePDU, error)
+	GetUpdates() ([]fastpath.Update, error)
 	SendInputEvent(data []byte) error
 }
 
@@ -72,7 +77,69 @@ func Connect(w http.ResponseWriter, r *http.Request) {
 	user := r.URL.Query().Get("user")
 	password := r.URL.Query().Get("password")
 
-	rdpClient, err := rdp.NewClient(host, user, password, width, height)
+	dialer, err := newDialer(r.URL.Query(), host, user, password)
+	if err != nil {
+		log.Println(fmt.Errorf("rdp dialer: %w", err))
+
+		return
+	}
+
+	config := rdp.Config{
+		Hostname:      host,
+		Domain:        r.URL.Query().Get("domain"),
+		Username:      user,
+		Password:      password,
+		DesktopWidth:  width,
+		DesktopHeight: height,
+	}
+
+	if r.URL.Query().Has("colorDepth") {
+		if config.ColorDepth, err = strconv.Atoi(r.URL.Query().Get("colorDepth")); err != nil {
+			log.Println(fmt.Errorf("get color depth: %w", err))
+
+			return
+		}
+	}
+
+	config.ConsoleSession = r.URL.Query().Get("console") == "true"
+
+	if r.URL.Query().Has("sessionId") {
+		sessionID, err := strconv.ParseUint(r.URL.Query().Get("sessionId"), 10, 32)
+		if err != nil {
+			log.Println(fmt.Errorf("get session id: %w", err))
+
+			return
+		}
+
+		config.SessionID = uint32(sessionID)
+	}
+
+	reader := newWsReader()
+
+	opts := []rdp.Option{
+		rdp.WithDialer(dialer),
+		rdp.WithCertificatePolicy(newCertificatePolicy(wsConn, reader.answers)),
+		rdp.WithLicenseStore(licenseStore),
+		rdp.WithDesktopSizeHandler(func(width, height uint16) { // called from rdpToWs, the only writer
+			if err := wsConn.WriteJSON(desktopSize{Type: "desktopSize", Width: width, Height: height}); err != nil {
+				log.Println(fmt.Errorf("failed sending desktop size to ws: %w", err))
+			}
+		}),
+	}
+
+	if r.URL.Query().Has("cookie") {
+		opts = append(opts, rdp.WithCookie(r.URL.Query().Get("cookie")))
+	}
+
+	if routingToken := r.URL.Query().Get("routingToken"); routingToken != "" {
+		opts = append(opts, rdp.WithRoutingToken([]byte(routingToken)))
+	}
+
+	// TODO: implement
+	//opts = append(opts, rdp.WithRemoteApp("C:\\agent\\agent.exe", ".\\Downloads\\cbct1.zip", "C:\\Users\\Doc"))
+	//opts = append(opts, rdp.WithRemoteApp("explore", "", ""))
+
+	rdpClient, err := rdp.New(config, opts...)
 	if err != nil {
 		log.Println(fmt.Errorf("rdp init: %w", err))
 
@@ -80,11 +147,9 @@ func Connect(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rdpClient.Close()
 
-	// TODO: implement
-	//rdpClient.SetRemoteApp("C:\\agent\\agent.exe", ".\\Downloads\\cbct1.zip", "C:\\Users\\Doc")
-	//rdpClient.SetRemoteApp("explore", "", "")
+	go reader.run(ctx, wsConn, cancel)
 
-	if err = rdpClient.Connect(); err != nil {
+	if err = rdpClient.Connect(ctx); err != nil {
 		log.Println(fmt.Errorf("rdp connect: %w", err))
 
 		return
@@ -92,35 +157,88 @@ func Connect(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("begin proxying")
 
-	go wsToRdp(ctx, wsConn, rdpClient, cancel)
+	close(reader.connected)
+
+	go wsToRdp(ctx, reader.inputs, rdpClient, cancel)
 	rdpToWs(ctx, rdpClient, wsConn)
 }
 
-func wsToRdp(ctx context.Context, wsConn *websocket.Conn, rdpConn rdpConn, cancel context.CancelFunc) {
+// wsReader is the only reader of the websocket: text messages answer the prompts, binary messages are
+// input events, which are dropped until the RDP connection is established.
+type wsReader struct {
+	answers   chan []byte
+	inputs    chan []byte
+	connected chan struct{}
+}
+
+func newWsReader() *wsReader {
+	return &wsReader{
+		answers:   make(chan []byte),
+		inputs:    make(chan []byte),
+		connected: make(chan struct{}),
+	}
+}
+
+// run reads the websocket until it is closed, which cancels the RDP connection even while it is established.
+func (r *wsReader) run(ctx context.Context, wsConn *websocket.Conn, cancel context.CancelFunc) {
 	defer func() {
-		log.Println("wsToRdp done")
+		close(r.answers)
+		close(r.inputs)
 		cancel()
 	}()
 
 	for {
+		messageType, data, err := wsConn.ReadMessage()
+		if err != nil {
+			if !strings.HasSuffix(err.Error(), "use of closed network connection") {
+				log.Println(fmt.Errorf("error reading message from ws: %w", err))
+			}
+
+			return
+		}
+
+		messages := r.answers
+
+		if messageType == websocket.BinaryMessage {
+			select {
+			case <-r.connected:
+				messages = r.inputs
+			default: // input events sent before the connection
+				continue
+			}
+		}
+
 		select {
+		case messages <- data:
 		case <-ctx.Done():
 			return
-		default: // pass
 		}
+	}
+}
 
-		_, data, err := wsConn.ReadMessage()
-		if err != nil {
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
-				return
-			}
+func wsToRdp(ctx context.Context, inputs <-chan []byte, rdpConn rdpConn, cancel context.CancelFunc) {
+	defer func() {
+		log.Println("wsToRdp done")
+		cancel()
+	}()
 
-			log.Println(fmt.Errorf("error reading message from ws: %w", err))
+	for {
+		var (
+			data []byte
+			ok   bool
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok = <-inputs:
+		}
 
+		if !ok { // websocket closed
 			return
 		}
 
-		if err = rdpConn.SendInputEvent(data); err != nil {
+		if err := rdpConn.SendInputEvent(data); err != nil {
 			log.Println(fmt.Errorf("failed writing to rdp: %w", err))
 
 			return
@@ -134,8 +252,8 @@ func rdpToWs(ctx context.Context, rdpConn rdpConn, wsConn *websocket.Conn) {
 	}()
 
 	var (
-		update *fastpath.UpdatePDU
-		err    error
+		updates []fastpath.Update
+		err     error
 	)
 
 	for {
@@ -145,30 +263,26 @@ func rdpToWs(ctx context.Context, rdpConn rdpConn, wsConn *websocket.Conn) {
 		default: // pass
 		}
 
-		update, err = rdpConn.GetUpdate()
-		switch {
-		case err == nil: // pass
-		case errors.Is(err, pdu.ErrDeactiateAll):
-			log.Println("deactivate all")
+		updates, err = rdpConn.GetUpdates()
+		if err != nil {
+			log.Println(fmt.Errorf("get updates: %w", err))
 
 			return
+		}
 
-		default:
-			log.Println(fmt.Errorf("get update: %w", err))
+		for i := range updates {
+			// the browser parses one update per message
+			if err = wsConn.WriteMessage(websocket.BinaryMessage, updates[i].Serialize()); err != nil {
+				if err == websocket.ErrCloseSent {
+					log.Println("sent to closed websocket")
 
-			return
-		}
+					return
+				}
 
-		if err = wsConn.WriteMessage(websocket.BinaryMessage, update.Data); err != nil {
-			if err == websocket.ErrCloseSent {
-				log.Println("sent to closed websocket")
+				log.Println(fmt.Errorf("failed sending message to ws: %w", err))
 
 				return
 			}
-
-			log.Println(fmt.Errorf("failed sending message to ws: %w", err))
-
-			return
 		}
 	}
 }
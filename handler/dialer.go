@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/lunnik9/rdp/rdp"
+	"github.com/lunnik9/rdp/rdp/gateway"
+	"github.com/lunnik9/rdp/rdp/jumphost"
+	"github.com/lunnik9/rdp/rdp/proxy"
+)
+
+// newDialer returns the dialer of the connection profile in the query: the RD Gateway, the SSH jump host
+// and the proxy are chained in this order, the first hop is reached through the proxy.
+func newDialer(query url.Values, host, user, password string) (rdp.Dialer, error) {
+	gatewayConfig := gatewayConfig(query, user, password)
+
+	next := host
+	if gatewayConfig != nil {
+		next = gatewayConfig.Address
+	}
+
+	if query.Get("jumpHost") != "" {
+		next = query.Get("jumpHost")
+	}
+
+	proxyURL, err := proxyURL(query, next)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+
+	var dialer rdp.Dialer = proxy.NewDialer(proxyURL, nil)
+
+	config, err := jumpHostConfig(query, dialer)
+	if err != nil {
+		return nil, fmt.Errorf("jump host: %w", err)
+	}
+
+	if config != nil {
+		dialer = jumphost.NewDialer(*config)
+	}
+
+	if gatewayConfig != nil {
+		gatewayConfig.Forward = dialer
+		dialer = gateway.NewDialer(*gatewayConfig)
+	}
+
+	return dialer, nil
+}
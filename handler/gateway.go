@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/url"
+
+	"github.com/lunnik9/rdp/rdp/gateway"
+)
+
+// gatewayConfig returns the RD Gateway of the query or nil, the gateway credentials
+// are the RDP ones unless gatewayUser is set.
+func gatewayConfig(query url.Values, user, password string) *gateway.Config {
+	address := query.Get("gateway")
+	if address == "" {
+		return nil
+	}
+
+	config := gateway.Config{
+		Address:  address,
+		Username: user,
+		Password: password,
+	}
+
+	if query.Has("gatewayUser") {
+		config.Username = query.Get("gatewayUser")
+		config.Password = query.Get("gatewayPassword")
+	}
+
+	switch query.Get("gatewayTransport") {
+	case "http":
+		config.Transport = gateway.TransportHTTP
+	case "websocket":
+		config.Transport = gateway.TransportWebSocket
+	}
+
+	return &config
+}
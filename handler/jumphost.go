@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lunnik9/rdp/rdp/jumphost"
+)
+
+const defaultSSHPort = "22"
+
+// jumpHostConfig returns the SSH jump host of the query or nil. The password comes from the query,
+// the host keys from RDP_SSH_KNOWN_HOSTS, ~/.ssh/known_hosts by default. The private key from the file
+// of RDP_SSH_KEY is the server identity, it signs in only to the jump hosts the operator lists
+// in RDP_SSH_KEY_HOSTS as comma-separated user@host[:port] entries.
+func jumpHostConfig(query url.Values, forward jumphost.Forward) (*jumphost.Config, error) {
+	address := query.Get("jumpHost")
+	if address == "" {
+		return nil, nil
+	}
+
+	config := jumphost.Config{
+		Address:         address,
+		Username:        query.Get("jumpUser"),
+		Password:        query.Get("jumpPassword"),
+		KnownHostsFiles: []string{knownHostsFile()},
+		Forward:         forward,
+	}
+
+	keyFile := os.Getenv("RDP_SSH_KEY")
+	if keyFile == "" || !isKeyJumpHost(config.Username, config.Address) {
+		return &config, nil
+	}
+
+	privateKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.PrivateKey = privateKey
+	config.Passphrase = os.Getenv("RDP_SSH_KEY_PASSPHRASE")
+
+	return &config, nil
+}
+
+// isKeyJumpHost reports whether the user at the jump host is listed in RDP_SSH_KEY_HOSTS.
+func isKeyJumpHost(user, address string) bool {
+	target := user + "@" + withSSHPort(address)
+
+	for _, entry := range strings.Split(os.Getenv("RDP_SSH_KEY_HOSTS"), ",") {
+		entry = strings.TrimSpace(entry)
+
+		i := strings.LastIndexByte(entry, '@')
+		if i <= 0 {
+			continue
+		}
+
+		if entry[:i]+"@"+withSSHPort(entry[i+1:]) == target {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withSSHPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return strings.ToLower(address)
+	}
+
+	return strings.ToLower(net.JoinHostPort(address, defaultSSHPort))
+}
+
+func knownHostsFile() string {
+	if file := os.Getenv("RDP_SSH_KNOWN_HOSTS"); file != "" {
+		return file
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "known_hosts"
+	}
+
+	return filepath.Join(homeDir, ".ssh", "known_hosts")
+}
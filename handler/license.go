@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/lunnik9/rdp/rdp"
+)
+
+// licenseStore keeps client access licenses issued by license servers, RDP_LICENSE_DIR overrides the directory.
+var licenseStore = rdp.NewFileLicenseStore(licenseDir())
+
+func licenseDir() string {
+	if dir := os.Getenv("RDP_LICENSE_DIR"); dir != "" {
+		return dir
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "licenses"
+	}
+
+	return filepath.Join(configDir, "rdp-html5", "licenses")
+}
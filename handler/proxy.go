@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"log"
+	"net/url"
+
+	"github.com/lunnik9/rdp/rdp/proxy"
+)
+
+// proxyRules of ALL_PROXY and NO_PROXY choose the proxy of the target host.
+var proxyRules = loadProxyRules()
+
+func loadProxyRules() *proxy.Rules {
+	rules, err := proxy.FromEnvironment()
+	if err != nil {
+		log.Println(err)
+
+		return &proxy.Rules{}
+	}
+
+	return rules
+}
+
+// proxyURL returns the proxy of the host or nil to connect directly, the proxy query parameter
+// overrides the environment and empty proxy disables it.
+func proxyURL(query url.Values, host string) (*url.URL, error) {
+	if !query.Has("proxy") {
+		return proxyRules.ProxyFor(host), nil
+	}
+
+	if query.Get("proxy") == "" {
+		return nil, nil
+	}
+
+	return proxy.ParseURL(query.Get("proxy"))
+}
@@ -0,0 +1,118 @@
+package rdp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+const (
+	autoReconnectAttempts   = 5
+	autoReconnectBackoff    = time.Second
+	autoReconnectMaxBackoff = 16 * time.Second
+)
+
+// handleSaveSessionInfo keeps the auto-reconnect cookie of the logged on session.
+func (c *Client) handleSaveSessionInfo(info *pdu.SaveSessionInfoPDUData) {
+	if info.LogonInfoExtended == nil || info.LogonInfoExtended.AutoReconnect == nil {
+		return
+	}
+
+	log.Println("RDP: Save Session Info: auto-reconnect cookie, logonId =", info.LogonInfoExtended.AutoReconnect.LogonID)
+
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	c.autoReconnectCookie = info.LogonInfoExtended.AutoReconnect
+}
+
+// canAutoReconnect reports whether the session can be resumed after err.
+func (c *Client) canAutoReconnect(err error) bool {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	return c.autoReconnectCookie != nil && !c.closed && isTransportError(err)
+}
+
+// autoReconnect connects to the server again with backoff and resumes the session with the auto-reconnect cookie.
+func (c *Client) autoReconnect() error {
+	_ = c.conn.Close() // unblocks SendInputEvent
+
+	c.setReconnecting(true)
+	defer c.setReconnecting(false)
+
+	var (
+		backoff = autoReconnectBackoff
+		err     error
+	)
+
+	for attempt := 1; attempt <= autoReconnectAttempts; attempt++ {
+		time.Sleep(backoff)
+
+		if c.isClosed() {
+			return ErrClosed
+		}
+
+		log.Printf("RDP: auto-reconnect attempt %d\n", attempt)
+
+		err = c.reconnect()
+
+		if c.isClosed() { // Close raced with the attempt
+			if err == nil {
+				_ = c.closeConn()
+			}
+
+			return ErrClosed
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		log.Println(fmt.Errorf("auto-reconnect: %w", err))
+
+		if backoff *= 2; backoff > autoReconnectMaxBackoff {
+			backoff = autoReconnectMaxBackoff
+		}
+	}
+
+	return err
+}
+
+func (c *Client) reconnect() error {
+	c.resetSession()
+
+	return c.Connect(context.Background())
+}
+
+func (c *Client) isClosed() bool {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	return c.closed
+}
+
+func (c *Client) setReconnecting(reconnecting bool) {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	c.reconnecting = reconnecting
+}
+
+// isTransportError reports whether err is caused by the lost connection rather than by the protocol
+// or by Close.
+func isTransportError(err error) bool {
+	if errors.Is(err, net.ErrClosed) {
+		return false
+	}
+
+	var netErr net.Error
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
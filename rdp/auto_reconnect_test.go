@@ -0,0 +1,94 @@
+package rdp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+func Test_isTransportError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"EOF", fmt.Errorf("get X.224 update: %w", io.EOF), true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"closed", &net.OpError{Op: "read", Net: "tcp", Err: net.ErrClosed}, false},
+		{"deactivate all", pdu.ErrDeactiateAll, false},
+		{"protocol", errors.New("unknown data pdu: 1"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, isTransportError(tc.err))
+		})
+	}
+}
+
+func TestClient_canAutoReconnect(t *testing.T) {
+	var c Client
+
+	require.False(t, c.canAutoReconnect(io.EOF))
+
+	c.handleSaveSessionInfo(&pdu.SaveSessionInfoPDUData{
+		InfoType:          pdu.InfoTypeLogonExtendedInfo,
+		LogonInfoExtended: &pdu.LogonInfoExtended{AutoReconnect: &pdu.ServerAutoReconnect{LogonID: 2}},
+	})
+
+	require.True(t, c.canAutoReconnect(io.EOF))
+	require.False(t, c.canAutoReconnect(pdu.ErrDeactiateAll))
+
+	c.closed = true
+
+	require.False(t, c.canAutoReconnect(io.EOF))
+}
+
+func TestClient_SendInputEventReconnecting(t *testing.T) {
+	var c Client
+
+	c.setReconnecting(true)
+
+	require.NoError(t, c.SendInputEvent([]byte{0x01}))
+}
+
+type countingDialer struct {
+	dials int32
+}
+
+func (d *countingDialer) Dial(network, address string) (net.Conn, error) {
+	atomic.AddInt32(&d.dials, 1)
+
+	return nil, errors.New("dial refused")
+}
+
+func TestClient_autoReconnectClosed(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	dialer := &countingDialer{}
+	c := Client{dialer: dialer}
+	c.setConn(clientConn)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.autoReconnect()
+	}()
+
+	time.Sleep(autoReconnectBackoff / 4) // within the first backoff
+
+	require.NoError(t, c.Close())
+	require.ErrorIs(t, <-done, ErrClosed)
+	require.Zero(t, atomic.LoadInt32(&dialer.dials))
+}
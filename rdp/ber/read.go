@@ -33,9 +33,9 @@ func ReadApplicationTag(r io.Reader) (uint8, error) {
 	return tag, nil
 }
 
-func ReadLength(r io.Reader) (uint16, error) {
+func ReadLength(r io.Reader) (int, error) {
 	var (
-		ret  uint16
+		ret  int
 		size uint8
 		err  error
 	)
@@ -45,26 +45,24 @@ func ReadLength(r io.Reader) (uint16, error) {
 		return 0, err
 	}
 
-	if size&0x80 > 0 {
-		size = size &^ 0x80
+	if size&0x80 == 0 {
+		return int(size), nil
+	}
+
+	size = size &^ 0x80
+
+	if size == 0 || size > 4 {
+		return 0, errors.New("BER length may be 1 to 4 bytes")
+	}
 
-		if size == 1 {
-			err = binary.Read(r, binary.BigEndian, &size)
-			if err != nil {
-				return 0, err
-			}
+	lengthBytes := make([]byte, size)
+
+	if _, err = io.ReadFull(r, lengthBytes); err != nil {
+		return 0, err
+	}
 
-			ret = uint16(size)
-		} else if size == 2 {
-			err = binary.Read(r, binary.BigEndian, &ret)
-			if err != nil {
-				return 0, err
-			}
-		} else {
-			return 0, errors.New("BER length may be 1 or 2")
-		}
-	} else {
-		ret = uint16(size)
+	for _, b := range lengthBytes {
+		ret = ret<<8 | int(b)
 	}
 
 	return ret, nil
@@ -109,7 +107,9 @@ func ReadEnumerated(r io.Reader) (uint8, error) {
 
 	var enumerated uint8
 
-	return enumerated, binary.Read(r, binary.BigEndian, &enumerated)
+	err = binary.Read(r, binary.BigEndian, &enumerated)
+
+	return enumerated, err
 }
 
 func ReadInteger(r io.Reader) (int, error) {
@@ -127,37 +127,81 @@ func ReadInteger(r io.Reader) (int, error) {
 		return 0, err
 	}
 
-	switch size {
-	case 1:
-		var num uint8
+	if size == 0 || size > 8 {
+		return 0, errors.New("wrong size")
+	}
 
-		return int(num), binary.Read(r, binary.BigEndian, &num)
-	case 2:
-		var num uint16
+	data := make([]byte, size)
 
-		return int(num), binary.Read(r, binary.BigEndian, &num)
-	case 3:
-		var (
-			int1 uint8
-			int2 uint16
-		)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return 0, err
+	}
 
-		err = binary.Read(r, binary.BigEndian, &int1)
-		if err != nil {
-			return 0, err
-		}
+	var num int
 
-		err = binary.Read(r, binary.BigEndian, &int2)
-		if err != nil {
-			return 0, err
-		}
+	for _, b := range data {
+		num = num<<8 | int(b)
+	}
 
-		return int(int1)<<0x10 + int(int2), nil
-	case 4:
-		var num uint32
+	return num, nil
+}
 
-		return int(num), binary.Read(r, binary.BigEndian, &num)
-	default:
-		return 0, errors.New("wrong size")
+func ReadContextTag(r io.Reader) (uint8, error) {
+	var identifier uint8
+
+	err := binary.Read(r, binary.BigEndian, &identifier)
+	if err != nil {
+		return 0, err
+	}
+
+	if identifier&(asn1.ClassMask|asn1.PCMask) != asn1.ClassContextSpecific|asn1.PCConstruct {
+		return 0, errors.New("ReadContextTag invalid data")
 	}
+
+	return identifier & asn1.TagMask, nil
+}
+
+func ReadSequence(r io.Reader) (int, error) {
+	universalTag, err := ReadUniversalTag(asn1.TagSequence, true, r)
+	if err != nil {
+		return 0, err
+	}
+
+	if !universalTag {
+		return 0, errors.New("bad sequence tag")
+	}
+
+	return ReadLength(r)
+}
+
+func ReadOctetString(r io.Reader) ([]byte, error) {
+	return readPrimitive(asn1.TagOctetString, r)
+}
+
+func ReadObjectIdentifier(r io.Reader) ([]byte, error) {
+	return readPrimitive(asn1.TagObjectIdenfier, r)
+}
+
+func readPrimitive(tag uint8, r io.Reader) ([]byte, error) {
+	universalTag, err := ReadUniversalTag(tag, false, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !universalTag {
+		return nil, fmt.Errorf("bad tag, expect %d", tag)
+	}
+
+	length, err := ReadLength(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
 }
@@ -55,10 +55,31 @@ func WriteApplicationTag(tag uint8, size int, w io.Writer) {
 }
 
 func WriteLength(size int, w io.Writer) {
-	if size > 0x7f {
+	if size > 0xffff {
+		w.Write([]byte{0x84})
+		binary.Write(w, binary.BigEndian, uint32(size))
+	} else if size > 0x7f {
 		w.Write([]byte{0x82})
 		binary.Write(w, binary.BigEndian, uint16(size))
 	} else {
 		w.Write([]byte{uint8(size)})
 	}
 }
+
+func WriteEnumerated(n uint8, w io.Writer) {
+	w.Write([]byte{0x0a}) // tag enumerated
+	WriteLength(1, w)
+	w.Write([]byte{n})
+}
+
+func WriteObjectIdentifier(oid []byte, w io.Writer) {
+	w.Write([]byte{0x06}) // tag object identifier
+	WriteLength(len(oid), w)
+	w.Write(oid)
+}
+
+func WriteContextTag(tag uint8, data []byte, w io.Writer) {
+	w.Write([]byte{0xa0 | tag}) // context-specific, constructed
+	WriteLength(len(data), w)
+	w.Write(data)
+}
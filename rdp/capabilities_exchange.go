@@ -1,22 +1,82 @@
 package rdp
 
-import "github.com/lunnik9/rdp/rdp/pdu"
+import (
+	"errors"
+	"io"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+	"github.com/lunnik9/rdp/rdp/sec"
+)
+
+func (c *Client) capabilitiesExchange() error {
+	var (
+		flags sec.Flag
+		wire  io.Reader
+		err   error
+	)
+
+	if c.secLayer.Enabled() {
+		_, flags, wire, err = c.secLayer.ReceiveWithFlags()
+	} else {
+		_, wire, err = c.secLayer.Receive()
+	}
 
-func (c *client) capabilitiesExchange() error {
-	_, wire, err := c.mcsLayer.Receive()
 	if err != nil {
 		return err
 	}
 
+	if flags.IsSet(sec.FlagRedirectionPkt) { // Standard Security Server Redirection PDU
+		var redirection pdu.ServerRedirectionPacket
+		if err = redirection.Deserialize(wire); err != nil {
+			return err
+		}
+
+		if err = c.handleServerRedirection(&redirection); err != nil {
+			return err
+		}
+
+		return c.capabilitiesExchange() // the Demand Active follows LB_NOREDIRECT
+	}
+
 	var resp pdu.ServerDemandActive
-	if err = resp.Deserialize(wire); err != nil {
+
+	err = resp.Deserialize(wire)
+	if errors.Is(err, pdu.ErrServerRedirection) {
+		var redirection pdu.EnhancedSecurityServerRedirection
+		if err = redirection.Deserialize(wire); err != nil {
+			return err
+		}
+
+		if err = c.handleServerRedirection(&redirection.RedirectionPacket); err != nil {
+			return err
+		}
+
+		return c.capabilitiesExchange()
+	}
+
+	if err != nil {
 		return err
 	}
 
 	c.shareID = resp.ShareID
 	c.serverCapabilitySets = resp.CapabilitySets
 
-	req := pdu.NewClientConfirmActive(resp.ShareID, c.userID, c.desktopWidth, c.desktopHeight, c.remoteApp != nil)
+	for _, set := range resp.CapabilitySets {
+		if set.BitmapCapabilitySet != nil { // the desktop size chosen by the server
+			c.desktopWidth = set.BitmapCapabilitySet.DesktopWidth
+			c.desktopHeight = set.BitmapCapabilitySet.DesktopHeight
+		}
+	}
+
+	if cipher := c.secLayer.Cipher(); cipher != nil {
+		for _, set := range resp.CapabilitySets {
+			if set.GeneralCapabilitySet != nil {
+				cipher.SetSaltedChecksum(set.GeneralCapabilitySet.ExtraFlags&0x0010 == 0x0010) // ENC_SALTED_CHECKSUM
+			}
+		}
+	}
+
+	req := pdu.NewClientConfirmActive(resp.ShareID, c.userID, c.clientSettings(), c.remoteApp != nil)
 
-	return c.mcsLayer.Send(c.userID, c.channelIDMap["global"], req.Serialize())
+	return c.secLayer.Send(c.userID, c.channelIDMap["global"], req.Serialize())
 }
@@ -0,0 +1,165 @@
+package rdp
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// CertificatePolicy configures verification of the server TLS certificate.
+// The zero value accepts any certificate.
+type CertificatePolicy struct {
+	// VerifyChain verifies the certificate chain against RootCAs or the system pool when RootCAs is nil.
+	VerifyChain bool
+	RootCAs     *x509.CertPool
+
+	// VerifyHostname checks the server certificate is valid for ServerName or the dialed host when empty.
+	VerifyHostname bool
+	ServerName     string
+
+	// Fingerprints pins hex-encoded SHA-256 fingerprints of the server certificate,
+	// any other certificate is rejected.
+	Fingerprints []string
+
+	// TrustStore remembers certificates trusted on first use.
+	TrustStore TrustStore
+
+	// Prompt asks the user to accept the certificate chain which failed verification with err,
+	// host is the key of the server in the trust store.
+	Prompt func(host string, chain []*x509.Certificate, err error) bool
+}
+
+func (p *CertificatePolicy) isZero() bool {
+	return !p.VerifyChain && !p.VerifyHostname && len(p.Fingerprints) == 0 && p.TrustStore == nil && p.Prompt == nil
+}
+
+// verify applies the policy to the certificate chain presented by the server at host[:port].
+func (p *CertificatePolicy) verify(address string, chain []*x509.Certificate) error {
+	if p.isZero() {
+		return nil
+	}
+
+	if len(chain) == 0 {
+		return ErrCertificateNotTrusted
+	}
+
+	fingerprint := CertificateFingerprint(chain[0])
+
+	if len(p.Fingerprints) != 0 {
+		for _, pin := range p.Fingerprints {
+			if normalizeFingerprint(pin) == fingerprint {
+				return nil
+			}
+		}
+
+		return ErrCertificatePinMismatch
+	}
+
+	err := p.verifyPKI(hostOnly(address), chain)
+	if err == nil {
+		return nil
+	}
+
+	host := serverKey(address)
+
+	if p.TrustStore != nil {
+		known, storeErr := p.TrustStore.Fingerprint(host)
+		if storeErr != nil {
+			return fmt.Errorf("trust store: %w", storeErr)
+		}
+
+		switch {
+		case known == fingerprint:
+			return nil
+		case known != "":
+			err = ErrCertificateChanged
+		case p.Prompt == nil: // trust on first use
+			return p.TrustStore.Trust(host, fingerprint)
+		}
+	}
+
+	if p.Prompt == nil || !p.Prompt(host, chain, err) {
+		return err
+	}
+
+	if p.TrustStore != nil {
+		return p.TrustStore.Trust(host, fingerprint)
+	}
+
+	return nil
+}
+
+func (p *CertificatePolicy) verifyPKI(host string, chain []*x509.Certificate) error {
+	if !p.VerifyChain && !p.VerifyHostname {
+		return ErrCertificateNotTrusted
+	}
+
+	if p.VerifyChain {
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := chain[0].Verify(x509.VerifyOptions{
+			Roots:         p.RootCAs,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrCertificateNotTrusted, err)
+		}
+	}
+
+	if p.VerifyHostname {
+		serverName := p.ServerName
+		if serverName == "" {
+			serverName = host
+		}
+
+		if err := chain[0].VerifyHostname(serverName); err != nil {
+			return fmt.Errorf("%w: %s", ErrCertificateNotTrusted, err)
+		}
+	}
+
+	return nil
+}
+
+// CertificateFingerprint returns the hex-encoded SHA-256 fingerprint of the certificate.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint accepts fingerprints in upper case and separated by colons.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
+
+// serverKey returns the host for the default port and [host]:port for the other ports, the key of the server
+// in the trust and license stores. The servers on the ports of a single host are distinct as in known_hosts of SSH.
+func serverKey(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	if port == defaultRDPPort {
+		return host
+	}
+
+	return "[" + host + "]:" + port
+}
+
+// hostOnly strips the port from the dialed address.
+func hostOnly(hostname string) string {
+	host, _, err := net.SplitHostPort(hostname)
+	if err != nil {
+		return hostname
+	}
+
+	return host
+}
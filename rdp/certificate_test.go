@@ -0,0 +1,153 @@
+package rdp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	} else {
+		template.DNSNames = []string{name}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+func TestCertificatePolicy_Verify(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "test CA", nil, nil)
+	server, _ := newTestCertificate(t, "rdp.example.com", ca, caKey)
+	chain := []*x509.Certificate{server}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	var zero CertificatePolicy
+	require.NoError(t, zero.verify("rdp.example.com", chain))
+
+	pki := CertificatePolicy{VerifyChain: true, VerifyHostname: true, RootCAs: roots}
+	require.NoError(t, pki.verify("rdp.example.com", chain))
+	require.ErrorIs(t, pki.verify("other.example.com", chain), ErrCertificateNotTrusted)
+
+	pki.RootCAs = x509.NewCertPool()
+	require.ErrorIs(t, pki.verify("rdp.example.com", chain), ErrCertificateNotTrusted)
+
+	pinned := CertificatePolicy{Fingerprints: []string{"00", CertificateFingerprint(server)}}
+	require.NoError(t, pinned.verify("rdp.example.com", chain))
+
+	pinned.Fingerprints = []string{CertificateFingerprint(ca)}
+	require.ErrorIs(t, pinned.verify("rdp.example.com", chain), ErrCertificatePinMismatch)
+}
+
+func TestCertificatePolicy_TrustOnFirstUse(t *testing.T) {
+	first, _ := newTestCertificate(t, "first", nil, nil)
+	second, _ := newTestCertificate(t, "second", nil, nil)
+
+	store := NewFileTrustStore(filepath.Join(t.TempDir(), "rdp", "known_hosts"))
+	policy := CertificatePolicy{TrustStore: store}
+
+	require.NoError(t, policy.verify("host", []*x509.Certificate{first}))
+	require.NoError(t, policy.verify("host", []*x509.Certificate{first}))
+	require.ErrorIs(t, policy.verify("host", []*x509.Certificate{second}), ErrCertificateChanged)
+
+	fingerprint, err := NewFileTrustStore(store.path).Fingerprint("host")
+	require.NoError(t, err)
+	require.Equal(t, CertificateFingerprint(first), fingerprint)
+
+	var prompted error
+
+	policy.Prompt = func(host string, chain []*x509.Certificate, err error) bool {
+		prompted = err
+
+		return true
+	}
+
+	require.NoError(t, policy.verify("host", []*x509.Certificate{second}))
+	require.ErrorIs(t, prompted, ErrCertificateChanged)
+
+	fingerprint, err = store.Fingerprint("host")
+	require.NoError(t, err)
+	require.Equal(t, CertificateFingerprint(second), fingerprint)
+
+	policy.Prompt = func(string, []*x509.Certificate, error) bool {
+		return false
+	}
+
+	require.ErrorIs(t, policy.verify("other", []*x509.Certificate{first}), ErrCertificateNotTrusted)
+}
+
+func TestCertificatePolicy_TrustStorePorts(t *testing.T) {
+	first, _ := newTestCertificate(t, "first", nil, nil)
+	second, _ := newTestCertificate(t, "second", nil, nil)
+
+	store := NewFileTrustStore(filepath.Join(t.TempDir(), "known_hosts"))
+	policy := CertificatePolicy{TrustStore: store}
+
+	require.NoError(t, policy.verify("host:3389", []*x509.Certificate{first}))
+	require.NoError(t, policy.verify("host:3390", []*x509.Certificate{second}))
+	require.NoError(t, policy.verify("host", []*x509.Certificate{first}))
+	require.ErrorIs(t, policy.verify("host:3390", []*x509.Certificate{first}), ErrCertificateChanged)
+
+	fingerprint, err := store.Fingerprint("[host]:3390")
+	require.NoError(t, err)
+	require.Equal(t, CertificateFingerprint(second), fingerprint)
+}
+
+func TestClient_StartTLS(t *testing.T) {
+	cert, key := newTestCertificate(t, "rdp.example.com", nil, nil)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	go func() {
+		tlsConn := tls.Server(serverConn, &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+		})
+
+		_ = tlsConn.Handshake()
+	}()
+
+	c := Client{
+		hostname: "rdp.example.com:3389",
+		conn:     clientConn,
+	}
+
+	WithCertificatePolicy(CertificatePolicy{Fingerprints: []string{CertificateFingerprint(cert)}})(&c)
+
+	require.NoError(t, c.StartTLS())
+	require.Len(t, c.ServerCertificates(), 1)
+	require.Equal(t, cert.Raw, c.ServerCertificates()[0].Raw)
+}
@@ -2,13 +2,17 @@ package rdp
 
 import (
 	"bufio"
+	"context"
+	"crypto/x509"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/lunnik9/rdp/rdp/fastpath"
 	"github.com/lunnik9/rdp/rdp/mcs"
 	"github.com/lunnik9/rdp/rdp/pdu"
+	"github.com/lunnik9/rdp/rdp/sec"
 	"github.com/lunnik9/rdp/rdp/tpkt"
 	"github.com/lunnik9/rdp/rdp/x224"
 )
@@ -19,66 +23,179 @@ type RemoteApp struct {
 	Args       string
 }
 
-type client struct {
-	conn       net.Conn
-	buffReader *bufio.Reader
-	tpktLayer  *tpkt.Protocol
-	x224Layer  *x224.Protocol
-	mcsLayer   *mcs.Protocol
-	fastPath   *fastpath.Protocol
+// Client of the RDP server.
+type Client struct {
+	hostname    string
+	dialer      Dialer
+	initialConn net.Conn
+	conn        net.Conn
+
+	phaseTimeout time.Duration
+	connectCtx   context.Context // of the running Connect
+	buffReader   *bufio.Reader
+	tpktLayer    *tpkt.Protocol
+	x224Layer    *x224.Protocol
+	mcsLayer     *mcs.Protocol
+	secLayer     *sec.Protocol
+	fastPath     *fastpath.Protocol
+	reassembler  *fastpath.Reassembler
 
 	domain   string
 	username string
 	password string
 
+	passwordCookie []byte // sent instead of the password after a server redirection
+	redirection    *pdu.ServerRedirectionPacket
+
+	certificatePolicy  CertificatePolicy
+	licenseStore       LicenseStore
+	serverCertificates []*x509.Certificate
+
+	settings                    pdu.ClientSettings
 	desktopWidth, desktopHeight uint16
+	desktopSizeHandler          func(width, height uint16)
 
 	serverCapabilitySets []pdu.CapabilitySet
 	remoteApp            *RemoteApp
 	railState            RailState
 
+	routingToken           []byte
+	cookie                 string
+	securityProtocols      []pdu.NegotiationProtocol // in the order of preference
+	requestedProtocols     pdu.NegotiationProtocol
+	negotiationAttempts    []NegotiationAttempt
+	negotiationFlags       pdu.NegotiationRequestFlag
 	selectedProtocol       pdu.NegotiationProtocol
 	serverNegotiationFlags pdu.NegotiationResponseFlag
+	serverSecurityData     *pdu.ServerSecurityData
+	clientRandom           []byte
 	channels               []string
 	channelIDMap           map[string]uint16
 	skipChannelJoin        bool
 	shareID                uint32
 	userID                 uint16
+
+	reconnectMu         sync.Mutex
+	autoReconnectCookie *pdu.ServerAutoReconnect
+	reconnecting        bool
+	closed              bool
 }
 
 const (
 	tcpConnectionTimeout = 5 * time.Second
+	defaultPhaseTimeout  = 15 * time.Second
 	readBufferSize       = 64 * 1024
 )
 
-func NewClient(
-	hostname, username, password string,
-	desktopWidth, desktopHeight int,
-) (*client, error) {
-	c := client{
-		domain:   "",
+// New returns the client of the config, the server is dialed by Connect.
+func New(config Config, opts ...Option) (*Client, error) {
+	settings, err := config.clientSettings()
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	domain, username := splitUsername(config.Username)
+	if config.Domain != "" {
+		domain = config.Domain
+	}
+
+	c := Client{
+		hostname: config.Hostname,
+		dialer:   &net.Dialer{Timeout: tcpConnectionTimeout},
+
+		phaseTimeout: defaultPhaseTimeout,
+
+		domain:   domain,
 		username: username,
-		password: password,
+		password: config.Password,
 
-		desktopWidth:  uint16(desktopWidth),
-		desktopHeight: uint16(desktopHeight),
+		settings:      settings,
+		desktopWidth:  settings.DesktopWidth,
+		desktopHeight: settings.DesktopHeight,
+
+		cookie:            mstshashCookie(username),
+		securityProtocols: config.securityProtocols(),
+		channels:          append([]string(nil), config.Channels...),
+	}
 
-		selectedProtocol: pdu.NegotiationProtocolSSL,
+	for _, opt := range opts {
+		opt(&c)
 	}
 
-	var err error
+	return &c, nil
+}
+
+// clientSettings returns the settings of the client PDUs with the current desktop size.
+func (c *Client) clientSettings() *pdu.ClientSettings {
+	settings := c.settings
+	settings.DesktopWidth, settings.DesktopHeight = c.desktopWidth, c.desktopHeight
+
+	return &settings
+}
+
+// dial opens the connection to hostname, the connection passed by WithConn is used once,
+// and sets up the protocol layers over it. Context dialers are canceled with ctx.
+func (c *Client) dial(ctx context.Context) error {
+	if conn := c.initialConn; conn != nil {
+		c.initialConn = nil
+		c.setConn(conn)
+
+		return nil
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if dialer, ok := c.dialer.(ContextDialer); ok {
+		if c.phaseTimeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, c.phaseTimeout)
+			defer cancel()
+		}
+
+		conn, err = dialer.DialContext(ctx, "tcp", c.hostname)
+	} else {
+		conn, err = c.dialer.Dial("tcp", c.hostname)
+	}
 
-	c.conn, err = net.DialTimeout("tcp", hostname, tcpConnectionTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("tcp connect: %w", err)
+		return fmt.Errorf("dial: %w", err)
 	}
 
+	c.setConn(conn)
+
+	return nil
+}
+
+// setConn sets up the protocol layers over the connection.
+func (c *Client) setConn(conn net.Conn) {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	c.conn = conn
 	c.buffReader = bufio.NewReaderSize(c.conn, readBufferSize)
 
-	c.tpktLayer = tpkt.New(&c)
+	c.tpktLayer = tpkt.New(c)
 	c.x224Layer = x224.New(c.tpktLayer)
 	c.mcsLayer = mcs.New(c.x224Layer)
-	c.fastPath = fastpath.New(&c)
+	c.secLayer = sec.New(c.mcsLayer)
+	c.fastPath = fastpath.New(c)
+	c.reassembler = fastpath.NewReassembler(c.settings.MaxRequestSize)
+}
 
-	return &c, nil
+// resetSession forgets the state negotiated over the previous connection before reconnecting.
+func (c *Client) resetSession() {
+	c.serverCertificates = nil
+	c.selectedProtocol = 0
+	c.serverNegotiationFlags = 0
+	c.serverSecurityData = nil
+	c.serverCapabilitySets = nil
+	c.clientRandom = nil
+	c.channelIDMap = nil
+	c.skipChannelJoin = false
+	c.shareID = 0
+	c.userID = 0
 }
@@ -1,9 +1,26 @@
 package rdp
 
-func (c *client) Close() error {
+func (c *Client) Close() error {
+	c.reconnectMu.Lock()
+	c.closed = true
+	c.reconnectMu.Unlock()
+
 	if c.remoteApp != nil {
 		c.railState = RailStateUninitialized
 	}
 
-	return c.conn.Close()
+	return c.closeConn()
+}
+
+// closeConn closes the current connection, which the auto-reconnect replaces concurrently.
+func (c *Client) closeConn() error {
+	c.reconnectMu.Lock()
+	conn := c.conn
+	c.reconnectMu.Unlock()
+
+	if conn == nil { // not connected
+		return nil
+	}
+
+	return conn.Close()
 }
@@ -0,0 +1,99 @@
+package rdp
+
+import (
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+// Config of the client, the zero values are the defaults.
+type Config struct {
+	Hostname string // host:port of the server
+	Domain   string // overrides the domain of the user name
+	Username string // user, DOMAIN\user or user@domain
+	Password string
+
+	DesktopWidth  int
+	DesktopHeight int
+	ColorDepth    int // 15, 16, 24 or 32 bits per pixel, 16 by default, 32 with Planar
+
+	// Planar advertises the RDP 6.0 Bitmap Compression (planar codec) of 32 bpp bitmaps, decoded by rdp/planar.
+	Planar bool
+
+	ClientName           string // up to 15 characters, rdp-html5 by default
+	KeyboardLayout       uint32 // pdu.KeyboardLayoutUS by default
+	KeyboardType         uint32 // pdu.KeyboardTypeIBMEnhanced by default
+	KeyboardSubType      uint32
+	KeyboardFunctionKeys uint32 // 12 by default
+	PerformanceFlags     uint32 // pdu.PerfDisableWallpaper and the other PERF_ flags
+
+	// Compression asks for the bulk compression of CompressionType, the updates are passed on
+	// compressed to the consumer of GetUpdate.
+	Compression     bool
+	CompressionType uint32
+
+	Channels []string // static virtual channels
+
+	// MaxRequestSize of the fastpath updates reassembled by GetUpdates, up to pdu.MaxRequestSizeDefault,
+	// the 16-bit size of a serialized update, and the default.
+	MaxRequestSize uint32
+
+	ConsoleSession bool   // attaches to the console session (session 0, "admin" mode)
+	SessionID      uint32 // attaches to an existing session, exclusive with ConsoleSession
+
+	// SecurityProtocols in the order of preference, HYBRID_EX, HYBRID and SSL by default. The X.224 Connection
+	// Request offers the preferred protocol together with the following weaker ones, the server selects
+	// the strongest of them, and the negotiation falls back to the rest the server requires.
+	// pdu.NegotiationProtocolRDP enables Standard RDP Security.
+	SecurityProtocols []pdu.NegotiationProtocol
+}
+
+// clientSettings returns the settings of the client PDUs, the defaults fill the zero values.
+func (config *Config) clientSettings() (pdu.ClientSettings, error) {
+	settings := *pdu.NewClientSettings(uint16(config.DesktopWidth), uint16(config.DesktopHeight))
+
+	if config.Planar {
+		settings.ColorDepth = 32
+		settings.Planar = true
+	}
+
+	if config.ColorDepth != 0 {
+		settings.ColorDepth = uint16(config.ColorDepth)
+	}
+
+	if config.ClientName != "" {
+		settings.ClientName = config.ClientName
+	}
+
+	if config.KeyboardLayout != 0 {
+		settings.KeyboardLayout = config.KeyboardLayout
+	}
+
+	if config.KeyboardType != 0 {
+		settings.KeyboardType = config.KeyboardType
+	}
+
+	if config.KeyboardFunctionKeys != 0 {
+		settings.KeyboardFunctionKey = config.KeyboardFunctionKeys
+	}
+
+	if config.MaxRequestSize != 0 {
+		settings.MaxRequestSize = config.MaxRequestSize
+	}
+
+	settings.KeyboardSubType = config.KeyboardSubType
+	settings.PerformanceFlags = config.PerformanceFlags
+	settings.Compression = config.Compression
+	settings.CompressionType = config.CompressionType
+	settings.ConsoleSession = config.ConsoleSession
+	settings.SessionID = config.SessionID
+
+	return settings, settings.Validate()
+}
+
+// securityProtocols returns the preference list of the security protocols.
+func (config *Config) securityProtocols() []pdu.NegotiationProtocol {
+	if len(config.SecurityProtocols) == 0 {
+		return []pdu.NegotiationProtocol{pdu.NegotiationProtocolHybridEx, pdu.NegotiationProtocolHybrid, pdu.NegotiationProtocolSSL}
+	}
+
+	return append([]pdu.NegotiationProtocol(nil), config.SecurityProtocols...)
+}
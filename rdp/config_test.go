@@ -0,0 +1,82 @@
+package rdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+func TestNew_Config(t *testing.T) {
+	c, err := New(testConfig)
+	require.NoError(t, err)
+	require.Equal(t, []pdu.NegotiationProtocol{pdu.NegotiationProtocolHybridEx, pdu.NegotiationProtocolHybrid, pdu.NegotiationProtocolSSL}, c.securityProtocols)
+	require.Equal(t, *pdu.NewClientSettings(1024, 768), c.settings)
+
+	config := testConfig
+	config.Domain = "EXAMPLE"
+	config.ColorDepth = 32
+	config.ClientName = "kiosk-7"
+	config.KeyboardLayout = 0x00000407 // German
+	config.PerformanceFlags = pdu.PerfDisableWallpaper | pdu.PerfDisableTheming
+	config.Channels = []string{"cliprdr"}
+	config.SecurityProtocols = []pdu.NegotiationProtocol{pdu.NegotiationProtocolRDP}
+	config.MaxRequestSize = 0x8000
+
+	c, err = New(config)
+	require.NoError(t, err)
+	require.Equal(t, "EXAMPLE", c.domain)
+	require.Equal(t, []string{"cliprdr"}, c.channels)
+	require.Equal(t, []pdu.NegotiationProtocol{pdu.NegotiationProtocolRDP}, c.securityProtocols)
+
+	userDataSet := pdu.NewClientUserDataSet(0, c.clientSettings(), c.channels)
+	require.Equal(t, uint32(0x00000407), userDataSet.ClientCoreData.KeyboardLayout)
+	require.Equal(t, pdu.ECFWant32BPPSession, userDataSet.ClientCoreData.EarlyCapabilityFlags&pdu.ECFWant32BPPSession)
+	require.Equal(t, []byte{'k', 0, 'i', 0, 'o', 0, 's', 0, 'k', 0, '-', 0, '7', 0, 0, 0}, userDataSet.ClientCoreData.ClientName[:16])
+
+	clientInfo := pdu.NewClientInfo(c.domain, c.username, c.password, c.clientSettings())
+	require.Equal(t, pdu.PerfDisableWallpaper|pdu.PerfDisableTheming, clientInfo.InfoPacket.ExtraInfo.PerformanceFlags)
+
+	confirmActive := pdu.NewClientConfirmActive(0x103ea, 1007, c.clientSettings(), false)
+	require.Equal(t, uint16(32), confirmActive.CapabilitySets[1].BitmapCapabilitySet.PreferredBitsPerPixel)
+	require.Equal(t, uint32(0x00000407), confirmActive.CapabilitySets[5].InputCapabilitySet.KeyboardLayout)
+	require.Equal(t, uint32(0x8000), confirmActive.CapabilitySets[11].MultifragmentUpdateCapabilitySet.MaxRequestSize)
+}
+
+func TestNew_Planar(t *testing.T) {
+	config := testConfig
+	config.Planar = true
+
+	c, err := New(config)
+	require.NoError(t, err)
+
+	userDataSet := pdu.NewClientUserDataSet(0, c.clientSettings(), c.channels)
+	require.Equal(t, uint16(0x0008), userDataSet.ClientCoreData.SupportedColorDepths) // RNS_UD_32BPP_SUPPORT
+	require.Equal(t, pdu.ECFWant32BPPSession, userDataSet.ClientCoreData.EarlyCapabilityFlags&pdu.ECFWant32BPPSession)
+
+	bitmapCapabilitySet := pdu.NewBitmapCapabilitySet(c.clientSettings()).BitmapCapabilitySet
+	require.Equal(t, uint16(32), bitmapCapabilitySet.PreferredBitsPerPixel)
+	require.Equal(t, pdu.DrawAllowDynamicColorFidelity|pdu.DrawAllowColorSubsampling|pdu.DrawAllowSkipAlpha, bitmapCapabilitySet.DrawingFlags)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	for _, tc := range []struct {
+		modify func(config *Config)
+		err    error
+	}{
+		{func(config *Config) { config.ColorDepth = 8 }, pdu.ErrInvalidColorDepth},
+		{func(config *Config) { config.ClientName = "a-client-name-too-long" }, pdu.ErrClientNameTooLong},
+		{func(config *Config) { config.Compression, config.CompressionType = true, 7 }, pdu.ErrInvalidCompression},
+		{func(config *Config) { config.ConsoleSession, config.SessionID = true, 3 }, pdu.ErrConsoleSessionID},
+		{func(config *Config) { config.Planar, config.ColorDepth = true, 16 }, pdu.ErrPlanarColorDepth},
+		{func(config *Config) { config.MaxRequestSize = 0xFFFF }, nil},
+		{func(config *Config) { config.MaxRequestSize = 0x10000 }, pdu.ErrMaxRequestSize},
+	} {
+		config := testConfig
+		tc.modify(&config)
+
+		_, err := New(config)
+		require.ErrorIs(t, err, tc.err)
+	}
+}
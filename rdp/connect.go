@@ -1,56 +1,188 @@
 package rdp
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"os"
+	"time"
 
+	"github.com/lunnik9/rdp/rdp/dialctx"
 	"github.com/lunnik9/rdp/rdp/pdu"
+	"github.com/lunnik9/rdp/rdp/sec"
 )
 
-func (c *client) Connect() error {
-	var err error
+// Connect dials the server and runs the connection sequence, falling back to the security protocols
+// the server requires and following Server Redirection PDUs of a connection broker. Every phase has the deadline of the phase timeout or of ctx, whichever
+// comes first, and the cancellation of ctx interrupts the phase. The connection is closed on failure.
+func (c *Client) Connect(ctx context.Context) error {
+	c.requestedProtocols = requestedProtocols(c.securityProtocols)
+	c.negotiationAttempts = nil
+
+	for redirections := 0; ; {
+		if err := c.dial(ctx); err != nil {
+			return err
+		}
+
+		err := c.connect(ctx)
+		if err != nil {
+			_ = c.conn.Close()
+		}
+
+		var failure *NegotiationFailureError
+
+		switch {
+		case errors.As(err, &failure):
+			if !c.fallback(failure) {
+				return err
+			}
+
+			c.resetSession()
 
-	if err = c.connectionInitiation(); err != nil {
-		return fmt.Errorf("connection initiation: %w", err)
+			continue
+		case !errors.Is(err, errRedirected):
+			return err
+		}
+
+		if redirections == maxRedirections {
+			return ErrTooManyRedirections
+		}
+
+		redirections++
+
+		if err = c.redirect(); err != nil {
+			return fmt.Errorf("server redirection: %w", err)
+		}
+
+		c.requestedProtocols = requestedProtocols(c.securityProtocols) // the target negotiates anew
 	}
+}
 
-	if err = c.basicSettingsExchange(); err != nil {
-		return fmt.Errorf("basic settings exchange: %w", err)
+// phase of the connection sequence.
+type phase struct {
+	name string
+	run  func() error
+}
+
+func (c *Client) connect(ctx context.Context) error {
+	c.connectCtx = ctx
+	defer func() { c.connectCtx = nil }()
+
+	stop := dialctx.InterruptOnDone(ctx, c.conn)
+	defer stop()
+
+	for _, p := range []phase{
+		{"connection initiation", c.connectionInitiation},
+		{"security upgrade", c.securityUpgrade},
+		{"basic settings exchange", c.basicSettingsExchange},
+		{"channel connection", c.channelConnection},
+		{"security commencement", c.securityCommencement},
+		{"secure settings exchange", c.secureSettingsExchange},
+		{"licensing", c.licensing},
+		{"capabilities exchange", c.capabilitiesExchange},
+		{"connection finalization", c.connectionFinalization},
+	} {
+		if err := c.runPhase(ctx, p); err != nil {
+			return err
+		}
 	}
 
-	if err = c.channelConnection(); err != nil {
-		return fmt.Errorf("channel connection: %w", err)
+	if c.remoteApp != nil {
+		c.railState = RailStateInitializing
 	}
 
-	if err = c.secureSettingsExchange(); err != nil {
-		return fmt.Errorf("secure settings exchange: %w", err)
+	stop()
+
+	return c.conn.SetDeadline(time.Time{})
+}
+
+// runPhase runs the phase with the deadline of the phase timeout or of ctx and tells which one expired.
+func (c *Client) runPhase(ctx context.Context, p phase) error {
+	deadline, isCtxDeadline := c.phaseDeadline(ctx)
+
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("%s: %w", p.name, err)
 	}
 
-	if err = c.licensing(); err != nil {
-		return fmt.Errorf("licensing: %w", err)
+	// checked after SetDeadline, which must not override the deadline set on cancellation
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", p.name, err)
 	}
 
-	if err = c.capabilitiesExchange(); err != nil {
-		return fmt.Errorf("capabilities exchange: %w", err)
+	err := p.run()
+
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return fmt.Errorf("%s: %w", p.name, ctx.Err())
+	case !isTimeout(err):
+		return fmt.Errorf("%s: %w", p.name, err)
+	case isCtxDeadline:
+		return fmt.Errorf("%s: %w", p.name, context.DeadlineExceeded)
 	}
 
-	if err = c.connectionFinalization(); err != nil {
-		return fmt.Errorf("connection finalizatioin: %w", err)
+	return fmt.Errorf("%s: %w after %s: %v", p.name, ErrPhaseTimeout, c.phaseTimeout, err)
+}
+
+// phaseDeadline returns the deadline of the phase starting now, the earlier of the phase timeout and
+// the deadline of ctx, or the zero time without both.
+func (c *Client) phaseDeadline(ctx context.Context) (deadline time.Time, isCtxDeadline bool) {
+	if c.phaseTimeout > 0 {
+		deadline = time.Now().Add(c.phaseTimeout)
 	}
 
-	return nil
+	ctxDeadline, ok := ctx.Deadline()
+	if ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		return ctxDeadline, true
+	}
+
+	return deadline, false
 }
 
-func (c *client) connectionInitiation() error {
+// restartPhaseDeadline sets the deadline of the phase again after waiting for the user, so the certificate
+// prompt does not count toward the phase timeout.
+func (c *Client) restartPhaseDeadline() error {
+	if c.connectCtx == nil {
+		return nil
+	}
+
+	deadline, _ := c.phaseDeadline(c.connectCtx)
+
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	return c.connectCtx.Err()
+}
+
+// isTimeout reports whether err is caused by the deadline of the connection.
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, os.ErrDeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (c *Client) connectionInitiation() error {
 	var err error
 
 	req := pdu.ClientConnectionRequest{
+		RoutingToken: c.routingToken,
+		Cookie:       c.cookie,
 		NegotiationRequest: pdu.NegotiationRequest{
-			RequestedProtocols: c.selectedProtocol,
+			Flags:              c.negotiationFlags,
+			RequestedProtocols: c.requestedProtocols,
 		},
 	}
 
+	if err = req.Validate(); err != nil {
+		return err
+	}
+
 	var (
 		resp pdu.ServerConnectionConfirm
 		wire io.Reader
@@ -65,26 +197,57 @@ func (c *client) connectionInitiation() error {
 	}
 
 	if resp.Type.IsFailure() {
-		return fmt.Errorf("negotiation faliure: failureCode = %d", resp.FailureCode())
+		c.negotiationAttempts = append(c.negotiationAttempts, NegotiationAttempt{
+			RequestedProtocols: c.requestedProtocols,
+			FailureCode:        resp.FailureCode(),
+		})
+
+		return &NegotiationFailureError{RequestedProtocols: c.requestedProtocols, FailureCode: resp.FailureCode()}
 	}
 
 	c.serverNegotiationFlags = resp.Flags
+	c.selectedProtocol = resp.SelectedProtocol()
 
-	if !resp.SelectedProtocol().IsSSL() {
-		return ErrUnsupportedRequestedProtocol
-	}
+	c.negotiationAttempts = append(c.negotiationAttempts, NegotiationAttempt{
+		RequestedProtocols: c.requestedProtocols,
+		SelectedProtocol:   c.selectedProtocol,
+	})
 
+	log.Printf("RDP: requested %s, selected %s\n", c.requestedProtocols, c.selectedProtocol)
 	log.Println("Server negotiation flags: " + c.serverNegotiationFlags.String())
 
-	if c.selectedProtocol.IsSSL() {
+	return c.checkCredentialModes()
+}
+
+// securityUpgrade switches to the security protocol selected by the server: TLS, CredSSP of NLA
+// and Early User Authorization.
+func (c *Client) securityUpgrade() error {
+	switch {
+	case c.selectedProtocol.IsRDP():
+		return nil
+	case c.selectedProtocol.IsSSL():
 		return c.StartTLS()
+	case c.selectedProtocol.IsHybrid(), c.selectedProtocol.IsHybridEx():
+		if err := c.StartTLS(); err != nil {
+			return err
+		}
+
+		if err := c.StartNLA(); err != nil {
+			return err
+		}
+
+		if c.selectedProtocol.IsHybridEx() {
+			return c.earlyUserAuthorization()
+		}
+
+		return nil
 	}
 
-	return nil
+	return ErrUnsupportedRequestedProtocol
 }
 
-func (c *client) basicSettingsExchange() error {
-	clientUserDataSet := pdu.NewClientUserDataSet(uint32(c.selectedProtocol), c.desktopWidth, c.desktopHeight, c.channels)
+func (c *Client) basicSettingsExchange() error {
+	clientUserDataSet := pdu.NewClientUserDataSet(uint32(c.selectedProtocol), c.clientSettings(), c.channels)
 
 	wire, err := c.mcsLayer.Connect(clientUserDataSet.Serialize())
 	if err != nil {
@@ -99,6 +262,8 @@ func (c *client) basicSettingsExchange() error {
 
 	c.initChannels(serverUserData.ServerNetworkData)
 
+	c.serverSecurityData = serverUserData.ServerSecurityData
+
 	log.Println("MCS: Server Connect Response: earlyCapabilityFlags: ", serverUserData.ServerCoreData.EarlyCapabilityFlags)
 
 	// RNS_UD_SC_SKIP_CHANNELJOIN_SUPPORTED = 0x00000008
@@ -107,9 +272,9 @@ func (c *client) basicSettingsExchange() error {
 	return nil
 }
 
-func (c *client) initChannels(serverNetworkData *pdu.ServerNetworkData) {
-	if c.channels == nil {
-		c.channelIDMap = make(map[string]uint16, len(c.channels))
+func (c *Client) initChannels(serverNetworkData *pdu.ServerNetworkData) {
+	if c.channelIDMap == nil {
+		c.channelIDMap = make(map[string]uint16, len(c.channels)+2)
 	}
 
 	for i, channelName := range c.channels {
@@ -119,7 +284,7 @@ func (c *client) initChannels(serverNetworkData *pdu.ServerNetworkData) {
 	c.channelIDMap["global"] = serverNetworkData.MCSChannelId
 }
 
-func (c *client) channelConnection() error {
+func (c *Client) channelConnection() error {
 	err := c.mcsLayer.ErectDomain()
 	if err != nil {
 		return err
@@ -144,49 +309,81 @@ func (c *client) channelConnection() error {
 	return nil
 }
 
-func (c *client) secureSettingsExchange() error {
-	clientInfoPDU := pdu.NewClientInfo(c.domain, c.username, c.password)
-
-	if c.remoteApp != nil {
-		clientInfoPDU.InfoPacket.Flags |= pdu.InfoFlagRail
+// securityCommencement sends the Security Exchange PDU and enables Standard RDP Security
+// when the server selected PROTOCOL_RDP with encryption.
+func (c *Client) securityCommencement() error {
+	if !c.selectedProtocol.IsRDP() || c.serverSecurityData == nil {
+		return nil
 	}
 
-	log.Println("RDP: Client Info")
-
-	if err := c.mcsLayer.Send(c.userID, c.channelIDMap["global"], clientInfoPDU.Serialize()); err != nil {
-		return fmt.Errorf("client info: %w", err)
+	encryptionMethod := sec.EncryptionMethod(c.serverSecurityData.EncryptionMethod)
+	if encryptionMethod == sec.EncryptionMethodNone {
+		return nil
 	}
 
-	return nil
-}
+	publicKey, err := sec.ServerPublicKey(c.serverSecurityData.ServerCertificate)
+	if err != nil {
+		return err
+	}
 
-func (c *client) licensing() error {
-	log.Println("RDP: Server License")
+	clientRandom := make([]byte, 32)
+	if _, err = rand.Read(clientRandom); err != nil {
+		return err
+	}
 
-	_, wire, err := c.mcsLayer.Receive()
+	cipher, err := sec.NewCipher(encryptionMethod, clientRandom, c.serverSecurityData.ServerRandom)
 	if err != nil {
 		return err
 	}
 
-	var resp pdu.ServerLicenseError
-	if err = resp.Deserialize(wire); err != nil {
-		return fmt.Errorf("server license error: %w", err)
+	log.Println("RDP: Security Exchange")
+
+	req := pdu.ClientSecurityExchange{
+		EncryptedClientRandom: sec.EncryptClientRandom(publicKey, clientRandom),
 	}
 
-	if resp.Preamble.MsgType == 0x03 { // NEW_LICENSE
-		return nil
+	if err = c.secLayer.SendWithFlags(c.userID, c.channelIDMap["global"], sec.FlagExchangePkt, req.Serialize()); err != nil {
+		return fmt.Errorf("client security exchange: %w", err)
 	}
 
-	if resp.Preamble.MsgType != 0xFF { // ERROR_ALERT
-		return fmt.Errorf("unknown license msg type: %d", resp.Preamble.MsgType)
+	c.secLayer.Enable(cipher)
+	c.fastPath.SetCipher(cipher)
+
+	c.clientRandom = clientRandom
+
+	return nil
+}
+
+func (c *Client) secureSettingsExchange() error {
+	password := c.password
+	if c.isCredentialless() {
+		password = ""
 	}
 
-	if resp.ValidClientMessage.ErrorCode != 0x00000007 { // STATUS_VALID_CLIENT
-		return fmt.Errorf("unknown license error code: %d", resp.ValidClientMessage.ErrorCode)
+	clientInfoPDU := pdu.NewClientInfo(c.domain, c.username, password, c.clientSettings())
+
+	if !c.isCredentialless() {
+		clientInfoPDU.InfoPacket.PasswordCookie = c.passwordCookie
 	}
 
-	if resp.ValidClientMessage.StateTransition != 0x00000002 { // ST_NO_TRANSITION
-		return fmt.Errorf("unknown license state transition: %d", resp.ValidClientMessage.StateTransition)
+	if c.remoteApp != nil {
+		clientInfoPDU.InfoPacket.Flags |= pdu.InfoFlagRail
+	}
+
+	if c.autoReconnectCookie != nil {
+		clientRandom := c.clientRandom
+		if clientRandom == nil { // Enhanced RDP Security
+			clientRandom = make([]byte, 32)
+		}
+
+		clientInfoPDU.InfoPacket.ExtraInfo.AutoReconnectCookie = pdu.NewClientAutoReconnect(c.autoReconnectCookie, clientRandom)
+	}
+
+	log.Println("RDP: Client Info")
+
+	err := c.secLayer.SendWithFlags(c.userID, c.channelIDMap["global"], sec.FlagInfoPkt, clientInfoPDU.InfoPacket.Serialize())
+	if err != nil {
+		return fmt.Errorf("client info: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,69 @@
+package rdp
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// silentServer reads the requests of the client and never answers.
+func silentServer(t *testing.T) net.Conn {
+	clientConn, serverConn := net.Pipe()
+
+	go func() { _, _ = io.Copy(io.Discard, serverConn) }()
+
+	t.Cleanup(func() { _ = serverConn.Close() })
+
+	return clientConn
+}
+
+func TestClient_ConnectTimeout(t *testing.T) {
+	c, err := New(testConfig, WithConn(silentServer(t)), WithPhaseTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	err = c.Connect(context.Background())
+	require.ErrorIs(t, err, ErrPhaseTimeout)
+	require.ErrorContains(t, err, "connection initiation")
+}
+
+func TestClient_ConnectContext(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ctx func() (context.Context, context.CancelFunc)
+		err error
+	}{
+		"canceled": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+
+				return ctx, cancel
+			},
+			err: context.Canceled,
+		},
+		"deadline": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			err: context.DeadlineExceeded,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(testConfig, WithConn(silentServer(t)))
+			require.NoError(t, err)
+
+			ctx, cancel := tc.ctx()
+			defer cancel()
+
+			start := time.Now()
+
+			err = c.Connect(ctx)
+			require.ErrorIs(t, err, tc.err)
+			require.ErrorContains(t, err, "connection initiation")
+			require.Less(t, time.Since(start), defaultPhaseTimeout)
+		})
+	}
+}
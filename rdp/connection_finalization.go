@@ -7,27 +7,27 @@ import (
 	"github.com/lunnik9/rdp/rdp/pdu"
 )
 
-func (c *client) connectionFinalization() error {
+func (c *Client) connectionFinalization() error {
 	var err error
 
 	synchronize := pdu.NewSynchronize(c.shareID, c.userID)
-	if err = c.mcsLayer.Send(c.userID, c.channelIDMap["global"], synchronize.Serialize()); err != nil {
+	if err = c.secLayer.Send(c.userID, c.channelIDMap["global"], synchronize.Serialize()); err != nil {
 		return err
 	}
 
 	controlCooperate := pdu.NewControl(c.shareID, c.userID, pdu.ControlActionCooperate)
-	if err = c.mcsLayer.Send(c.userID, c.channelIDMap["global"], controlCooperate.Serialize()); err != nil {
+	if err = c.secLayer.Send(c.userID, c.channelIDMap["global"], controlCooperate.Serialize()); err != nil {
 		return err
 	}
 
 	controlRequestControl := pdu.NewControl(c.shareID, c.userID, pdu.ControlActionRequestControl)
-	if err = c.mcsLayer.Send(c.userID, c.channelIDMap["global"], controlRequestControl.Serialize()); err != nil {
+	if err = c.secLayer.Send(c.userID, c.channelIDMap["global"], controlRequestControl.Serialize()); err != nil {
 		return err
 	}
 
 	fontList := pdu.NewFontList(c.shareID, c.userID)
 
-	err = c.mcsLayer.Send(c.userID, c.channelIDMap["global"], fontList.Serialize())
+	err = c.secLayer.Send(c.userID, c.channelIDMap["global"], fontList.Serialize())
 	if err != nil {
 		return err
 	}
@@ -47,7 +47,7 @@ func (c *client) connectionFinalization() error {
 			break
 		}
 
-		_, wire, err = c.mcsLayer.Receive()
+		_, wire, err = c.secLayer.Receive()
 		if err != nil {
 			return err
 		}
@@ -57,6 +57,14 @@ func (c *client) connectionFinalization() error {
 			return err
 		}
 
+		if dataPDU.ServerRedirection != nil {
+			if err = c.handleServerRedirection(&dataPDU.ServerRedirection.RedirectionPacket); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		pduType2 := dataPDU.ShareDataHeader.PDUType2
 
 		switch {
@@ -72,6 +80,8 @@ func (c *client) connectionFinalization() error {
 			}
 		case pduType2.IsFontmap():
 			fontMapReceived = true
+		case pduType2.IsSaveSessionInfo():
+			c.handleSaveSessionInfo(dataPDU.SaveSessionInfo)
 		case pduType2.IsErrorInfo():
 			return fmt.Errorf("server error info: %d", dataPDU.ErrorInfoPDUData.ErrorInfo)
 		default:
@@ -79,9 +89,5 @@ func (c *client) connectionFinalization() error {
 		}
 	}
 
-	if c.remoteApp != nil {
-		c.railState = RailStateInitializing
-	}
-
 	return nil
 }
@@ -0,0 +1,34 @@
+package rdp
+
+import "github.com/lunnik9/rdp/rdp/pdu"
+
+// WithRestrictedAdmin requests Restricted Admin mode: the user is authenticated by CredSSP,
+// but no reusable credentials are delegated to the server.
+func WithRestrictedAdmin() Option {
+	return func(c *Client) {
+		c.negotiationFlags |= pdu.NegReqFlagRestrictedAdminModeRequired
+		c.securityProtocols = []pdu.NegotiationProtocol{pdu.NegotiationProtocolHybridEx, pdu.NegotiationProtocolHybrid}
+	}
+}
+
+// isCredentialless reports whether the password must not reach the server.
+func (c *Client) isCredentialless() bool {
+	return c.negotiationFlags.IsRestrictedAdminModeRequired()
+}
+
+// checkCredentialModes fails when the server can't honour the requested credential modes.
+func (c *Client) checkCredentialModes() error {
+	if !c.isCredentialless() {
+		return nil
+	}
+
+	if !c.selectedProtocol.IsHybrid() && !c.selectedProtocol.IsHybridEx() {
+		return ErrNLARequired
+	}
+
+	if c.negotiationFlags.IsRestrictedAdminModeRequired() && !c.serverNegotiationFlags.IsRestrictedAdminModeSupported() {
+		return ErrRestrictedAdminNotSupported
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package rdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+func TestClient_checkCredentialModes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		setMode     Option
+		selected    pdu.NegotiationProtocol
+		serverFlags pdu.NegotiationResponseFlag
+		wantErr     error
+	}{
+		{
+			name:     "password",
+			setMode:  func(*Client) {},
+			selected: pdu.NegotiationProtocolSSL,
+		},
+		{
+			name:        "restricted admin",
+			setMode:     WithRestrictedAdmin(),
+			selected:    pdu.NegotiationProtocolHybrid,
+			serverFlags: pdu.NegotiationResponseFlagAdminModeSupported,
+		},
+		{
+			name:     "restricted admin not supported",
+			setMode:  WithRestrictedAdmin(),
+			selected: pdu.NegotiationProtocolHybridEx,
+			wantErr:  ErrRestrictedAdminNotSupported,
+		},
+		{
+			name:        "restricted admin without NLA",
+			setMode:     WithRestrictedAdmin(),
+			selected:    pdu.NegotiationProtocolSSL,
+			serverFlags: pdu.NegotiationResponseFlagAdminModeSupported,
+			wantErr:     ErrNLARequired,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Client
+
+			tc.setMode(&c)
+
+			c.selectedProtocol = tc.selected
+			c.serverNegotiationFlags = tc.serverFlags
+
+			require.ErrorIs(t, c.checkCredentialModes(), tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package credssp
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoNegoToken         = errors.New("no nego token in server TSRequest")
+	ErrInvalidPubKeyAuth   = errors.New("invalid server pubKeyAuth")
+	ErrInvalidEncryptedMsg = errors.New("invalid encrypted message")
+)
+
+// ServerError TSRequest errorCode sent by the server.
+type ServerError struct {
+	Code uint32
+}
+
+var statusCodeMap = map[uint32]string{
+	0xC000006A: "STATUS_WRONG_PASSWORD",
+	0xC000006D: "STATUS_LOGON_FAILURE",
+	0xC000006E: "STATUS_ACCOUNT_RESTRICTION",
+	0xC000006F: "STATUS_INVALID_LOGON_HOURS",
+	0xC0000070: "STATUS_INVALID_WORKSTATION",
+	0xC0000071: "STATUS_PASSWORD_EXPIRED",
+	0xC0000072: "STATUS_ACCOUNT_DISABLED",
+	0xC0000193: "STATUS_ACCOUNT_EXPIRED",
+	0xC0000224: "STATUS_PASSWORD_MUST_CHANGE",
+	0xC0000234: "STATUS_ACCOUNT_LOCKED_OUT",
+	0xC000015B: "STATUS_LOGON_TYPE_NOT_GRANTED",
+}
+
+func (e ServerError) Error() string {
+	if name, ok := statusCodeMap[e.Code]; ok {
+		return fmt.Sprintf("CredSSP server error: %s (0x%08X)", name, e.Code)
+	}
+
+	return fmt.Sprintf("CredSSP server error: 0x%08X", e.Code)
+}
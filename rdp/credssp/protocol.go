@@ -0,0 +1,281 @@
+package credssp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/lunnik9/rdp/rdp/ntlm"
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+const (
+	// version highest CredSSP protocol version supported by the client
+	version = 6
+
+	// nonceVersion first version binding pubKeyAuth to the client nonce
+	nonceVersion = 5
+
+	// credTypePassword TSPasswordCreds
+	credTypePassword = 1
+)
+
+const (
+	clientServerHashMagic = "CredSSP Client-To-Server Binding Hash\x00"
+	serverClientHashMagic = "CredSSP Server-To-Client Binding Hash\x00"
+)
+
+// Credentials user credentials authenticated by NTLM and delegated to the server.
+type Credentials struct {
+	Domain   string
+	Username string
+	Password string
+
+	// RestrictedAdmin delegates empty credentials (Restricted Admin mode),
+	// the server uses its own machine account for network resources.
+	RestrictedAdmin bool
+}
+
+// tsCredentials returns TSCredentials of the connection mode.
+func (c Credentials) tsCredentials() TSCredentials {
+	// Restricted Admin mode sends empty domain, user name and password
+	var passwordCreds TSPasswordCreds
+
+	if !c.RestrictedAdmin {
+		passwordCreds = TSPasswordCreds{
+			DomainName: utf16.Encode(c.Domain),
+			UserName:   utf16.Encode(c.Username),
+			Password:   utf16.Encode(c.Password),
+		}
+	}
+
+	return TSCredentials{
+		CredType:    credTypePassword,
+		Credentials: passwordCreds.Serialize(),
+	}
+}
+
+type Protocol struct {
+	conn   io.ReadWriter
+	random io.Reader
+}
+
+func New(conn io.ReadWriter) *Protocol {
+	return &Protocol{
+		conn:   conn,
+		random: rand.Reader,
+	}
+}
+
+// Authenticate runs CredSSP over the established TLS connection: NTLMv2 authentication
+// wrapped in SPNEGO, public key binding to the TLS server certificate and credentials delegation.
+// publicKey is the SubjectPublicKey of the server TLS certificate.
+func (p *Protocol) Authenticate(credentials Credentials, publicKey []byte) error {
+	ntlmv2 := ntlm.NewNTLMv2(credentials.Domain, credentials.Username, credentials.Password)
+
+	log.Println("CredSSP: NTLM negotiate")
+
+	err := p.send(&TSRequest{
+		Version:    version,
+		NegoTokens: [][]byte{negTokenInit(ntlmv2.Negotiate())},
+	})
+	if err != nil {
+		return fmt.Errorf("negotiate: %w", err)
+	}
+
+	resp, err := p.receive()
+	if err != nil {
+		return fmt.Errorf("challenge: %w", err)
+	}
+
+	if len(resp.NegoTokens) == 0 {
+		return ErrNoNegoToken
+	}
+
+	challenge, spnego, err := unwrapNegoToken(resp.NegoTokens[0])
+	if err != nil {
+		return fmt.Errorf("challenge: %w", err)
+	}
+
+	negotiatedVersion := version
+	if resp.Version < negotiatedVersion {
+		negotiatedVersion = resp.Version
+	}
+
+	log.Println("CredSSP: NTLM authenticate")
+
+	authenticate, err := ntlmv2.Authenticate(challenge)
+	if err != nil {
+		return fmt.Errorf("authenticate: %w", err)
+	}
+
+	security := ntlmv2.Security()
+
+	req := TSRequest{
+		Version:    version,
+		NegoTokens: [][]byte{authenticate},
+	}
+
+	if spnego {
+		token := NegTokenResp{
+			ResponseToken: authenticate,
+			MechListMIC:   security.Clone().Sign(mechTypeList()),
+		}
+
+		req.NegoTokens = [][]byte{token.Serialize()}
+	}
+
+	if negotiatedVersion >= nonceVersion {
+		req.ClientNonce = make([]byte, 32)
+
+		if _, err = io.ReadFull(p.random, req.ClientNonce); err != nil {
+			return err
+		}
+	}
+
+	req.PubKeyAuth = encrypt(security, clientPubKeyAuth(negotiatedVersion, req.ClientNonce, publicKey))
+
+	if err = p.send(&req); err != nil {
+		return fmt.Errorf("authenticate: %w", err)
+	}
+
+	resp, err = p.receive()
+	if err != nil {
+		return fmt.Errorf("public key auth: %w", err)
+	}
+
+	if spnego && len(resp.NegoTokens) > 0 {
+		if err = verifyNegTokenResp(security, resp.NegoTokens[0]); err != nil {
+			return fmt.Errorf("public key auth: %w", err)
+		}
+	}
+
+	pubKeyAuth, err := decrypt(security, resp.PubKeyAuth)
+	if err != nil {
+		return fmt.Errorf("public key auth: %w", err)
+	}
+
+	if !bytes.Equal(pubKeyAuth, serverPubKeyAuth(negotiatedVersion, req.ClientNonce, publicKey)) {
+		return ErrInvalidPubKeyAuth
+	}
+
+	log.Println("CredSSP: credentials")
+
+	tsCredentials := credentials.tsCredentials()
+
+	err = p.send(&TSRequest{
+		Version:  version,
+		AuthInfo: encrypt(security, tsCredentials.Serialize()),
+	})
+	if err != nil {
+		return fmt.Errorf("credentials: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Protocol) send(req *TSRequest) error {
+	_, err := p.conn.Write(req.Serialize())
+
+	return err
+}
+
+func (p *Protocol) receive() (*TSRequest, error) {
+	var resp TSRequest
+
+	if err := resp.Deserialize(p.conn); err != nil {
+		return nil, err
+	}
+
+	if resp.ErrorCode != 0 {
+		return nil, ServerError{Code: resp.ErrorCode}
+	}
+
+	return &resp, nil
+}
+
+// unwrapNegoToken extracts the NTLM message from a NegTokenResp,
+// servers answering with a bare NTLM message are accepted as well.
+func unwrapNegoToken(token []byte) ([]byte, bool, error) {
+	if bytes.HasPrefix(token, []byte("NTLMSSP\x00")) {
+		return token, false, nil
+	}
+
+	var resp NegTokenResp
+
+	if err := resp.Deserialize(bytes.NewReader(token)); err != nil {
+		return nil, false, err
+	}
+
+	if resp.NegState == NegStateReject {
+		return nil, false, ErrSPNEGOReject
+	}
+
+	return resp.ResponseToken, true, nil
+}
+
+func verifyNegTokenResp(security *ntlm.Security, token []byte) error {
+	var resp NegTokenResp
+
+	if err := resp.Deserialize(bytes.NewReader(token)); err != nil {
+		return err
+	}
+
+	if resp.NegState == NegStateReject {
+		return ErrSPNEGOReject
+	}
+
+	if len(resp.MechListMIC) == 0 {
+		return nil
+	}
+
+	return security.Clone().Verify(mechTypeList(), resp.MechListMIC)
+}
+
+func clientPubKeyAuth(version int, nonce, publicKey []byte) []byte {
+	if version < nonceVersion {
+		return publicKey
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(clientServerHashMagic))
+	hash.Write(nonce)
+	hash.Write(publicKey)
+
+	return hash.Sum(nil)
+}
+
+func serverPubKeyAuth(version int, nonce, publicKey []byte) []byte {
+	if version < nonceVersion {
+		// first byte of the public key incremented by one
+		pubKeyAuth := append([]byte{}, publicKey...)
+		pubKeyAuth[0]++
+
+		return pubKeyAuth
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(serverClientHashMagic))
+	hash.Write(nonce)
+	hash.Write(publicKey)
+
+	return hash.Sum(nil)
+}
+
+// encrypt seals the message, the NTLM signature precedes the encrypted data.
+func encrypt(security *ntlm.Security, message []byte) []byte {
+	sealed, signature := security.Seal(message)
+
+	return append(signature, sealed...)
+}
+
+func decrypt(security *ntlm.Security, data []byte) ([]byte, error) {
+	if len(data) < 16 {
+		return nil, ErrInvalidEncryptedMsg
+	}
+
+	return security.Unseal(data[16:], data[:16])
+}
@@ -0,0 +1,432 @@
+package credssp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/md4"
+
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+// testResponder is a minimal CredSSP server verifying NTLMv2 independently of the ntlm package.
+type testResponder struct {
+	version  int
+	domain   string
+	username string
+	password string
+
+	conn      net.Conn
+	publicKey []byte
+}
+
+type negTokenInitASN1 struct {
+	MechTypes []asn1.ObjectIdentifier `asn1:"explicit,tag:0"`
+	MechToken []byte                  `asn1:"explicit,optional,tag:2"`
+}
+
+func parseNegTokenInit(token []byte) (*negTokenInitASN1, error) {
+	var gss asn1.RawValue
+
+	if _, err := asn1.Unmarshal(token, &gss); err != nil {
+		return nil, err
+	}
+
+	var oid asn1.ObjectIdentifier
+
+	rest, err := asn1.Unmarshal(gss.Bytes, &oid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !oid.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 2}) {
+		return nil, errors.New("not SPNEGO")
+	}
+
+	var inner asn1.RawValue
+
+	if _, err = asn1.Unmarshal(rest, &inner); err != nil {
+		return nil, err
+	}
+
+	var init negTokenInitASN1
+
+	if _, err = asn1.Unmarshal(inner.Bytes, &init); err != nil {
+		return nil, err
+	}
+
+	return &init, nil
+}
+
+const (
+	clientSigningMagic = "session key to client-to-server signing key magic constant\x00"
+	serverSigningMagic = "session key to server-to-client signing key magic constant\x00"
+	clientSealingMagic = "session key to client-to-server sealing key magic constant\x00"
+	serverSealingMagic = "session key to server-to-client sealing key magic constant\x00"
+)
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+
+	for _, d := range data {
+		mac.Write(d)
+	}
+
+	return mac.Sum(nil)
+}
+
+func deriveKey(key []byte, magic string) []byte {
+	sum := md5.Sum(append(append([]byte{}, key...), magic...))
+
+	return sum[:]
+}
+
+func checksum(handle *rc4.Cipher, signingKey []byte, seqNum uint32, message []byte) []byte {
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, seqNum)
+
+	sum := make([]byte, 8)
+	handle.XORKeyStream(sum, hmacMD5(signingKey, seq, message)[:8])
+
+	return append(append([]byte{0x01, 0x00, 0x00, 0x00}, sum...), seq...)
+}
+
+func field(msg []byte, pos int) []byte {
+	length := binary.LittleEndian.Uint16(msg[pos:])
+	offset := binary.LittleEndian.Uint32(msg[pos+4:])
+
+	return msg[offset : offset+uint32(length)]
+}
+
+func (s *testResponder) send(req TSRequest) error {
+	req.Version = s.version
+	_, err := s.conn.Write(req.Serialize())
+
+	return err
+}
+
+func (s *testResponder) run() (*TSCredentials, error) {
+	var req TSRequest
+
+	if err := req.Deserialize(s.conn); err != nil {
+		return nil, err
+	}
+
+	init, err := parseNegTokenInit(req.NegoTokens[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if !init.MechTypes[0].Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 2, 10}) {
+		return nil, errors.New("NTLMSSP not offered")
+	}
+
+	negotiate := init.MechToken
+	serverChallenge := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+	targetInfo := new(bytes.Buffer)
+	for _, pair := range []struct {
+		id    uint16
+		value []byte
+	}{
+		{0x0002, utf16.Encode("DOMAIN")},
+		{0x0001, utf16.Encode("SERVER")},
+		{0x0007, make([]byte, 8)},
+		{0x0000, nil},
+	} {
+		_ = binary.Write(targetInfo, binary.LittleEndian, pair.id)
+		_ = binary.Write(targetInfo, binary.LittleEndian, uint16(len(pair.value)))
+		targetInfo.Write(pair.value)
+	}
+
+	challenge := new(bytes.Buffer)
+	challenge.WriteString("NTLMSSP\x00")
+	_ = binary.Write(challenge, binary.LittleEndian, uint32(2))
+	_ = binary.Write(challenge, binary.LittleEndian, []uint16{0, 0})
+	_ = binary.Write(challenge, binary.LittleEndian, uint32(56))
+	_ = binary.Write(challenge, binary.LittleEndian, binary.LittleEndian.Uint32(negotiate[12:]))
+	challenge.Write(serverChallenge)
+	challenge.Write(make([]byte, 8))
+	_ = binary.Write(challenge, binary.LittleEndian, []uint16{uint16(targetInfo.Len()), uint16(targetInfo.Len())})
+	_ = binary.Write(challenge, binary.LittleEndian, uint32(56))
+	challenge.Write([]byte{0x06, 0x01, 0xb1, 0x1d, 0x00, 0x00, 0x00, 0x0f})
+	challenge.Write(targetInfo.Bytes())
+
+	token := NegTokenResp{NegState: NegStateAcceptIncomplete, ResponseToken: challenge.Bytes()}
+	if err = s.send(TSRequest{NegoTokens: [][]byte{token.Serialize()}}); err != nil {
+		return nil, err
+	}
+
+	req = TSRequest{}
+	if err = req.Deserialize(s.conn); err != nil {
+		return nil, err
+	}
+
+	var authToken NegTokenResp
+	if err = authToken.Deserialize(bytes.NewReader(req.NegoTokens[0])); err != nil {
+		return nil, err
+	}
+
+	authenticate := authToken.ResponseToken
+	ntResponse := field(authenticate, 20)
+	user := field(authenticate, 36)
+	domain := field(authenticate, 28)
+
+	hash := md4.New()
+	hash.Write(utf16.Encode(s.password))
+
+	responseKeyNT := hmacMD5(hash.Sum(nil), utf16.Encode(strings.ToUpper(s.username)+s.domain))
+	ntProofStr := hmacMD5(responseKeyNT, serverChallenge, ntResponse[16:])
+
+	if !bytes.Equal(ntProofStr, ntResponse[:16]) ||
+		!bytes.Equal(user, utf16.Encode(s.username)) ||
+		!bytes.Equal(domain, utf16.Encode(s.domain)) {
+		return nil, s.send(TSRequest{ErrorCode: 0xC000006D})
+	}
+
+	sessionBaseKey := hmacMD5(responseKeyNT, ntProofStr)
+	exportedSessionKey := make([]byte, 16)
+	cipher, _ := rc4.NewCipher(sessionBaseKey)
+	cipher.XORKeyStream(exportedSessionKey, field(authenticate, 52))
+
+	withoutMIC := append([]byte{}, authenticate...)
+	copy(withoutMIC[72:88], make([]byte, 16))
+
+	if !bytes.Equal(authenticate[72:88], hmacMD5(exportedSessionKey, negotiate, challenge.Bytes(), withoutMIC)) {
+		return nil, errors.New("invalid MIC")
+	}
+
+	clientSigningKey := deriveKey(exportedSessionKey, clientSigningMagic)
+	serverSigningKey := deriveKey(exportedSessionKey, serverSigningMagic)
+	clientSealing, _ := rc4.NewCipher(deriveKey(exportedSessionKey, clientSealingMagic))
+	serverSealing, _ := rc4.NewCipher(deriveKey(exportedSessionKey, serverSealingMagic))
+
+	// mechListMIC does not change the state used for the first application message
+	micHandle := *clientSealing
+	if !bytes.Equal(authToken.MechListMIC, checksum(&micHandle, clientSigningKey, 0, mechTypeList())) {
+		return nil, errors.New("invalid mechListMIC")
+	}
+
+	pubKeyAuth := make([]byte, len(req.PubKeyAuth)-16)
+	clientSealing.XORKeyStream(pubKeyAuth, req.PubKeyAuth[16:])
+
+	if !bytes.Equal(req.PubKeyAuth[:16], checksum(clientSealing, clientSigningKey, 0, pubKeyAuth)) {
+		return nil, errors.New("invalid pubKeyAuth checksum")
+	}
+
+	var serverPubKeyAuth []byte
+
+	if s.version >= 5 {
+		expected := sha256.Sum256(append(append([]byte(clientServerHashMagic), req.ClientNonce...), s.publicKey...))
+		if !bytes.Equal(pubKeyAuth, expected[:]) {
+			return nil, errors.New("invalid client pubKeyAuth")
+		}
+
+		sum := sha256.Sum256(append(append([]byte(serverClientHashMagic), req.ClientNonce...), s.publicKey...))
+		serverPubKeyAuth = sum[:]
+	} else {
+		if !bytes.Equal(pubKeyAuth, s.publicKey) {
+			return nil, errors.New("invalid client pubKeyAuth")
+		}
+
+		serverPubKeyAuth = append([]byte{s.publicKey[0] + 1}, s.publicKey[1:]...)
+	}
+
+	micHandle = *serverSealing
+	token = NegTokenResp{
+		NegState:    NegStateAcceptCompleted,
+		MechListMIC: checksum(&micHandle, serverSigningKey, 0, mechTypeList()),
+	}
+
+	sealed := make([]byte, len(serverPubKeyAuth))
+	serverSealing.XORKeyStream(sealed, serverPubKeyAuth)
+
+	err = s.send(TSRequest{
+		NegoTokens: [][]byte{token.Serialize()},
+		PubKeyAuth: append(checksum(serverSealing, serverSigningKey, 0, serverPubKeyAuth), sealed...),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req = TSRequest{}
+	if err = req.Deserialize(s.conn); err != nil {
+		return nil, err
+	}
+
+	authInfo := make([]byte, len(req.AuthInfo)-16)
+	clientSealing.XORKeyStream(authInfo, req.AuthInfo[16:])
+
+	if !bytes.Equal(req.AuthInfo[:16], checksum(clientSealing, clientSigningKey, 1, authInfo)) {
+		return nil, errors.New("invalid authInfo checksum")
+	}
+
+	var credentials TSCredentials
+
+	return &credentials, credentials.Deserialize(bytes.NewReader(authInfo))
+}
+
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "SERVER"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func subjectPublicKey(t *testing.T, der []byte) []byte {
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+
+	_, err = asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki)
+	require.NoError(t, err)
+
+	return spki.PublicKey.Bytes
+}
+
+// authenticate runs CredSSP against the test responder and returns credentials received by it.
+func authenticate(t *testing.T, serverVersion int, credentials Credentials) (*TSCredentials, error) {
+	cert := testCertificate(t)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	responder := testResponder{
+		version:  serverVersion,
+		domain:   "DOMAIN",
+		username: "user",
+		password: "P@ssw0rd",
+		conn: tls.Server(serverConn, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}),
+		publicKey: subjectPublicKey(t, cert.Certificate[0]),
+	}
+
+	type result struct {
+		creds *TSCredentials
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		creds, err := responder.run()
+		done <- result{creds, err}
+	}()
+
+	tlsConn := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, tlsConn.Handshake())
+
+	publicKey := subjectPublicKey(t, tlsConn.ConnectionState().PeerCertificates[0].Raw)
+
+	err := New(tlsConn).Authenticate(credentials, publicKey)
+
+	res := <-done
+	require.NoError(t, res.err)
+
+	return res.creds, err
+}
+
+func TestProtocol_Authenticate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		serverVersion int
+		password      string
+		wantErr       error
+	}{
+		{name: "version 6", serverVersion: 6, password: "P@ssw0rd"},
+		{name: "version 3", serverVersion: 3, password: "P@ssw0rd"},
+		{name: "wrong password", serverVersion: 6, password: "wrong", wantErr: ServerError{Code: 0xC000006D}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			creds, err := authenticate(t, tc.serverVersion, Credentials{
+				Domain:   "DOMAIN",
+				Username: "user",
+				Password: tc.password,
+			})
+
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, credTypePassword, creds.CredType)
+
+			var passwordCreds TSPasswordCreds
+			require.NoError(t, passwordCreds.Deserialize(bytes.NewReader(creds.Credentials)))
+
+			require.Equal(t, utf16.Encode("DOMAIN"), passwordCreds.DomainName)
+			require.Equal(t, utf16.Encode("user"), passwordCreds.UserName)
+			require.Equal(t, utf16.Encode("P@ssw0rd"), passwordCreds.Password)
+		})
+	}
+}
+
+func TestProtocol_AuthenticateRestrictedAdmin(t *testing.T) {
+	creds, err := authenticate(t, 6, Credentials{
+		Domain:          "DOMAIN",
+		Username:        "user",
+		Password:        "P@ssw0rd",
+		RestrictedAdmin: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, credTypePassword, creds.CredType)
+
+	var passwordCreds TSPasswordCreds
+	require.NoError(t, passwordCreds.Deserialize(bytes.NewReader(creds.Credentials)))
+
+	require.Empty(t, passwordCreds.DomainName)
+	require.Empty(t, passwordCreds.UserName)
+	require.Empty(t, passwordCreds.Password)
+}
+
+func TestTSRequest_Deserialize(t *testing.T) {
+	req := TSRequest{
+		Version:     6,
+		NegoTokens:  [][]byte{{0x01, 0x02}, bytes.Repeat([]byte{0x03}, 300)},
+		PubKeyAuth:  []byte{0x04},
+		ErrorCode:   0xC000006D,
+		ClientNonce: bytes.Repeat([]byte{0x05}, 32),
+	}
+
+	var actual TSRequest
+
+	require.NoError(t, actual.Deserialize(bytes.NewReader(req.Serialize())))
+	require.Equal(t, req, actual)
+}
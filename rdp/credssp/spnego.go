@@ -0,0 +1,123 @@
+package credssp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/lunnik9/rdp/rdp/ber"
+)
+
+var (
+	spnegoOID  = []byte{0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}                         // 1.3.6.1.5.5.2
+	ntlmsspOID = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a} // 1.3.6.1.4.1.311.2.2.10
+)
+
+// NegState NegTokenResp negState.
+type NegState uint8
+
+const (
+	NegStateAcceptCompleted  NegState = 0
+	NegStateAcceptIncomplete NegState = 1
+	NegStateReject           NegState = 2
+	NegStateRequestMIC       NegState = 3
+)
+
+var ErrSPNEGOReject = errors.New("SPNEGO: mechanism rejected")
+
+// mechTypeList MechTypeList offering NTLMSSP only, also the input of mechListMIC.
+func mechTypeList() []byte {
+	oid := new(bytes.Buffer)
+	ber.WriteObjectIdentifier(ntlmsspOID, oid)
+
+	buf := new(bytes.Buffer)
+	ber.WriteSequence(oid.Bytes(), buf)
+
+	return buf.Bytes()
+}
+
+// negTokenInit wraps the mechToken into a GSS-API InitialContextToken carrying NegTokenInit.
+func negTokenInit(mechToken []byte) []byte {
+	fields := new(bytes.Buffer)
+	ber.WriteContextTag(0, mechTypeList(), fields)
+	writeContextOctetString(2, mechToken, fields)
+
+	negTokenInit := new(bytes.Buffer)
+	ber.WriteSequence(fields.Bytes(), negTokenInit)
+
+	token := new(bytes.Buffer)
+	ber.WriteObjectIdentifier(spnegoOID, token)
+	ber.WriteContextTag(0, negTokenInit.Bytes(), token)
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0x60) // [APPLICATION 0]
+	ber.WriteLength(token.Len(), buf)
+	buf.Write(token.Bytes())
+
+	return buf.Bytes()
+}
+
+// NegTokenResp NegTokenResp structure.
+type NegTokenResp struct {
+	NegState      NegState
+	ResponseToken []byte
+	MechListMIC   []byte
+}
+
+func (t *NegTokenResp) Serialize() []byte {
+	fields := new(bytes.Buffer)
+
+	if len(t.ResponseToken) > 0 {
+		writeContextOctetString(2, t.ResponseToken, fields)
+	}
+
+	if len(t.MechListMIC) > 0 {
+		writeContextOctetString(3, t.MechListMIC, fields)
+	}
+
+	negTokenResp := new(bytes.Buffer)
+	ber.WriteSequence(fields.Bytes(), negTokenResp)
+
+	buf := new(bytes.Buffer)
+	ber.WriteContextTag(1, negTokenResp.Bytes(), buf)
+
+	return buf.Bytes()
+}
+
+func (t *NegTokenResp) Deserialize(wire io.Reader) error {
+	tag, err := ber.ReadContextTag(wire)
+	if err != nil {
+		return err
+	}
+
+	if tag != 1 {
+		return errors.New("SPNEGO: NegTokenResp expected")
+	}
+
+	if _, err = ber.ReadLength(wire); err != nil {
+		return err
+	}
+
+	data, err := readSequence(wire)
+	if err != nil {
+		return err
+	}
+
+	return readContextFields(data, func(tag uint8, field io.Reader) error {
+		var err error
+
+		switch tag {
+		case 0:
+			var state uint8
+
+			state, err = ber.ReadEnumerated(field)
+			t.NegState = NegState(state)
+		case 2:
+			t.ResponseToken, err = ber.ReadOctetString(field)
+		case 3:
+			t.MechListMIC, err = ber.ReadOctetString(field)
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,266 @@
+package credssp
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lunnik9/rdp/rdp/ber"
+)
+
+// TSRequest TSRequest structure.
+type TSRequest struct {
+	Version     int
+	NegoTokens  [][]byte
+	AuthInfo    []byte
+	PubKeyAuth  []byte
+	ErrorCode   uint32
+	ClientNonce []byte
+}
+
+func (r *TSRequest) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	writeContextInteger(0, r.Version, buf)
+
+	if len(r.NegoTokens) > 0 {
+		tokens := new(bytes.Buffer)
+
+		for _, token := range r.NegoTokens {
+			negoToken := new(bytes.Buffer)
+			writeContextOctetString(0, token, negoToken)
+
+			ber.WriteSequence(negoToken.Bytes(), tokens)
+		}
+
+		negoData := new(bytes.Buffer)
+		ber.WriteSequence(tokens.Bytes(), negoData)
+
+		ber.WriteContextTag(1, negoData.Bytes(), buf)
+	}
+
+	if len(r.AuthInfo) > 0 {
+		writeContextOctetString(2, r.AuthInfo, buf)
+	}
+
+	if len(r.PubKeyAuth) > 0 {
+		writeContextOctetString(3, r.PubKeyAuth, buf)
+	}
+
+	if r.ErrorCode != 0 {
+		writeContextInteger(4, int(r.ErrorCode), buf)
+	}
+
+	if len(r.ClientNonce) > 0 {
+		writeContextOctetString(5, r.ClientNonce, buf)
+	}
+
+	out := new(bytes.Buffer)
+	ber.WriteSequence(buf.Bytes(), out)
+
+	return out.Bytes()
+}
+
+func (r *TSRequest) Deserialize(wire io.Reader) error {
+	data, err := readSequence(wire)
+	if err != nil {
+		return err
+	}
+
+	return readContextFields(data, func(tag uint8, field io.Reader) error {
+		var err error
+
+		switch tag {
+		case 0:
+			r.Version, err = ber.ReadInteger(field)
+		case 1:
+			r.NegoTokens, err = readNegoData(field)
+		case 2:
+			r.AuthInfo, err = ber.ReadOctetString(field)
+		case 3:
+			r.PubKeyAuth, err = ber.ReadOctetString(field)
+		case 4:
+			var errorCode int
+
+			errorCode, err = ber.ReadInteger(field)
+			r.ErrorCode = uint32(errorCode)
+		case 5:
+			r.ClientNonce, err = ber.ReadOctetString(field)
+		}
+
+		return err
+	})
+}
+
+func readNegoData(wire io.Reader) ([][]byte, error) {
+	data, err := readSequence(wire)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tokens [][]byte
+		r      = bytes.NewReader(data)
+	)
+
+	for r.Len() > 0 {
+		negoToken, err := readSequence(r)
+		if err != nil {
+			return nil, err
+		}
+
+		err = readContextFields(negoToken, func(tag uint8, field io.Reader) error {
+			if tag != 0 {
+				return nil
+			}
+
+			token, err := ber.ReadOctetString(field)
+			tokens = append(tokens, token)
+
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+// TSCredentials TSCredentials structure.
+type TSCredentials struct {
+	CredType    int
+	Credentials []byte
+}
+
+func (c *TSCredentials) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	writeContextInteger(0, c.CredType, buf)
+	writeContextOctetString(1, c.Credentials, buf)
+
+	out := new(bytes.Buffer)
+	ber.WriteSequence(buf.Bytes(), out)
+
+	return out.Bytes()
+}
+
+func (c *TSCredentials) Deserialize(wire io.Reader) error {
+	data, err := readSequence(wire)
+	if err != nil {
+		return err
+	}
+
+	return readContextFields(data, func(tag uint8, field io.Reader) error {
+		var err error
+
+		switch tag {
+		case 0:
+			c.CredType, err = ber.ReadInteger(field)
+		case 1:
+			c.Credentials, err = ber.ReadOctetString(field)
+		}
+
+		return err
+	})
+}
+
+// TSPasswordCreds TSPasswordCreds structure, strings are sent in UTF-16LE.
+type TSPasswordCreds struct {
+	DomainName []byte
+	UserName   []byte
+	Password   []byte
+}
+
+func (c *TSPasswordCreds) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	writeContextOctetString(0, c.DomainName, buf)
+	writeContextOctetString(1, c.UserName, buf)
+	writeContextOctetString(2, c.Password, buf)
+
+	out := new(bytes.Buffer)
+	ber.WriteSequence(buf.Bytes(), out)
+
+	return out.Bytes()
+}
+
+func (c *TSPasswordCreds) Deserialize(wire io.Reader) error {
+	data, err := readSequence(wire)
+	if err != nil {
+		return err
+	}
+
+	return readContextFields(data, func(tag uint8, field io.Reader) error {
+		var err error
+
+		switch tag {
+		case 0:
+			c.DomainName, err = ber.ReadOctetString(field)
+		case 1:
+			c.UserName, err = ber.ReadOctetString(field)
+		case 2:
+			c.Password, err = ber.ReadOctetString(field)
+		}
+
+		return err
+	})
+}
+
+func writeContextInteger(tag uint8, n int, w io.Writer) {
+	buf := new(bytes.Buffer)
+	ber.WriteInteger(n, buf)
+
+	ber.WriteContextTag(tag, buf.Bytes(), w)
+}
+
+func writeContextOctetString(tag uint8, data []byte, w io.Writer) {
+	buf := new(bytes.Buffer)
+	ber.WriteOctetString(data, buf)
+
+	ber.WriteContextTag(tag, buf.Bytes(), w)
+}
+
+func readSequence(wire io.Reader) ([]byte, error) {
+	length, err := ber.ReadSequence(wire)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+
+	if _, err = io.ReadFull(wire, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// readContextFields walks the explicitly tagged fields of a sequence
+// calling fn for every one of them; unknown fields are skipped by fn.
+func readContextFields(data []byte, fn func(tag uint8, field io.Reader) error) error {
+	r := bytes.NewReader(data)
+
+	for r.Len() > 0 {
+		tag, err := ber.ReadContextTag(r)
+		if err != nil {
+			return err
+		}
+
+		length, err := ber.ReadLength(r)
+		if err != nil {
+			return err
+		}
+
+		field := make([]byte, length)
+
+		if _, err = io.ReadFull(r, field); err != nil {
+			return err
+		}
+
+		if err = fn(tag, bytes.NewReader(field)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+// Package dialctx cancels the dials and the handshakes over the dialed connections with the context.
+package dialctx
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Dialer opens connections without the context.
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// ContextDialer opens connections canceled by the context, *net.Dialer is a context dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Dial dials address with ctx when the dialer is a context dialer, the other dialers are not canceled.
+func Dial(ctx context.Context, dialer Dialer, network, address string) (net.Conn, error) {
+	if contextDialer, ok := dialer.(ContextDialer); ok {
+		return contextDialer.DialContext(ctx, network, address)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return dialer.Dial(network, address)
+}
+
+// Deadline returns the earlier of the timeout and the deadline of ctx.
+func Deadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+
+	return deadline
+}
+
+// InterruptOnDone sets the deadline in the past when ctx is done, it unblocks reads and writes of the connection
+// until stop is called.
+func InterruptOnDone(ctx context.Context, conn net.Conn) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package rdp
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Dialer opens connections to RDP servers, *net.Dialer and gateway.Dialer are dialers.
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// ContextDialer is the dialer canceled by the context of Connect, *net.Dialer is a context dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Option configures the client created by New.
+type Option func(c *Client)
+
+// WithDialer sets the dialer of the server connections, TCP with a timeout by default.
+func WithDialer(dialer Dialer) Option {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
+
+// WithConn sets the connection to the server used by the first Connect, the connections
+// following server redirection or auto-reconnect are opened by the dialer.
+func WithConn(conn net.Conn) Option {
+	return func(c *Client) {
+		c.initialConn = conn
+	}
+}
+
+// WithRoutingToken sets the opaque routing token sent in the X.224 Connection Request
+// instead of the mstshash cookie, e.g. the load balance info received from a session broker.
+func WithRoutingToken(routingToken []byte) Option {
+	return func(c *Client) {
+		c.routingToken = routingToken
+	}
+}
+
+// WithCookie sets the mstshash cookie sent in the X.224 Connection Request, by default the user name
+// without the domain truncated to 9 characters.
+// The empty cookie is not sent.
+func WithCookie(cookie string) Option {
+	return func(c *Client) {
+		c.cookie = cookie
+	}
+}
+
+// WithLicenseStore sets the store of client access licenses, issued licenses are not kept by default.
+func WithLicenseStore(store LicenseStore) Option {
+	return func(c *Client) {
+		c.licenseStore = store
+	}
+}
+
+// WithPhaseTimeout sets the timeout of every phase of the connection sequence, 15 seconds by default,
+// zero leaves only the deadline of the Connect context.
+func WithPhaseTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.phaseTimeout = timeout
+	}
+}
@@ -0,0 +1,80 @@
+package rdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	Hostname:      "rdp.example.com:3389",
+	Username:      "user",
+	Password:      "password",
+	DesktopWidth:  1024,
+	DesktopHeight: 768,
+}
+
+type testDialer struct {
+	addresses []string
+	conns     []net.Conn
+}
+
+func (d *testDialer) Dial(network, address string) (net.Conn, error) {
+	d.addresses = append(d.addresses, network+" "+address)
+
+	conn, _ := net.Pipe()
+	d.conns = append(d.conns, conn)
+
+	return conn, nil
+}
+
+func TestNewClient_WithConn(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+
+	go func() {
+		defer serverConn.Close()
+
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(serverConn, header); err != nil {
+			return
+		}
+
+		request := make([]byte, binary.BigEndian.Uint16(header[2:])-4)
+		if _, err := io.ReadFull(serverConn, request); err != nil || !bytes.Contains(request, []byte("mstshash=user")) {
+			return
+		}
+
+		_, _ = serverConn.Write([]byte{
+			0x03, 0x00, 0x00, 0x13, // TPKT
+			0x0e, 0xd0, 0x00, 0x00, 0x12, 0x34, 0x00, // X.224 Connection Confirm
+			0x03, 0x00, 0x08, 0x00, 0x05, 0x00, 0x00, 0x00, // RDP_NEG_FAILURE HYBRID_REQUIRED_BY_SERVER
+		})
+	}()
+
+	dialer := testDialer{}
+
+	c, err := New(testConfig, WithConn(clientConn), WithDialer(&dialer))
+	require.NoError(t, err)
+
+	require.ErrorContains(t, c.Connect(context.Background()), "failureCode = 5")
+	require.Empty(t, dialer.addresses)
+
+	require.NoError(t, c.dial(context.Background())) // the connection after redirection or auto-reconnect
+	require.Equal(t, []string{"tcp rdp.example.com:3389"}, dialer.addresses)
+	require.Equal(t, dialer.conns[0], c.conn)
+
+	require.NoError(t, c.Close())
+}
+
+func TestNewClient_NotConnected(t *testing.T) {
+	c, err := New(testConfig)
+	require.NoError(t, err)
+
+	require.Nil(t, c.conn)
+	require.NoError(t, c.Close())
+}
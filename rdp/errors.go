@@ -4,4 +4,15 @@ import "errors"
 
 var (
 	ErrUnsupportedRequestedProtocol = errors.New("unsupported requested protocol")
+	ErrAccessDenied                 = errors.New("early user authorization: access denied")
+	ErrCertificateNotTrusted        = errors.New("server certificate is not trusted")
+	ErrCertificatePinMismatch       = errors.New("server certificate does not match pinned fingerprints")
+	ErrCertificateChanged           = errors.New("server certificate differs from the trusted one")
+	ErrNLARequired                  = errors.New("restricted admin mode requires NLA")
+	ErrRestrictedAdminNotSupported  = errors.New("server does not support restricted admin mode")
+	ErrTooManyRedirections          = errors.New("too many server redirections")
+	ErrInvalidLicensingMAC          = errors.New("invalid licensing MAC")
+	ErrUnexpectedLicensingMessage   = errors.New("unexpected licensing message")
+	ErrPhaseTimeout                 = errors.New("connection phase timed out")
+	ErrClosed                       = errors.New("client closed")
 )
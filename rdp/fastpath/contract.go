@@ -0,0 +1,8 @@
+package fastpath
+
+// cipher encrypts fastpath data under Standard RDP Security.
+type cipher interface {
+	Encrypt(data []byte) []byte
+	Decrypt(data []byte, saltedChecksum bool) ([]byte, error)
+	SaltedChecksum() bool
+}
@@ -5,7 +5,8 @@ import (
 )
 
 type Protocol struct {
-	conn io.ReadWriter
+	conn   io.ReadWriter
+	cipher cipher
 
 	updatePDUData []byte
 }
@@ -17,3 +18,8 @@ func New(conn io.ReadWriter) *Protocol {
 		updatePDUData: make([]byte, 64*1024),
 	}
 }
+
+// SetCipher enables encryption of fastpath input and decryption of fastpath output.
+func (i *Protocol) SetCipher(cipher cipher) {
+	i.cipher = cipher
+}
@@ -0,0 +1,86 @@
+package fastpath
+
+import (
+	"errors"
+)
+
+var (
+	ErrUpdateTooLarge      = errors.New("reassembled update exceeds the max request size")
+	ErrUnexpectedFragment  = errors.New("unexpected update fragment")
+	ErrCompressedFragments = errors.New("bulk compressed update fragments")
+)
+
+// Reassembler joins the fragments of the updates sent by FASTPATH_FRAGMENT_FIRST, FASTPATH_FRAGMENT_NEXT
+// and FASTPATH_FRAGMENT_LAST, up to the MaxRequestSize of the Multifragment Update Capability Set.
+type Reassembler struct {
+	maxRequestSize int
+
+	fragments *Update // the update being reassembled
+}
+
+func NewReassembler(maxRequestSize uint32) *Reassembler {
+	return &Reassembler{
+		maxRequestSize: int(maxRequestSize),
+	}
+}
+
+// Reassemble returns the complete updates: the single ones as they are, the fragmented ones
+// decoded once the last fragment arrives.
+func (r *Reassembler) Reassemble(updates []Update) ([]Update, error) {
+	complete := make([]Update, 0, len(updates))
+
+	for _, update := range updates {
+		if update.Fragmentation == FragmentSingle {
+			if r.fragments != nil {
+				return nil, ErrUnexpectedFragment
+			}
+
+			complete = append(complete, update)
+
+			continue
+		}
+
+		if update.Compression&CompressionUsed == CompressionUsed {
+			return nil, ErrCompressedFragments // each fragment is decompressed before joining
+		}
+
+		switch {
+		case update.Fragmentation == FragmentFirst && r.fragments == nil:
+			r.fragments = &Update{UpdateCode: update.UpdateCode}
+		case update.Fragmentation != FragmentFirst && r.fragments != nil && r.fragments.UpdateCode == update.UpdateCode:
+			// pass
+		default:
+			r.Reset()
+
+			return nil, ErrUnexpectedFragment
+		}
+
+		if len(r.fragments.Data)+len(update.Data) > r.maxRequestSize {
+			r.Reset()
+
+			return nil, ErrUpdateTooLarge
+		}
+
+		r.fragments.Data = append(r.fragments.Data, update.Data...)
+
+		if update.Fragmentation != FragmentLast {
+			continue
+		}
+
+		reassembled := r.fragments
+		r.fragments = nil
+
+		if err := reassembled.decode(); err != nil {
+			return nil, err
+		}
+
+		complete = append(complete, *reassembled)
+	}
+
+	return complete, nil
+}
+
+// Reset drops the fragments received so far, e.g. of the lost connection.
+func (r *Reassembler) Reset() {
+	r.fragments = nil
+}
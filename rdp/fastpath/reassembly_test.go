@@ -0,0 +1,175 @@
+package fastpath
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fragment splits the update data into the fragments of the given size.
+func fragment(update Update, size int) []Update {
+	data := update.Serialize()[3:] // updateHeader and size
+
+	var fragments []Update
+
+	for offset := 0; offset < len(data); offset += size {
+		end := offset + size
+		if end > len(data) {
+			end = len(data)
+		}
+
+		fragments = append(fragments, Update{
+			UpdateCode:    update.UpdateCode,
+			Fragmentation: FragmentNext,
+			Data:          data[offset:end],
+		})
+	}
+
+	fragments[0].Fragmentation = FragmentFirst
+	fragments[len(fragments)-1].Fragmentation = FragmentLast
+
+	return fragments
+}
+
+func TestReassembler_Reassemble(t *testing.T) {
+	bitmap := Update{UpdateCode: UpdateCodeBitmap, Bitmap: &BitmapUpdateData{Rectangles: []BitmapData{
+		{Width: 4, Height: 4, BitsPerPixel: 8, BitmapLength: 16, BitmapDataStream: []byte("0123456789abcdef")},
+		{DestLeft: 4, Width: 2, Height: 1, BitsPerPixel: 16, BitmapLength: 4, BitmapDataStream: []byte{1, 2, 3, 4}},
+	}}}
+	bitmap.Data = bitmap.Serialize()[3:]
+
+	pointer := Update{UpdateCode: UpdateCodePTRPosition, PointerPosition: &PointerPositionUpdateData{XPos: 1, YPos: 2}}
+	pointer.Data = pointer.Serialize()[3:]
+
+	fragments := fragment(bitmap, 10)
+	require.Greater(t, len(fragments), 3)
+
+	r := NewReassembler(uint32(len(bitmap.Data)))
+
+	// the fragments of the update PDUs received one by one
+	updates, err := r.Reassemble([]Update{pointer, fragments[0]})
+	require.NoError(t, err)
+	require.Equal(t, []Update{pointer}, updates)
+
+	for _, update := range fragments[1 : len(fragments)-1] {
+		updates, err = r.Reassemble([]Update{update})
+		require.NoError(t, err)
+		require.Empty(t, updates)
+	}
+
+	updates, err = r.Reassemble([]Update{fragments[len(fragments)-1], pointer})
+	require.NoError(t, err)
+	require.Equal(t, []Update{bitmap, pointer}, updates)
+
+	// all the fragments in a single update PDU
+	updates, err = r.Reassemble(fragment(bitmap, 7))
+	require.NoError(t, err)
+	require.Equal(t, []Update{bitmap}, updates)
+}
+
+func TestReassembler_ReassembleErrors(t *testing.T) {
+	bitmap := Update{UpdateCode: UpdateCodeBitmap, Bitmap: &BitmapUpdateData{Rectangles: []BitmapData{
+		{Width: 4, Height: 4, BitsPerPixel: 8, BitmapLength: 16, BitmapDataStream: []byte("0123456789abcdef")},
+	}}}
+	fragments := fragment(bitmap, 8)
+	size := len(bitmap.Serialize()) - 3
+
+	single := Update{UpdateCode: UpdateCodeSynchronize, Data: []byte{}}
+	orders := fragments[1]
+	orders.UpdateCode = UpdateCodeOrders
+	compressed := fragments[0]
+	compressed.Compression = CompressionUsed
+
+	testCases := []struct {
+		name           string
+		maxRequestSize int
+		updates        []Update
+		expectedErr    error
+	}{
+		{
+			name:           "exceeds max request size",
+			maxRequestSize: size - 1,
+			updates:        fragments,
+			expectedErr:    ErrUpdateTooLarge,
+		},
+		{
+			name:           "next without first",
+			maxRequestSize: size,
+			updates:        fragments[1:],
+			expectedErr:    ErrUnexpectedFragment,
+		},
+		{
+			name:           "last without first",
+			maxRequestSize: size,
+			updates:        fragments[len(fragments)-1:],
+			expectedErr:    ErrUnexpectedFragment,
+		},
+		{
+			name:           "first during reassembly",
+			maxRequestSize: size,
+			updates:        []Update{fragments[0], fragments[0]},
+			expectedErr:    ErrUnexpectedFragment,
+		},
+		{
+			name:           "single during reassembly",
+			maxRequestSize: size,
+			updates:        []Update{fragments[0], single},
+			expectedErr:    ErrUnexpectedFragment,
+		},
+		{
+			name:           "update code mismatch",
+			maxRequestSize: size,
+			updates:        []Update{fragments[0], orders},
+			expectedErr:    ErrUnexpectedFragment,
+		},
+		{
+			name:           "compressed",
+			maxRequestSize: size,
+			updates:        []Update{compressed},
+			expectedErr:    ErrCompressedFragments,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewReassembler(uint32(tc.maxRequestSize))
+
+			_, err := r.Reassemble(tc.updates)
+			require.ErrorIs(t, err, tc.expectedErr)
+
+			// the reassembler recovers on the next update
+			r.Reset()
+
+			updates, err := r.Reassemble(fragments)
+			if tc.maxRequestSize < size {
+				require.ErrorIs(t, err, ErrUpdateTooLarge)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, updates, 1)
+			require.Equal(t, bitmap.Bitmap, updates[0].Bitmap)
+		})
+	}
+}
+
+func TestReassembler_MaxRequestSizeLimit(t *testing.T) {
+	orders := Update{UpdateCode: UpdateCodeOrders, Data: bytes.Repeat([]byte{0xaa}, 0xFFFF)}
+
+	r := NewReassembler(0xFFFF)
+
+	updates, err := r.Reassemble(fragment(orders, 0x4000))
+	require.NoError(t, err)
+	require.Equal(t, []Update{orders}, updates)
+
+	wire := updates[0].Serialize()
+	require.Equal(t, []byte{0x00, 0xff, 0xff}, wire[:3]) // updateHeader and size
+	require.Len(t, wire, 3+0xFFFF)
+
+	orders.Data = append(orders.Data, 0xaa)
+
+	_, err = r.Reassemble(fragment(orders, 0x4000))
+	require.ErrorIs(t, err, ErrUpdateTooLarge)
+}
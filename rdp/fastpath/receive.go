@@ -1,8 +1,10 @@
 package fastpath
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -38,17 +40,72 @@ const (
 	CompressionUsed Compression = 0x2
 )
 
+// Update Fast-Path Update (TS_FP_UPDATE). The updateData of the single unfragmented updates without bulk
+// compression is decoded into the field of the update code, Data always keeps it.
 type Update struct {
 	UpdateCode       UpdateCode
-	fragmentation    Fragment
-	compression      Compression
-	compressionFlags uint8
-	size             uint16
-
-	paletteUpdateData         *paletteUpdateData
-	bitmapUpdateData          *bitmapUpdateData
-	pointerPositionUpdateData *pointerPositionUpdateData
-	colorPointerUpdateData    *colorPointerUpdateData
+	Fragmentation    Fragment
+	Compression      Compression
+	CompressionFlags uint8
+	Data             []byte
+
+	Palette         *PaletteUpdateData
+	Bitmap          *BitmapUpdateData
+	Synchronize     *SynchronizeUpdateData
+	PointerPosition *PointerPositionUpdateData
+	SystemPointer   *SystemPointerUpdateData
+	ColorPointer    *ColorPointerUpdateData
+	NewPointer      *NewPointerUpdateData
+	CachedPointer   *CachedPointerUpdateData
+	LargePointer    *LargePointerUpdateData
+	SurfaceCommands []SurfaceCommand
+}
+
+// Serialize writes the typed update data, or Data when there is none.
+func (u *Update) Serialize() []byte {
+	data := u.Data
+
+	switch {
+	case u.Palette != nil:
+		data = u.Palette.Serialize()
+	case u.Bitmap != nil:
+		data = u.Bitmap.Serialize()
+	case u.Synchronize != nil:
+		data = nil
+	case u.PointerPosition != nil:
+		data = u.PointerPosition.Serialize()
+	case u.SystemPointer != nil:
+		data = nil
+	case u.ColorPointer != nil:
+		data = u.ColorPointer.Serialize()
+	case u.NewPointer != nil:
+		data = u.NewPointer.Serialize()
+	case u.CachedPointer != nil:
+		data = u.CachedPointer.Serialize()
+	case u.LargePointer != nil:
+		data = u.LargePointer.Serialize()
+	case u.SurfaceCommands != nil:
+		buf := new(bytes.Buffer)
+
+		for i := range u.SurfaceCommands {
+			buf.Write(u.SurfaceCommands[i].Serialize())
+		}
+
+		data = buf.Bytes()
+	}
+
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(uint8(u.UpdateCode)&0xf | uint8(u.Fragmentation)&0x3<<4 | uint8(u.Compression)&0x3<<6)
+
+	if u.Compression&CompressionUsed == CompressionUsed {
+		buf.WriteByte(u.CompressionFlags)
+	}
+
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(data)))
+	buf.Write(data)
+
+	return buf.Bytes()
 }
 
 func (u *Update) Deserialize(wire io.Reader) error {
@@ -61,49 +118,88 @@ func (u *Update) Deserialize(wire io.Reader) error {
 	}
 
 	u.UpdateCode = UpdateCode(updateHeader & 0xf)
-	u.fragmentation = Fragment((updateHeader >> 4) & 0x3)
-	u.compression = Compression((updateHeader >> 6) & 0x3)
+	u.Fragmentation = Fragment((updateHeader >> 4) & 0x3)
+	u.Compression = Compression((updateHeader >> 6) & 0x3)
 
-	if u.compression&CompressionUsed == CompressionUsed {
-		err = binary.Read(wire, binary.LittleEndian, &u.compressionFlags)
+	if u.Compression&CompressionUsed == CompressionUsed {
+		err = binary.Read(wire, binary.LittleEndian, &u.CompressionFlags)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &u.size)
+	var size uint16
+	err = binary.Read(wire, binary.LittleEndian, &size)
 	if err != nil {
 		return err
 	}
 
-	//switch u.UpdateCode {
-	//case UpdateCodePalette:
-	//	u.paletteUpdateData = &paletteUpdateData{}
-	//
-	//	return u.paletteUpdateData.Deserialize(wire)
-	//case UpdateCodeBitmap:
-	//	u.bitmapUpdateData = &bitmapUpdateData{}
-	//
-	//	return u.bitmapUpdateData.Deserialize(wire)
-	//case UpdateCodeSynchronize: // do nothing
-	//case UpdateCodePTRPosition:
-	//	u.pointerPositionUpdateData = &pointerPositionUpdateData{}
-	//
-	//	return u.pointerPositionUpdateData.Deserialize(wire)
-	//case UpdateCodePTRNull: // do nothing
-	//case UpdateCodePTRDefault: // do nothing
-	//case UpdateCodeCached:
-	//	u.colorPointerUpdateData = &colorPointerUpdateData{}
-	//
-	//	return u.colorPointerUpdateData.Deserialize(wire)
-	//}
-
-	d := make([]byte, u.size)
-	_, err = wire.Read(d)
+	u.Data = make([]byte, size)
+	_, err = io.ReadFull(wire, u.Data)
 	if err != nil {
 		return err
 	}
 
+	if u.Fragmentation != FragmentSingle || u.Compression&CompressionUsed == CompressionUsed {
+		return nil // the fragments are reassembled and decompressed first
+	}
+
+	return u.decode()
+}
+
+// decode parses Data into the type of the update code.
+func (u *Update) decode() error {
+	wire := bytes.NewReader(u.Data)
+
+	switch u.UpdateCode {
+	case UpdateCodePalette:
+		u.Palette = &PaletteUpdateData{}
+
+		return u.Palette.Deserialize(wire)
+	case UpdateCodeBitmap:
+		u.Bitmap = &BitmapUpdateData{}
+
+		return u.Bitmap.Deserialize(wire)
+	case UpdateCodeSynchronize:
+		u.Synchronize = &SynchronizeUpdateData{}
+	case UpdateCodeSurfCMDs:
+		u.SurfaceCommands = []SurfaceCommand{}
+
+		for wire.Len() > 0 {
+			var command SurfaceCommand
+			if err := command.Deserialize(wire); err != nil {
+				return err
+			}
+
+			u.SurfaceCommands = append(u.SurfaceCommands, command)
+		}
+	case UpdateCodePTRNull:
+		u.SystemPointer = &SystemPointerUpdateData{SystemPointerType: SystemPointerNull}
+	case UpdateCodePTRDefault:
+		u.SystemPointer = &SystemPointerUpdateData{SystemPointerType: SystemPointerDefault}
+	case UpdateCodePTRPosition:
+		u.PointerPosition = &PointerPositionUpdateData{}
+
+		return u.PointerPosition.Deserialize(wire)
+	case UpdateCodeColor:
+		u.ColorPointer = &ColorPointerUpdateData{}
+
+		return u.ColorPointer.Deserialize(wire)
+	case UpdateCodeCached:
+		u.CachedPointer = &CachedPointerUpdateData{}
+
+		return u.CachedPointer.Deserialize(wire)
+	case UpdateCodePointer:
+		u.NewPointer = &NewPointerUpdateData{}
+
+		return u.NewPointer.Deserialize(wire)
+	case UpdateCodeLargePointer:
+		u.LargePointer = &LargePointerUpdateData{}
+
+		return u.LargePointer.Deserialize(wire)
+	}
+
+	// orders are kept as Data
 	return nil
 }
 
@@ -128,7 +224,10 @@ type UpdatePDU struct {
 	Data           []byte
 }
 
-var ErrUnexpectedX224 = errors.New("unexpected x224")
+var (
+	ErrUnexpectedX224         = errors.New("unexpected x224")
+	ErrEncryptedWithoutCipher = errors.New("encrypted update without negotiated encryption")
+)
 
 func (pdu *UpdatePDU) Deserialize(wire io.Reader) error {
 	err := binary.Read(wire, binary.LittleEndian, &pdu.fpOutputHeader)
@@ -143,17 +242,10 @@ func (pdu *UpdatePDU) Deserialize(wire io.Reader) error {
 		return ErrUnexpectedX224
 	}
 
-	if pdu.Flags&UpdatePDUFlagSecureChecksum == UpdatePDUFlagSecureChecksum {
-		return errors.New("checksum not supported")
-	}
-
-	if pdu.Flags&UpdatePDUFlagEncrypted == UpdatePDUFlagEncrypted {
-		return errors.New("encryption not supported")
-	}
-
 	var (
 		length           uint16
 		length1, length2 uint8
+		headerLen        uint16 = 2 // fpOutputHeader and length1
 	)
 
 	err = binary.Read(wire, binary.LittleEndian, &length1)
@@ -170,21 +262,24 @@ func (pdu *UpdatePDU) Deserialize(wire io.Reader) error {
 		}
 
 		length1 -= 0x80
+		headerLen++
 
 		length = binary.BigEndian.Uint16([]byte{length1, length2})
 	}
 
-	if length > 0x4000 {
-		return errors.New("too big packet")
+	if length < headerLen {
+		return errors.New("wrong packet length")
 	}
 
+	length -= headerLen // length includes the header
+
 	if len(pdu.Data) != 0 {
 		pdu.Data = pdu.Data[:length]
 	} else {
 		pdu.Data = make([]byte, length)
 	}
 
-	_, err = wire.Read(pdu.Data)
+	_, err = io.ReadFull(wire, pdu.Data)
 	if err != nil {
 		return err
 	}
@@ -192,6 +287,24 @@ func (pdu *UpdatePDU) Deserialize(wire io.Reader) error {
 	return nil
 }
 
+// Updates splits fastpath update PDU into its updates and decodes them, the updates don't share memory with Data.
+func (pdu *UpdatePDU) Updates() ([]Update, error) {
+	wire := bytes.NewReader(pdu.Data)
+
+	var updates []Update
+
+	for wire.Len() > 0 {
+		var update Update
+		if err := update.Deserialize(wire); err != nil {
+			return nil, fmt.Errorf("update %d: %w", len(updates), err)
+		}
+
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
+
 func (i *Protocol) Receive() (*UpdatePDU, error) {
 	var pdu UpdatePDU
 	pdu.Data = i.updatePDUData
@@ -199,5 +312,18 @@ func (i *Protocol) Receive() (*UpdatePDU, error) {
 		return nil, err
 	}
 
+	if pdu.Flags&UpdatePDUFlagEncrypted == UpdatePDUFlagEncrypted {
+		if i.cipher == nil {
+			return nil, ErrEncryptedWithoutCipher
+		}
+
+		data, err := i.cipher.Decrypt(pdu.Data, pdu.Flags&UpdatePDUFlagSecureChecksum == UpdatePDUFlagSecureChecksum)
+		if err != nil {
+			return nil, err
+		}
+
+		pdu.Data = data
+	}
+
 	return &pdu, nil
 }
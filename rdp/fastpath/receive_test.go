@@ -0,0 +1,130 @@
+package fastpath
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type xorCipher struct{}
+
+func (xorCipher) Encrypt(data []byte) []byte {
+	output := make([]byte, 8+len(data))
+	for i, b := range data {
+		output[8+i] = b ^ 0xff
+	}
+
+	return output
+}
+
+func (c xorCipher) Decrypt(data []byte, _ bool) ([]byte, error) {
+	return c.Encrypt(data[8:])[8:], nil
+}
+
+func (xorCipher) SaltedChecksum() bool {
+	return true
+}
+
+func TestUpdatePDU_Deserialize(t *testing.T) {
+	var actual UpdatePDU
+
+	wire := bytes.NewBuffer([]byte{0x00, 0x05, 0x01, 0x02, 0x03, 0x00, 0x04})
+
+	require.NoError(t, actual.Deserialize(wire))
+	require.Equal(t, []byte{0x01, 0x02, 0x03}, actual.Data)
+	require.Equal(t, []byte{0x00, 0x04}, wire.Bytes())
+}
+
+func TestUpdatePDU_DeserializeLarge(t *testing.T) {
+	var actual UpdatePDU
+
+	data := bytes.Repeat([]byte{0xaa}, 0x7fff-3) // fragments of the updates up to MaxRequestSize
+
+	wire := bytes.NewBuffer(append([]byte{0x00, 0xff, 0xff}, data...))
+
+	require.NoError(t, actual.Deserialize(wire))
+	require.Equal(t, data, actual.Data)
+}
+
+func TestProtocol_Encrypted(t *testing.T) {
+	conn := new(bytes.Buffer)
+
+	protocol := New(conn)
+	protocol.SetCipher(xorCipher{})
+
+	require.NoError(t, protocol.Send(NewInputEventPDU([]byte{0x01, 0x02})))
+	require.Equal(t, []byte{0xc4, 0x0c, 0, 0, 0, 0, 0, 0, 0, 0, 0xfe, 0xfd}, conn.Bytes())
+
+	conn.Reset()
+	conn.Write([]byte{0x80, 0x0c, 0, 0, 0, 0, 0, 0, 0, 0, 0xfe, 0xfd})
+
+	update, err := protocol.Receive()
+	require.NoError(t, err)
+	require.Equal(t, UpdatePDUFlagEncrypted, update.Flags)
+	require.Equal(t, []byte{0x01, 0x02}, update.Data)
+}
+
+func TestInputEventPDU_SerializeLength(t *testing.T) {
+	event := NewInputEventPDU(make([]byte, 0x80))
+
+	actual := event.Serialize()
+
+	require.Equal(t, []byte{0x04, 0x80, 0x83}, actual[:3])
+	require.Len(t, actual, 0x83)
+}
+
+func TestUpdatePDU_Updates(t *testing.T) {
+	typed := []Update{
+		{UpdateCode: UpdateCodePalette, Palette: &PaletteUpdateData{PaletteEntries: []PaletteEntry{{Red: 1, Green: 2, Blue: 3}}}},
+		{UpdateCode: UpdateCodeBitmap, Bitmap: &BitmapUpdateData{Rectangles: []BitmapData{
+			{Width: 1, Height: 1, BitsPerPixel: 8, BitmapLength: 1, BitmapDataStream: []byte{0x01}},
+		}}},
+		{UpdateCode: UpdateCodePTRPosition, PointerPosition: &PointerPositionUpdateData{XPos: 10, YPos: 20}},
+		{UpdateCode: UpdateCodePTRNull, SystemPointer: &SystemPointerUpdateData{SystemPointerType: SystemPointerNull}},
+		{UpdateCode: UpdateCodePTRDefault, SystemPointer: &SystemPointerUpdateData{SystemPointerType: SystemPointerDefault}},
+		{UpdateCode: UpdateCodeColor, ColorPointer: &ColorPointerUpdateData{Width: 1, Height: 1, XorMaskData: []byte{1, 2, 3, 0}, AndMaskData: []byte{0, 0}}},
+		{UpdateCode: UpdateCodeCached, CachedPointer: &CachedPointerUpdateData{CacheIndex: 4}},
+		{UpdateCode: UpdateCodePointer, NewPointer: &NewPointerUpdateData{XorBpp: 24, ColorPointerData: ColorPointerUpdateData{XorMaskData: []byte{}, AndMaskData: []byte{}}}},
+		{UpdateCode: UpdateCodeLargePointer, LargePointer: &LargePointerUpdateData{XorBpp: 32, XorMaskData: []byte{1, 2, 3, 4}, AndMaskData: []byte{0x80}}},
+		{UpdateCode: UpdateCodeSynchronize, Synchronize: &SynchronizeUpdateData{}},
+		{UpdateCode: UpdateCodeSurfCMDs, SurfaceCommands: []SurfaceCommand{
+			{CmdType: SurfaceCommandTypeFrameMarker, FrameMarker: &FrameMarkerCommand{FrameAction: FrameActionBegin, FrameID: 1}},
+			{CmdType: SurfaceCommandTypeFrameMarker, FrameMarker: &FrameMarkerCommand{FrameAction: FrameActionEnd, FrameID: 1}},
+		}},
+		{UpdateCode: UpdateCodeSurfCMDs, SurfaceCommands: []SurfaceCommand{
+			{CmdType: SurfaceCommandTypeFrameMarker, FrameMarker: &FrameMarkerCommand{FrameAction: FrameActionBegin, FrameID: 2}},
+			{CmdType: 0x02, Data: []byte{0x01, 0x02, 0x03}}, // unknown, kept to the end of the update
+		}},
+	}
+
+	raw := []Update{
+		{UpdateCode: UpdateCodeOrders, Data: []byte{0x01, 0x00, 0x09}},
+		{UpdateCode: UpdateCodeBitmap, Fragmentation: FragmentFirst, Data: []byte{0x01, 0x00}},
+		{UpdateCode: UpdateCodeBitmap, Compression: CompressionUsed, CompressionFlags: 0x21, Data: []byte{0xaa}},
+	}
+
+	var pdu UpdatePDU
+
+	for _, update := range append(append([]Update(nil), typed...), raw...) {
+		pdu.Data = append(pdu.Data, update.Serialize()...)
+	}
+
+	updates, err := pdu.Updates()
+	require.NoError(t, err)
+	require.Len(t, updates, len(typed)+len(raw))
+
+	for i, expected := range typed {
+		expected.Data = expected.Serialize()[3:] // updateHeader and size
+
+		require.Equal(t, expected, updates[i], "update %d", i)
+	}
+
+	require.Equal(t, raw, updates[len(typed):])
+
+	pdu.Data[0] = byte(UpdateCodeBitmap)
+	pdu.Data = pdu.Data[:5] // truncated bitmap update
+
+	_, err = pdu.Updates()
+	require.Error(t, err)
+}
@@ -6,6 +6,11 @@ import (
 	"io"
 )
 
+const (
+	inputFlagSecureChecksum uint8 = 0x1 // FASTPATH_INPUT_SECURE_CHECKSUM
+	inputFlagEncrypted      uint8 = 0x2 // FASTPATH_INPUT_ENCRYPTED
+)
+
 type InputEventPDU struct {
 	action    uint8
 	numEvents uint8
@@ -37,7 +42,7 @@ func (pdu *InputEventPDU) SerializeLength(value int, w io.Writer) error {
 	if value > 0x7f {
 		value += 2 // 2 bytes length
 
-		return binary.Write(w, binary.BigEndian, value|0x8000)
+		return binary.Write(w, binary.BigEndian, uint16(value|0x8000))
 	}
 
 	value += 1 // 1 byte length
@@ -49,6 +54,16 @@ func (pdu *InputEventPDU) SerializeLength(value int, w io.Writer) error {
 }
 
 func (i *Protocol) Send(pdu *InputEventPDU) error {
+	if i.cipher != nil {
+		pdu.flags = inputFlagEncrypted
+
+		if i.cipher.SaltedChecksum() {
+			pdu.flags |= inputFlagSecureChecksum
+		}
+
+		pdu.eventData = i.cipher.Encrypt(pdu.eventData)
+	}
+
 	data := pdu.Serialize()
 
 	_, err := i.conn.Write(data)
@@ -0,0 +1,264 @@
+package fastpath
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+type SurfaceCommandType uint16
+
+const (
+	// SurfaceCommandTypeSetSurfaceBits CMDTYPE_SET_SURFACE_BITS
+	SurfaceCommandTypeSetSurfaceBits SurfaceCommandType = 0x0001
+
+	// SurfaceCommandTypeFrameMarker CMDTYPE_FRAME_MARKER
+	SurfaceCommandTypeFrameMarker SurfaceCommandType = 0x0004
+
+	// SurfaceCommandTypeStreamSurfaceBits CMDTYPE_STREAM_SURFACE_BITS
+	SurfaceCommandTypeStreamSurfaceBits SurfaceCommandType = 0x0006
+)
+
+// SurfaceCommand Surface Command (TS_SURFCMD), either of the surface bits or the frame marker.
+// Data keeps the rest of the update after the type of an unknown command, whose length is unknown.
+type SurfaceCommand struct {
+	CmdType     SurfaceCommandType
+	SurfaceBits *SurfaceBitsCommand
+	FrameMarker *FrameMarkerCommand
+	Data        []byte
+}
+
+func (c *SurfaceCommand) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, c.CmdType)
+
+	switch {
+	case c.SurfaceBits != nil:
+		buf.Write(c.SurfaceBits.Serialize())
+	case c.FrameMarker != nil:
+		buf.Write(c.FrameMarker.Serialize())
+	default:
+		buf.Write(c.Data)
+	}
+
+	return buf.Bytes()
+}
+
+func (c *SurfaceCommand) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &c.CmdType)
+	if err != nil {
+		return err
+	}
+
+	switch c.CmdType {
+	case SurfaceCommandTypeSetSurfaceBits, SurfaceCommandTypeStreamSurfaceBits:
+		c.SurfaceBits = &SurfaceBitsCommand{}
+
+		return c.SurfaceBits.Deserialize(wire)
+	case SurfaceCommandTypeFrameMarker:
+		c.FrameMarker = &FrameMarkerCommand{}
+
+		return c.FrameMarker.Deserialize(wire)
+	}
+
+	c.Data, err = io.ReadAll(wire)
+
+	return err
+}
+
+// SurfaceBitsCommand Set Surface Bits Command (TS_SURFCMD_SET_SURF_BITS)
+// and Stream Surface Bits Command (TS_SURFCMD_STREAM_SURF_BITS).
+type SurfaceBitsCommand struct {
+	DestLeft   uint16
+	DestTop    uint16
+	DestRight  uint16
+	DestBottom uint16
+	BitmapData BitmapDataEx
+}
+
+func (c *SurfaceBitsCommand) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, c.DestLeft)
+	_ = binary.Write(buf, binary.LittleEndian, c.DestTop)
+	_ = binary.Write(buf, binary.LittleEndian, c.DestRight)
+	_ = binary.Write(buf, binary.LittleEndian, c.DestBottom)
+	buf.Write(c.BitmapData.Serialize())
+
+	return buf.Bytes()
+}
+
+func (c *SurfaceBitsCommand) Deserialize(wire io.Reader) error {
+	var err error
+
+	err = binary.Read(wire, binary.LittleEndian, &c.DestLeft)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &c.DestTop)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &c.DestRight)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &c.DestBottom)
+	if err != nil {
+		return err
+	}
+
+	return c.BitmapData.Deserialize(wire)
+}
+
+// BitmapDataExFlagCompressedBitmapHeaderPresent EX_COMPRESSED_BITMAP_HEADER_PRESENT
+const BitmapDataExFlagCompressedBitmapHeaderPresent uint8 = 0x01
+
+// BitmapDataEx Extended Bitmap Data (TS_BITMAP_DATA_EX), the codec of CodecID is negotiated
+// by the Bitmap Codecs Capability Set.
+type BitmapDataEx struct {
+	Bpp                uint8
+	Flags              uint8
+	CodecID            uint8
+	Width              uint16
+	Height             uint16
+	ExBitmapDataHeader *CompressedBitmapHeaderEx
+	BitmapData         []byte
+}
+
+func (d *BitmapDataEx) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	flags := d.Flags &^ BitmapDataExFlagCompressedBitmapHeaderPresent
+	if d.ExBitmapDataHeader != nil {
+		flags |= BitmapDataExFlagCompressedBitmapHeaderPresent
+	}
+
+	_ = binary.Write(buf, binary.LittleEndian, d.Bpp)
+	_ = binary.Write(buf, binary.LittleEndian, flags)
+	_ = binary.Write(buf, binary.LittleEndian, uint8(0)) // reserved
+	_ = binary.Write(buf, binary.LittleEndian, d.CodecID)
+	_ = binary.Write(buf, binary.LittleEndian, d.Width)
+	_ = binary.Write(buf, binary.LittleEndian, d.Height)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(d.BitmapData)))
+
+	if d.ExBitmapDataHeader != nil {
+		buf.Write(d.ExBitmapDataHeader.Serialize())
+	}
+
+	buf.Write(d.BitmapData)
+
+	return buf.Bytes()
+}
+
+func (d *BitmapDataEx) Deserialize(wire io.Reader) error {
+	var err error
+
+	err = binary.Read(wire, binary.LittleEndian, &d.Bpp)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.Flags)
+	if err != nil {
+		return err
+	}
+
+	var reserved uint8
+	err = binary.Read(wire, binary.LittleEndian, &reserved)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.CodecID)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.Width)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.Height)
+	if err != nil {
+		return err
+	}
+
+	var bitmapDataLength uint32
+	err = binary.Read(wire, binary.LittleEndian, &bitmapDataLength)
+	if err != nil {
+		return err
+	}
+
+	if d.Flags&BitmapDataExFlagCompressedBitmapHeaderPresent != 0 {
+		d.ExBitmapDataHeader = &CompressedBitmapHeaderEx{}
+
+		err = d.ExBitmapDataHeader.Deserialize(wire)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.BitmapData, err = readBytes(wire, int(bitmapDataLength))
+
+	return err
+}
+
+// CompressedBitmapHeaderEx Extended Compressed Bitmap Header (TS_COMPRESSED_BITMAP_HEADER_EX).
+type CompressedBitmapHeaderEx struct {
+	HighUniqueID   uint32
+	LowUniqueID    uint32
+	TmMilliseconds uint64
+	TmSeconds      uint64
+}
+
+func (h *CompressedBitmapHeaderEx) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, h)
+
+	return buf.Bytes()
+}
+
+func (h *CompressedBitmapHeaderEx) Deserialize(wire io.Reader) error {
+	return binary.Read(wire, binary.LittleEndian, h)
+}
+
+type FrameAction uint16
+
+const (
+	// FrameActionBegin SURFACECMD_FRAMEACTION_BEGIN
+	FrameActionBegin FrameAction = 0x0000
+
+	// FrameActionEnd SURFACECMD_FRAMEACTION_END
+	FrameActionEnd FrameAction = 0x0001
+)
+
+// FrameMarkerCommand Frame Marker Command (TS_FRAME_MARKER).
+type FrameMarkerCommand struct {
+	FrameAction FrameAction
+	FrameID     uint32
+}
+
+func (c *FrameMarkerCommand) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, c.FrameAction)
+	_ = binary.Write(buf, binary.LittleEndian, c.FrameID)
+
+	return buf.Bytes()
+}
+
+func (c *FrameMarkerCommand) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &c.FrameAction)
+	if err != nil {
+		return err
+	}
+
+	return binary.Read(wire, binary.LittleEndian, &c.FrameID)
+}
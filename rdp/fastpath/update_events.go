@@ -1,16 +1,39 @@
 package fastpath
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 )
 
+var ErrInvalidPaletteSize = errors.New("palette of more than 256 colors")
+
+// readBytes reads n bytes, n of the wire does not size the allocation upfront.
+func readBytes(wire io.Reader, n int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(wire, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return data, nil
+}
+
+// PaletteEntry RGB Palette Entry (TS_PALETTE_ENTRY).
 type PaletteEntry struct {
 	Red   uint8
 	Green uint8
 	Blue  uint8
 }
 
+func (e *PaletteEntry) Serialize() []byte {
+	return []byte{e.Red, e.Green, e.Blue}
+}
+
 func (e *PaletteEntry) Deserialize(wire io.Reader) error {
 	var err error
 
@@ -24,7 +47,7 @@ func (e *PaletteEntry) Deserialize(wire io.Reader) error {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &e.Green)
+	err = binary.Read(wire, binary.LittleEndian, &e.Blue)
 	if err != nil {
 		return err
 	}
@@ -32,11 +55,31 @@ func (e *PaletteEntry) Deserialize(wire io.Reader) error {
 	return nil
 }
 
-type paletteUpdateData struct {
+const (
+	updateTypeBitmap  uint16 = 0x0001 // UPDATETYPE_BITMAP
+	updateTypePalette uint16 = 0x0002 // UPDATETYPE_PALETTE
+)
+
+// PaletteUpdateData Palette Update Data (TS_UPDATE_PALETTE_DATA).
+type PaletteUpdateData struct {
 	PaletteEntries []PaletteEntry
 }
 
-func (d *paletteUpdateData) Deserialize(wire io.Reader) error {
+func (d *PaletteUpdateData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, updateTypePalette)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0)) // pad2Octets
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(d.PaletteEntries)))
+
+	for i := range d.PaletteEntries {
+		buf.Write(d.PaletteEntries[i].Serialize())
+	}
+
+	return buf.Bytes()
+}
+
+func (d *PaletteUpdateData) Deserialize(wire io.Reader) error {
 	var err error
 
 	var updateType uint16
@@ -51,12 +94,16 @@ func (d *paletteUpdateData) Deserialize(wire io.Reader) error {
 		return err
 	}
 
-	var numberColors uint16
+	var numberColors uint32
 	err = binary.Read(wire, binary.LittleEndian, &numberColors)
 	if err != nil {
 		return err
 	}
 
+	if numberColors > 256 {
+		return ErrInvalidPaletteSize
+	}
+
 	d.PaletteEntries = make([]PaletteEntry, numberColors)
 
 	for i := 0; i < len(d.PaletteEntries); i++ {
@@ -69,12 +116,24 @@ func (d *paletteUpdateData) Deserialize(wire io.Reader) error {
 	return nil
 }
 
+// CompressedDataHeader Compressed Data Header (TS_CD_HEADER).
 type CompressedDataHeader struct {
 	CbCompMainBodySize uint16
 	CbScanWidth        uint16
 	CbUncompressedSize uint16
 }
 
+func (h *CompressedDataHeader) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0)) // cbCompFirstRowSize
+	_ = binary.Write(buf, binary.LittleEndian, h.CbCompMainBodySize)
+	_ = binary.Write(buf, binary.LittleEndian, h.CbScanWidth)
+	_ = binary.Write(buf, binary.LittleEndian, h.CbUncompressedSize)
+
+	return buf.Bytes()
+}
+
 func (h *CompressedDataHeader) Deserialize(wire io.Reader) error {
 	var err error
 
@@ -112,6 +171,7 @@ const (
 	BitmapDataFlagNoHDR BitmapDataFlag = 0x0400
 )
 
+// BitmapData Bitmap Data (TS_BITMAP_DATA), BitmapLength excludes the compressed data header.
 type BitmapData struct {
 	DestLeft         uint16
 	DestTop          uint16
@@ -126,6 +186,42 @@ type BitmapData struct {
 	BitmapDataStream []byte
 }
 
+func (d *BitmapData) hasComprHdr() bool {
+	return d.Flags&BitmapDataFlagNoHDR != BitmapDataFlagNoHDR && d.Flags&BitmapDataFlagCompression == BitmapDataFlagCompression
+}
+
+func (d *BitmapData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	bitmapLength := uint16(len(d.BitmapDataStream))
+	if d.hasComprHdr() {
+		bitmapLength += 8
+	}
+
+	_ = binary.Write(buf, binary.LittleEndian, d.DestLeft)
+	_ = binary.Write(buf, binary.LittleEndian, d.DestTop)
+	_ = binary.Write(buf, binary.LittleEndian, d.DestRight)
+	_ = binary.Write(buf, binary.LittleEndian, d.DestBottom)
+	_ = binary.Write(buf, binary.LittleEndian, d.Width)
+	_ = binary.Write(buf, binary.LittleEndian, d.Height)
+	_ = binary.Write(buf, binary.LittleEndian, d.BitsPerPixel)
+	_ = binary.Write(buf, binary.LittleEndian, d.Flags)
+	_ = binary.Write(buf, binary.LittleEndian, bitmapLength)
+
+	if d.hasComprHdr() {
+		header := d.BitmapComprHdr
+		if header == nil {
+			header = &CompressedDataHeader{}
+		}
+
+		buf.Write(header.Serialize())
+	}
+
+	buf.Write(d.BitmapDataStream)
+
+	return buf.Bytes()
+}
+
 func (d *BitmapData) Deserialize(wire io.Reader) error {
 	var err error
 
@@ -174,7 +270,13 @@ func (d *BitmapData) Deserialize(wire io.Reader) error {
 		return err
 	}
 
-	if d.Flags&BitmapDataFlagNoHDR != BitmapDataFlagNoHDR && d.Flags&BitmapDataFlagCompression == BitmapDataFlagCompression {
+	if d.hasComprHdr() {
+		if d.BitmapLength < 8 {
+			return io.ErrUnexpectedEOF
+		}
+
+		d.BitmapComprHdr = &CompressedDataHeader{}
+
 		err = d.BitmapComprHdr.Deserialize(wire)
 		if err != nil {
 			return err
@@ -183,21 +285,30 @@ func (d *BitmapData) Deserialize(wire io.Reader) error {
 		d.BitmapLength -= 8
 	}
 
-	d.BitmapDataStream = make([]byte, d.BitmapLength)
-
-	_, err = wire.Read(d.BitmapDataStream)
-	if err != nil {
-		return err
-	}
+	d.BitmapDataStream, err = readBytes(wire, int(d.BitmapLength))
 
-	return nil
+	return err
 }
 
-type bitmapUpdateData struct {
+// BitmapUpdateData Bitmap Update Data (TS_UPDATE_BITMAP_DATA).
+type BitmapUpdateData struct {
 	Rectangles []BitmapData
 }
 
-func (d *bitmapUpdateData) Deserialize(wire io.Reader) error {
+func (d *BitmapUpdateData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, updateTypeBitmap)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(d.Rectangles)))
+
+	for i := range d.Rectangles {
+		buf.Write(d.Rectangles[i].Serialize())
+	}
+
+	return buf.Bytes()
+}
+
+func (d *BitmapUpdateData) Deserialize(wire io.Reader) error {
 	var err error
 
 	var updateType uint16
@@ -224,20 +335,30 @@ func (d *bitmapUpdateData) Deserialize(wire io.Reader) error {
 	return nil
 }
 
-type pointerPositionUpdateData struct {
-	xPos uint16
-	yPos uint16
+// PointerPositionUpdateData Pointer Position Update (TS_POINTERPOSATTRIBUTE).
+type PointerPositionUpdateData struct {
+	XPos uint16
+	YPos uint16
+}
+
+func (d *PointerPositionUpdateData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, d.XPos)
+	_ = binary.Write(buf, binary.LittleEndian, d.YPos)
+
+	return buf.Bytes()
 }
 
-func (d *pointerPositionUpdateData) Deserialize(wire io.Reader) error {
+func (d *PointerPositionUpdateData) Deserialize(wire io.Reader) error {
 	var err error
 
-	err = binary.Read(wire, binary.LittleEndian, &d.xPos)
+	err = binary.Read(wire, binary.LittleEndian, &d.XPos)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &d.yPos)
+	err = binary.Read(wire, binary.LittleEndian, &d.YPos)
 	if err != nil {
 		return err
 	}
@@ -245,73 +366,233 @@ func (d *pointerPositionUpdateData) Deserialize(wire io.Reader) error {
 	return nil
 }
 
-type colorPointerUpdateData struct {
-	cacheIndex    uint16
-	xPos          uint16
-	yPos          uint16
-	width         uint16
-	height        uint16
-	lengthAndMask uint16
-	lengthXorMask uint16
-	xorMaskData   []byte
-	andMaskData   []byte
+type SystemPointerType uint32
+
+const (
+	// SystemPointerNull SYSPTR_NULL, the pointer is hidden
+	SystemPointerNull SystemPointerType = 0x00000000
+
+	// SystemPointerDefault SYSPTR_DEFAULT
+	SystemPointerDefault SystemPointerType = 0x00007F00
+)
+
+// SynchronizeUpdateData Fast-Path Synchronize Update (TS_FP_UPDATE_SYNCHRONIZE), the update has no data.
+type SynchronizeUpdateData struct{}
+
+// SystemPointerUpdateData System Pointer Update of FASTPATH_UPDATETYPE_PTR_NULL and FASTPATH_UPDATETYPE_PTR_DEFAULT,
+// the type comes from the update code.
+type SystemPointerUpdateData struct {
+	SystemPointerType SystemPointerType
+}
+
+// ColorPointerUpdateData Color Pointer Update (TS_COLORPOINTERATTRIBUTE).
+type ColorPointerUpdateData struct {
+	CacheIndex  uint16
+	XPos        uint16
+	YPos        uint16
+	Width       uint16
+	Height      uint16
+	XorMaskData []byte
+	AndMaskData []byte
+}
+
+func (d *ColorPointerUpdateData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, d.CacheIndex)
+	_ = binary.Write(buf, binary.LittleEndian, d.XPos)
+	_ = binary.Write(buf, binary.LittleEndian, d.YPos)
+	_ = binary.Write(buf, binary.LittleEndian, d.Width)
+	_ = binary.Write(buf, binary.LittleEndian, d.Height)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(d.AndMaskData)))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(d.XorMaskData)))
+	buf.Write(d.XorMaskData)
+	buf.Write(d.AndMaskData)
+	buf.WriteByte(0) // pad
+
+	return buf.Bytes()
 }
 
-func (d *colorPointerUpdateData) Deserialize(wire io.Reader) error {
+func (d *ColorPointerUpdateData) Deserialize(wire io.Reader) error {
 	var err error
 
-	err = binary.Read(wire, binary.LittleEndian, &d.cacheIndex)
+	err = binary.Read(wire, binary.LittleEndian, &d.CacheIndex)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &d.xPos)
+	err = binary.Read(wire, binary.LittleEndian, &d.XPos)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &d.yPos)
+	err = binary.Read(wire, binary.LittleEndian, &d.YPos)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &d.width)
+	err = binary.Read(wire, binary.LittleEndian, &d.Width)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &d.height)
+	err = binary.Read(wire, binary.LittleEndian, &d.Height)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &d.lengthAndMask)
+	var lengthAndMask, lengthXorMask uint16
+
+	err = binary.Read(wire, binary.LittleEndian, &lengthAndMask)
 	if err != nil {
 		return err
 	}
 
-	err = binary.Read(wire, binary.LittleEndian, &d.lengthXorMask)
+	err = binary.Read(wire, binary.LittleEndian, &lengthXorMask)
 	if err != nil {
 		return err
 	}
 
-	if d.lengthXorMask > 0 {
-		d.xorMaskData = make([]byte, d.lengthXorMask)
-		_, err = wire.Read(d.xorMaskData)
-		if err != nil {
-			return err
-		}
+	d.XorMaskData, err = readBytes(wire, int(lengthXorMask))
+	if err != nil {
+		return err
 	}
 
-	if d.lengthAndMask > 0 {
-		d.andMaskData = make([]byte, d.lengthAndMask)
-		_, err = wire.Read(d.andMaskData)
-		if err != nil {
-			return err
-		}
+	d.AndMaskData, err = readBytes(wire, int(lengthAndMask))
+	if err != nil {
+		return err
+	}
+
+	// the pad is optional
+	return nil
+}
+
+// NewPointerUpdateData New Pointer Update (TS_FP_POINTERATTRIBUTE).
+type NewPointerUpdateData struct {
+	XorBpp           uint16
+	ColorPointerData ColorPointerUpdateData
+}
+
+func (d *NewPointerUpdateData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, d.XorBpp)
+	buf.Write(d.ColorPointerData.Serialize())
+
+	return buf.Bytes()
+}
+
+func (d *NewPointerUpdateData) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &d.XorBpp)
+	if err != nil {
+		return err
+	}
+
+	return d.ColorPointerData.Deserialize(wire)
+}
+
+// CachedPointerUpdateData Cached Pointer Update (TS_CACHEDPOINTERATTRIBUTE).
+type CachedPointerUpdateData struct {
+	CacheIndex uint16
+}
+
+func (d *CachedPointerUpdateData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, d.CacheIndex)
+
+	return buf.Bytes()
+}
+
+func (d *CachedPointerUpdateData) Deserialize(wire io.Reader) error {
+	return binary.Read(wire, binary.LittleEndian, &d.CacheIndex)
+}
+
+// LargePointerUpdateData Large Pointer Update (TS_FP_LARGEPOINTERATTRIBUTE) of up to 384x384 pixels.
+type LargePointerUpdateData struct {
+	XorBpp      uint16
+	CacheIndex  uint16
+	XPos        uint16
+	YPos        uint16
+	Width       uint16
+	Height      uint16
+	XorMaskData []byte
+	AndMaskData []byte
+}
+
+func (d *LargePointerUpdateData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, d.XorBpp)
+	_ = binary.Write(buf, binary.LittleEndian, d.CacheIndex)
+	_ = binary.Write(buf, binary.LittleEndian, d.XPos)
+	_ = binary.Write(buf, binary.LittleEndian, d.YPos)
+	_ = binary.Write(buf, binary.LittleEndian, d.Width)
+	_ = binary.Write(buf, binary.LittleEndian, d.Height)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(d.AndMaskData)))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(d.XorMaskData)))
+	buf.Write(d.XorMaskData)
+	buf.Write(d.AndMaskData)
+	buf.WriteByte(0) // pad
+
+	return buf.Bytes()
+}
+
+func (d *LargePointerUpdateData) Deserialize(wire io.Reader) error {
+	var err error
+
+	err = binary.Read(wire, binary.LittleEndian, &d.XorBpp)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.CacheIndex)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.XPos)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.YPos)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.Width)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &d.Height)
+	if err != nil {
+		return err
+	}
+
+	var lengthAndMask, lengthXorMask uint32
+
+	err = binary.Read(wire, binary.LittleEndian, &lengthAndMask)
+	if err != nil {
+		return err
 	}
 
-	var padding uint8
+	err = binary.Read(wire, binary.LittleEndian, &lengthXorMask)
+	if err != nil {
+		return err
+	}
 
-	return binary.Read(wire, binary.LittleEndian, &padding)
+	d.XorMaskData, err = readBytes(wire, int(lengthXorMask))
+	if err != nil {
+		return err
+	}
+
+	d.AndMaskData, err = readBytes(wire, int(lengthAndMask))
+	if err != nil {
+		return err
+	}
+
+	// the pad is optional
+	return nil
 }
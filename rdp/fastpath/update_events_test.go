@@ -0,0 +1,155 @@
+package fastpath
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type updateData interface {
+	Serialize() []byte
+	Deserialize(wire io.Reader) error
+}
+
+func TestUpdateData_RoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    updateData
+		output   updateData
+		expected []byte
+	}{
+		{
+			name:   "palette",
+			input:  &PaletteUpdateData{PaletteEntries: []PaletteEntry{{Red: 0x01, Green: 0x02, Blue: 0x03}, {Red: 0xff}}},
+			output: &PaletteUpdateData{},
+			expected: []byte{
+				0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, // UPDATETYPE_PALETTE, pad2Octets, numberColors
+				0x01, 0x02, 0x03, 0xff, 0x00, 0x00,
+			},
+		},
+		{
+			name: "bitmap",
+			input: &BitmapUpdateData{Rectangles: []BitmapData{
+				{
+					DestRight: 1, DestBottom: 0, Width: 2, Height: 1, BitsPerPixel: 16,
+					BitmapLength: 4, BitmapDataStream: []byte{0x1f, 0x00, 0xe0, 0x07},
+				},
+				{
+					DestLeft: 2, DestRight: 3, Width: 2, Height: 1, BitsPerPixel: 16, Flags: BitmapDataFlagCompression,
+					BitmapLength:     3,
+					BitmapComprHdr:   &CompressedDataHeader{CbCompMainBodySize: 3, CbScanWidth: 4, CbUncompressedSize: 4},
+					BitmapDataStream: []byte{0x62, 0x1f, 0x00},
+				},
+				{
+					Width: 2, Height: 1, BitsPerPixel: 16, Flags: BitmapDataFlagCompression | BitmapDataFlagNoHDR,
+					BitmapLength: 3, BitmapDataStream: []byte{0x62, 0x1f, 0x00},
+				},
+			}},
+			output: &BitmapUpdateData{},
+		},
+		{
+			name:     "pointer position",
+			input:    &PointerPositionUpdateData{XPos: 0x0102, YPos: 0x0304},
+			output:   &PointerPositionUpdateData{},
+			expected: []byte{0x02, 0x01, 0x04, 0x03},
+		},
+		{
+			name: "color pointer",
+			input: &ColorPointerUpdateData{
+				CacheIndex: 1, XPos: 2, YPos: 3, Width: 4, Height: 1,
+				XorMaskData: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c},
+				AndMaskData: []byte{0xf0, 0x00},
+			},
+			output: &ColorPointerUpdateData{},
+			expected: []byte{
+				0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04, 0x00, 0x01, 0x00, 0x02, 0x00, 0x0c, 0x00,
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0xf0, 0x00, 0x00,
+			},
+		},
+		{
+			name: "new pointer",
+			input: &NewPointerUpdateData{XorBpp: 32, ColorPointerData: ColorPointerUpdateData{
+				CacheIndex: 2, Width: 1, Height: 1,
+				XorMaskData: []byte{0x01, 0x02, 0x03, 0x04}, AndMaskData: []byte{0x80, 0x00},
+			}},
+			output: &NewPointerUpdateData{},
+		},
+		{
+			name:     "cached pointer",
+			input:    &CachedPointerUpdateData{CacheIndex: 7},
+			output:   &CachedPointerUpdateData{},
+			expected: []byte{0x07, 0x00},
+		},
+		{
+			name: "large pointer",
+			input: &LargePointerUpdateData{
+				XorBpp: 32, CacheIndex: 3, XPos: 100, YPos: 200, Width: 384, Height: 1,
+				XorMaskData: bytes.Repeat([]byte{0xaa}, 384*4), AndMaskData: bytes.Repeat([]byte{0x00}, 48),
+			},
+			output: &LargePointerUpdateData{},
+		},
+		{
+			name: "set surface bits",
+			input: &SurfaceCommand{
+				CmdType: SurfaceCommandTypeSetSurfaceBits,
+				SurfaceBits: &SurfaceBitsCommand{
+					DestLeft: 1, DestTop: 2, DestRight: 3, DestBottom: 4,
+					BitmapData: BitmapDataEx{Bpp: 32, CodecID: 3, Width: 2, Height: 2, BitmapData: []byte{0x01, 0x02}},
+				},
+			},
+			output: &SurfaceCommand{},
+		},
+		{
+			name: "stream surface bits with the extended header",
+			input: &SurfaceCommand{
+				CmdType: SurfaceCommandTypeStreamSurfaceBits,
+				SurfaceBits: &SurfaceBitsCommand{
+					BitmapData: BitmapDataEx{
+						Bpp: 32, Flags: BitmapDataExFlagCompressedBitmapHeaderPresent, CodecID: 1, Width: 64, Height: 64,
+						ExBitmapDataHeader: &CompressedBitmapHeaderEx{HighUniqueID: 1, LowUniqueID: 2, TmMilliseconds: 3, TmSeconds: 4},
+						BitmapData:         []byte{0xc4, 0xcc},
+					},
+				},
+			},
+			output: &SurfaceCommand{},
+		},
+		{
+			name:     "frame marker",
+			input:    &SurfaceCommand{CmdType: SurfaceCommandTypeFrameMarker, FrameMarker: &FrameMarkerCommand{FrameAction: FrameActionEnd, FrameID: 5}},
+			output:   &SurfaceCommand{},
+			expected: []byte{0x04, 0x00, 0x01, 0x00, 0x05, 0x00, 0x00, 0x00},
+		},
+		{
+			name:     "unknown surface command",
+			input:    &SurfaceCommand{CmdType: 0x02, Data: []byte{0x01, 0x02, 0x03}},
+			output:   &SurfaceCommand{},
+			expected: []byte{0x02, 0x00, 0x01, 0x02, 0x03},
+		},
+	} {
+		wire := tc.input.Serialize()
+		if tc.expected != nil {
+			require.Equal(t, tc.expected, wire, tc.name)
+		}
+
+		require.NoError(t, tc.output.Deserialize(bytes.NewReader(wire)), tc.name)
+		require.Equal(t, tc.input, tc.output, tc.name)
+	}
+}
+
+func TestUpdateData_DeserializeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output updateData
+		input  []byte
+		err    error
+	}{
+		{"palette too large", &PaletteUpdateData{}, []byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00}, ErrInvalidPaletteSize},
+		{"truncated palette", &PaletteUpdateData{}, []byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, io.EOF},
+		{"truncated bitmap", &BitmapData{}, []byte{0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0, 16, 0, 0, 0, 2, 0, 0x1f}, io.ErrUnexpectedEOF},
+		{"large pointer beyond the data", &LargePointerUpdateData{}, append(make([]byte, 12), 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0x7f), io.ErrUnexpectedEOF},
+	} {
+		require.ErrorIs(t, tc.output.Deserialize(bytes.NewReader(tc.input)), tc.err, tc.name)
+	}
+}
@@ -0,0 +1,257 @@
+// Package framebuffer keeps the picture of the remote desktop: the drawing operations of the server
+// are applied to an in-memory image, the viewers take snapshots and follow the dirty regions.
+package framebuffer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"github.com/lunnik9/rdp/rdp/fastpath"
+	"github.com/lunnik9/rdp/rdp/planar"
+	"github.com/lunnik9/rdp/rdp/rle"
+)
+
+var (
+	ErrUnsupportedBitsPerPixel = errors.New("unsupported bits per pixel")
+	ErrShortBitmap             = errors.New("bitmap data shorter than its size")
+	ErrDecompress              = errors.New("bitmap decompression failed")
+)
+
+// Framebuffer of the remote desktop, safe for concurrent use.
+type Framebuffer struct {
+	mu sync.RWMutex
+
+	img     *image.RGBA
+	palette [256]color.RGBA
+
+	subscriptions map[*Subscription]struct{}
+}
+
+func New(width, height int) *Framebuffer {
+	return &Framebuffer{
+		img:           image.NewRGBA(image.Rect(0, 0, width, height)),
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Bounds of the desktop.
+func (f *Framebuffer) Bounds() image.Rectangle {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.img.Bounds()
+}
+
+// Snapshot returns a copy of the desktop.
+func (f *Framebuffer) Snapshot() *image.RGBA {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	snapshot := image.NewRGBA(f.img.Bounds())
+	copy(snapshot.Pix, f.img.Pix)
+
+	return snapshot
+}
+
+// Resize follows the new desktop size of the deactivation-reactivation sequence, the picture is kept
+// in the top-left corner.
+func (f *Framebuffer) Resize(width, height int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), f.img, image.Point{}, draw.Src)
+
+	f.img = img
+	f.invalidate(img.Bounds())
+}
+
+// SetPalette replaces the palette of the 8 bpp bitmaps from the first entry on.
+func (f *Framebuffer) SetPalette(entries []fastpath.PaletteEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, entry := range entries {
+		if i >= len(f.palette) {
+			break
+		}
+
+		f.palette[i] = color.RGBA{R: entry.Red, G: entry.Green, B: entry.Blue, A: 0xFF}
+	}
+}
+
+// Apply draws the palette and bitmap updates, the other updates don't change the picture.
+func (f *Framebuffer) Apply(update *fastpath.Update) error {
+	if update.Palette != nil {
+		f.SetPalette(update.Palette.PaletteEntries)
+	}
+
+	if update.Bitmap != nil {
+		for i := range update.Bitmap.Rectangles {
+			if err := f.ApplyBitmap(&update.Bitmap.Rectangles[i]); err != nil {
+				return fmt.Errorf("rectangle %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ApplyBitmap draws the rectangle of the Bitmap Update: the bottom-up scanlines of Interleaved RLE,
+// RDP 6.0 planar or uncompressed data, clipped to the destination and the desktop.
+func (f *Framebuffer) ApplyBitmap(bitmap *fastpath.BitmapData) error {
+	width, height := int(bitmap.Width), int(bitmap.Height)
+
+	bytesPerPixel, err := bytesPerPixel(bitmap.BitsPerPixel)
+	if err != nil {
+		return err
+	}
+
+	data := bitmap.BitmapDataStream
+
+	if bitmap.Flags&fastpath.BitmapDataFlagCompression == fastpath.BitmapDataFlagCompression {
+		if data, err = decompress(bitmap, bytesPerPixel); err != nil {
+			return err
+		}
+
+		if bitmap.BitsPerPixel == 32 {
+			bytesPerPixel = 4 // blue, green, red and alpha of the planar decoder
+		}
+	}
+
+	rowDelta := width * bytesPerPixel
+	if len(data) < rowDelta*height {
+		return ErrShortBitmap
+	}
+
+	dest := image.Rect(int(bitmap.DestLeft), int(bitmap.DestTop), int(bitmap.DestRight)+1, int(bitmap.DestBottom)+1)
+	dest = dest.Intersect(image.Rect(dest.Min.X, dest.Min.Y, dest.Min.X+width, dest.Min.Y+height))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dest = dest.Intersect(f.img.Bounds())
+
+	for y := dest.Min.Y; y < dest.Max.Y; y++ {
+		row := data[(height-1-(y-int(bitmap.DestTop)))*rowDelta:]
+
+		for x := dest.Min.X; x < dest.Max.X; x++ {
+			pixel := row[(x-int(bitmap.DestLeft))*bytesPerPixel:]
+
+			f.img.SetRGBA(x, y, f.rgba(bitmap.BitsPerPixel, pixel))
+		}
+	}
+
+	f.invalidate(dest)
+
+	return nil
+}
+
+// CopyRect copies the screen area at src to dest, like the Screen Blit of the drawing orders.
+// The areas may overlap.
+func (f *Framebuffer) CopyRect(dest image.Rectangle, src image.Point) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bounds := f.img.Bounds()
+
+	// clip the destination by both the desktop and the source area within the desktop
+	dest = dest.Intersect(bounds)
+	dest = dest.Intersect(bounds.Add(dest.Min.Sub(src)))
+
+	if dest.Empty() {
+		return
+	}
+
+	area := image.NewRGBA(dest.Sub(dest.Min))
+	draw.Draw(area, area.Bounds(), f.img, src, draw.Src)
+	draw.Draw(f.img, dest, area, image.Point{}, draw.Src)
+
+	f.invalidate(dest)
+}
+
+// FillRect fills the area with the color, like the Opaque Rectangle and the black and white Destination Blit.
+func (f *Framebuffer) FillRect(rect image.Rectangle, c color.RGBA) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rect = rect.Intersect(f.img.Bounds())
+	if rect.Empty() {
+		return
+	}
+
+	draw.Draw(f.img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+
+	f.invalidate(rect)
+}
+
+func bytesPerPixel(bitsPerPixel uint16) (int, error) {
+	switch bitsPerPixel {
+	case 8:
+		return 1, nil
+	case 15, 16:
+		return 2, nil
+	case 24:
+		return 3, nil
+	case 32:
+		return 4, nil
+	}
+
+	return 0, ErrUnsupportedBitsPerPixel
+}
+
+func decompress(bitmap *fastpath.BitmapData, bytesPerPixel int) ([]byte, error) {
+	width, height := int(bitmap.Width), int(bitmap.Height)
+
+	if bitmap.BitsPerPixel == 32 {
+		data := make([]byte, width*height*4)
+
+		if err := planar.Decompress(bitmap.BitmapDataStream, width, height, data); err != nil {
+			return nil, err
+		}
+
+		return data, nil
+	}
+
+	data := make([]byte, width*height*bytesPerPixel)
+
+	if !rle.DecompressColorDepth(int(bitmap.BitsPerPixel), bitmap.BitmapDataStream, data, width*bytesPerPixel) {
+		return nil, ErrDecompress
+	}
+
+	return data, nil
+}
+
+// rgba converts the little-endian pixel of the color depth.
+func (f *Framebuffer) rgba(bitsPerPixel uint16, pixel []byte) color.RGBA {
+	switch bitsPerPixel {
+	case 8:
+		return f.palette[pixel[0]]
+	case 15:
+		v := uint16(pixel[0]) | uint16(pixel[1])<<8
+
+		return color.RGBA{R: expand5(v >> 10), G: expand5(v >> 5), B: expand5(v), A: 0xFF}
+	case 16:
+		v := uint16(pixel[0]) | uint16(pixel[1])<<8
+
+		return color.RGBA{R: expand5(v >> 11), G: expand6(v >> 5), B: expand5(v), A: 0xFF}
+	default: // 24 and 32 bpp, the alpha of the desktop is ignored
+		return color.RGBA{R: pixel[2], G: pixel[1], B: pixel[0], A: 0xFF}
+	}
+}
+
+func expand5(v uint16) uint8 {
+	v &= 0x1F
+
+	return uint8(v<<3 | v>>2)
+}
+
+func expand6(v uint16) uint8 {
+	v &= 0x3F
+
+	return uint8(v<<2 | v>>4)
+}
@@ -0,0 +1,232 @@
+package framebuffer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/fastpath"
+)
+
+var (
+	red   = color.RGBA{R: 0xFF, A: 0xFF}
+	green = color.RGBA{G: 0xFF, A: 0xFF}
+	blue  = color.RGBA{B: 0xFF, A: 0xFF}
+	black = color.RGBA{A: 0xFF}
+	white = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+)
+
+func requirePixels(t *testing.T, f *Framebuffer, rect image.Rectangle, expected ...color.RGBA) {
+	t.Helper()
+
+	snapshot := f.Snapshot()
+
+	var actual []color.RGBA
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			actual = append(actual, snapshot.RGBAAt(x, y))
+		}
+	}
+
+	require.Equal(t, expected, actual)
+}
+
+func TestFramebuffer_ApplyBitmap(t *testing.T) {
+	f := New(4, 4)
+
+	f.SetPalette([]fastpath.PaletteEntry{{Red: 0xFF}, {Green: 0xFF}, {Blue: 0xFF}})
+
+	for _, tc := range []struct {
+		name     string
+		bitmap   fastpath.BitmapData
+		expected []color.RGBA
+	}{
+		{
+			name: "8 bpp palette",
+			bitmap: fastpath.BitmapData{
+				Width: 2, Height: 2, BitsPerPixel: 8,
+				BitmapDataStream: []byte{0x02, 0x02, 0x00, 0x01}, // bottom-up
+			},
+			expected: []color.RGBA{red, green, blue, blue},
+		},
+		{
+			name: "15 bpp",
+			bitmap: fastpath.BitmapData{
+				Width: 2, Height: 2, BitsPerPixel: 15,
+				BitmapDataStream: []byte{0x00, 0x00, 0xFF, 0x7F, 0x00, 0x7C, 0xE0, 0x03},
+			},
+			expected: []color.RGBA{red, green, black, white},
+		},
+		{
+			name: "16 bpp",
+			bitmap: fastpath.BitmapData{
+				Width: 2, Height: 2, BitsPerPixel: 16,
+				BitmapDataStream: []byte{0x1F, 0x00, 0xFF, 0xFF, 0x00, 0xF8, 0xE0, 0x07},
+			},
+			expected: []color.RGBA{red, green, blue, white},
+		},
+		{
+			name: "16 bpp Interleaved RLE",
+			bitmap: fastpath.BitmapData{
+				Width: 2, Height: 2, BitsPerPixel: 16, Flags: fastpath.BitmapDataFlagCompression,
+				BitmapDataStream: []byte{0x62, 0x1F, 0x00, 0xFD, 0xFE}, // REGULAR_COLOR_RUN of 2 blue, WHITE, BLACK
+			},
+			expected: []color.RGBA{white, black, blue, blue},
+		},
+		{
+			name: "24 bpp",
+			bitmap: fastpath.BitmapData{
+				Width: 2, Height: 2, BitsPerPixel: 24,
+				BitmapDataStream: []byte{0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0x00, 0xFF, 0x00},
+			},
+			expected: []color.RGBA{red, green, blue, black},
+		},
+		{
+			name: "32 bpp planar",
+			bitmap: fastpath.BitmapData{
+				Width: 2, Height: 2, BitsPerPixel: 32, Flags: fastpath.BitmapDataFlagCompression,
+				BitmapDataStream: []byte{
+					0x20,                   // NA, raw RGB planes
+					0x00, 0xFF, 0xFF, 0x00, // red
+					0x00, 0x00, 0x00, 0xFF, // green
+					0xFF, 0x00, 0x00, 0x00, // blue
+					0x00, // pad
+				},
+			},
+			expected: []color.RGBA{red, green, blue, red},
+		},
+	} {
+		bitmap := tc.bitmap
+		bitmap.DestLeft, bitmap.DestTop, bitmap.DestRight, bitmap.DestBottom = 1, 1, 2, 2
+
+		require.NoError(t, f.ApplyBitmap(&bitmap), tc.name)
+
+		t.Run(tc.name, func(t *testing.T) {
+			requirePixels(t, f, image.Rect(1, 1, 3, 3), tc.expected...)
+		})
+	}
+}
+
+func TestFramebuffer_ApplyBitmapClipped(t *testing.T) {
+	f := New(3, 2)
+
+	s := f.Subscribe()
+	defer s.Close()
+
+	// 4x2 bitmap of 3 columns at the right edge of the desktop
+	err := f.ApplyBitmap(&fastpath.BitmapData{
+		DestLeft: 1, DestTop: 1, DestRight: 3, DestBottom: 2,
+		Width: 4, Height: 2, BitsPerPixel: 24,
+		BitmapDataStream: []byte{
+			0x00, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0x00, 0xFF, 0xFF, 0xFF,
+			0xFF, 0x00, 0x00, 0x00, 0xFF, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF,
+		},
+	})
+	require.NoError(t, err)
+
+	requirePixels(t, f, f.Bounds(), color.RGBA{}, color.RGBA{}, color.RGBA{}, color.RGBA{}, blue, green)
+	require.Equal(t, []image.Rectangle{image.Rect(1, 1, 3, 2)}, s.Dirty())
+}
+
+func TestFramebuffer_ApplyBitmapErrors(t *testing.T) {
+	f := New(4, 4)
+
+	require.ErrorIs(t, f.ApplyBitmap(&fastpath.BitmapData{Width: 1, Height: 1, BitsPerPixel: 4}), ErrUnsupportedBitsPerPixel)
+	require.ErrorIs(t, f.ApplyBitmap(&fastpath.BitmapData{Width: 2, Height: 2, BitsPerPixel: 16, BitmapDataStream: []byte{0x00}}), ErrShortBitmap)
+	require.ErrorIs(t, f.ApplyBitmap(&fastpath.BitmapData{
+		Width: 2, Height: 2, BitsPerPixel: 16, Flags: fastpath.BitmapDataFlagCompression,
+		BitmapDataStream: []byte{0xFB},
+	}), ErrDecompress)
+}
+
+func TestFramebuffer_CopyRect(t *testing.T) {
+	f := New(4, 1)
+
+	f.FillRect(image.Rect(0, 0, 1, 1), red)
+	f.FillRect(image.Rect(1, 0, 2, 1), green)
+	f.FillRect(image.Rect(2, 0, 4, 1), blue)
+
+	f.CopyRect(image.Rect(1, 0, 4, 1), image.Pt(0, 0)) // overlapping to the right
+	requirePixels(t, f, f.Bounds(), red, red, green, blue)
+
+	f.CopyRect(image.Rect(0, 0, 4, 1), image.Pt(2, 0)) // source clipped by the desktop
+	requirePixels(t, f, f.Bounds(), green, blue, green, blue)
+}
+
+func TestFramebuffer_Subscribe(t *testing.T) {
+	f := New(100, 100)
+
+	s := f.Subscribe()
+
+	f.FillRect(image.Rect(0, 0, 10, 10), red)
+	f.FillRect(image.Rect(5, 5, 20, 20), green) // merged with the overlapping one
+	f.FillRect(image.Rect(50, 50, 60, 60), blue)
+	f.FillRect(image.Rect(200, 200, 210, 210), blue) // outside of the desktop
+
+	select {
+	case <-s.C():
+	default:
+		t.Fatal("subscription not signalled")
+	}
+
+	require.Equal(t, []image.Rectangle{image.Rect(0, 0, 20, 20), image.Rect(50, 50, 60, 60)}, s.Dirty())
+	require.Empty(t, s.Dirty())
+
+	for i := 0; i < maxDirtyRegions+1; i++ {
+		f.FillRect(image.Rect(i, 90, i+1, 91), white) // adjacent regions don't overlap
+	}
+
+	require.Equal(t, []image.Rectangle{image.Rect(0, 90, maxDirtyRegions+1, 91)}, s.Dirty())
+
+	s.Close()
+
+	f.FillRect(image.Rect(0, 0, 1, 1), white)
+	require.Empty(t, s.Dirty())
+}
+
+func TestFramebuffer_ResizeAndSnapshot(t *testing.T) {
+	f := New(2, 2)
+	f.FillRect(image.Rect(0, 0, 2, 2), red)
+
+	snapshot := f.Snapshot()
+
+	s := f.Subscribe()
+	defer s.Close()
+
+	f.Resize(3, 1)
+	require.Equal(t, image.Rect(0, 0, 3, 1), f.Bounds())
+	requirePixels(t, f, f.Bounds(), red, red, color.RGBA{})
+	require.Equal(t, []image.Rectangle{image.Rect(0, 0, 3, 1)}, s.Dirty())
+
+	require.Equal(t, image.Rect(0, 0, 2, 2), snapshot.Bounds()) // a copy
+	require.Equal(t, red, snapshot.RGBAAt(1, 1))
+}
+
+func TestFramebuffer_Apply(t *testing.T) {
+	f := New(2, 1)
+
+	var pdu fastpath.UpdatePDU
+
+	for _, update := range []fastpath.Update{
+		{UpdateCode: fastpath.UpdateCodePalette, Palette: &fastpath.PaletteUpdateData{
+			PaletteEntries: []fastpath.PaletteEntry{{Red: 0xFF}, {Blue: 0xFF}},
+		}},
+		{UpdateCode: fastpath.UpdateCodeBitmap, Bitmap: &fastpath.BitmapUpdateData{Rectangles: []fastpath.BitmapData{
+			{DestRight: 1, Width: 2, Height: 1, BitsPerPixel: 8, BitmapDataStream: []byte{0x01, 0x00}},
+		}}},
+	} {
+		pdu.Data = append(pdu.Data, update.Serialize()...)
+	}
+
+	updates, err := pdu.Updates()
+	require.NoError(t, err)
+
+	for i := range updates {
+		require.NoError(t, f.Apply(&updates[i]))
+	}
+
+	requirePixels(t, f, f.Bounds(), blue, red)
+}
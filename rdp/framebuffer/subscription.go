@@ -0,0 +1,97 @@
+package framebuffer
+
+import "image"
+
+// maxDirtyRegions per subscription, the regions of a lagging viewer are merged into their bounds beyond it.
+const maxDirtyRegions = 64
+
+// Subscription follows the dirty regions of the framebuffer. The regions accumulate until Dirty takes them,
+// so a slow viewer never blocks the drawing.
+type Subscription struct {
+	f *Framebuffer
+
+	c     chan struct{}
+	dirty []image.Rectangle
+}
+
+// Subscribe returns a subscription to the dirty regions. Late joiners subscribe before taking the Snapshot,
+// so no change falls in between.
+func (f *Framebuffer) Subscribe() *Subscription {
+	s := &Subscription{
+		f: f,
+		c: make(chan struct{}, 1),
+	}
+
+	f.mu.Lock()
+	f.subscriptions[s] = struct{}{}
+	f.mu.Unlock()
+
+	return s
+}
+
+// C is signalled when there are dirty regions to take.
+func (s *Subscription) C() <-chan struct{} {
+	return s.c
+}
+
+// Dirty takes the regions changed since the previous call.
+func (s *Subscription) Dirty() []image.Rectangle {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	dirty := s.dirty
+	s.dirty = nil
+
+	return dirty
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.f.mu.Lock()
+	delete(s.f.subscriptions, s)
+	s.f.mu.Unlock()
+}
+
+// invalidate adds the region to the subscriptions, the framebuffer lock is held.
+func (f *Framebuffer) invalidate(rect image.Rectangle) {
+	if rect.Empty() {
+		return
+	}
+
+	for s := range f.subscriptions {
+		s.add(rect)
+
+		select {
+		case s.c <- struct{}{}:
+		default: // already signalled
+		}
+	}
+}
+
+// add merges the region with the overlapping ones.
+func (s *Subscription) add(rect image.Rectangle) {
+	for merged := true; merged; {
+		merged = false
+
+		for i, dirty := range s.dirty {
+			if dirty.Overlaps(rect) {
+				rect = rect.Union(dirty)
+				s.dirty = append(s.dirty[:i], s.dirty[i+1:]...)
+				merged = true
+
+				break
+			}
+		}
+	}
+
+	s.dirty = append(s.dirty, rect)
+
+	if len(s.dirty) > maxDirtyRegions {
+		bounds := s.dirty[0]
+		for _, dirty := range s.dirty[1:] {
+			bounds = bounds.Union(dirty)
+		}
+
+		s.dirty = []image.Rectangle{bounds}
+	}
+}
@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// transport carries the gateway packets, a single write is a whole packet.
+type transport struct {
+	r       io.Reader
+	w       io.Writer
+	in, out net.Conn
+}
+
+func (t *transport) Close() error {
+	if closer, ok := t.w.(io.Closer); ok { // WebSocket close frame or the last chunk
+		_ = closer.Close()
+	}
+
+	if t.in != t.out { // a single connection of the WebSocket transport
+		_ = t.in.Close()
+	}
+
+	return t.out.Close()
+}
+
+// Conn is the channel to the RDP server through the gateway.
+type Conn struct {
+	t         *transport
+	channelID uint32
+	data      []byte // rest of the last data packet
+
+	writeMu sync.Mutex
+}
+
+func newConn(t *transport) *Conn {
+	return &Conn{
+		t: t,
+	}
+}
+
+type deserializer interface {
+	Deserialize(wire io.Reader) error
+}
+
+// setup runs the handshake, creates and authorizes the tunnel and creates the channel, MS-TSGU 3.3.5.1.
+func (c *Conn) setup(resource string, port uint16, clientName string) error {
+	var (
+		handshake handshakeResponse
+		tunnel    tunnelResponse
+		auth      tunnelAuthResponse
+		channel   channelResponse
+	)
+
+	if err := c.exchange((&handshakeRequest{}).Serialize(), packetTypeHandshakeResponse, &handshake); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+
+	if handshake.ErrorCode != 0 {
+		return ServerError{Packet: "handshake", Code: handshake.ErrorCode}
+	}
+
+	req := tunnelCreate{CapsFlags: capabilityIdleTimeout}
+	if err := c.exchange(req.Serialize(), packetTypeTunnelResponse, &tunnel); err != nil {
+		return fmt.Errorf("tunnel create: %w", err)
+	}
+
+	if tunnel.StatusCode != 0 {
+		return ServerError{Packet: "tunnel create", Code: tunnel.StatusCode}
+	}
+
+	if err := c.exchange((&tunnelAuth{ClientName: clientName}).Serialize(), packetTypeTunnelAuthResponse, &auth); err != nil {
+		return fmt.Errorf("tunnel authorization: %w", err)
+	}
+
+	if auth.ErrorCode != 0 {
+		return ServerError{Packet: "tunnel authorization", Code: auth.ErrorCode}
+	}
+
+	log.Printf("RDG: tunnel %d authorized, idle timeout = %d min\n", tunnel.TunnelID, auth.IdleTimeout)
+
+	create := channelCreate{Resource: resource, Port: port}
+	if err := c.exchange(create.Serialize(), packetTypeChannelResponse, &channel); err != nil {
+		return fmt.Errorf("channel create: %w", err)
+	}
+
+	if channel.ErrorCode != 0 {
+		return ServerError{Packet: "channel create", Code: channel.ErrorCode}
+	}
+
+	c.channelID = channel.ChannelID
+
+	log.Printf("RDG: channel %d to %s:%d\n", c.channelID, resource, port)
+
+	return nil
+}
+
+// exchange sends the packet and reads the response of respType.
+func (c *Conn) exchange(packet []byte, respType packetType, resp deserializer) error {
+	if err := c.writePacket(packet); err != nil {
+		return err
+	}
+
+	for {
+		t, body, err := readPacket(c.t.r)
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case respType:
+			return resp.Deserialize(bytes.NewReader(body))
+		case packetTypeKeepalive:
+			continue
+		}
+
+		return fmt.Errorf("%w: %d", ErrUnexpectedPacket, t)
+	}
+}
+
+func (c *Conn) writePacket(packet []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err := c.t.w.Write(packet)
+
+	return err
+}
+
+// Read returns the data of the data packets, keepalives are answered.
+func (c *Conn) Read(b []byte) (int, error) {
+	for len(c.data) == 0 {
+		t, body, err := readPacket(c.t.r)
+		if err != nil {
+			return 0, err
+		}
+
+		switch t {
+		case packetTypeData:
+			if c.data, err = dataPacketData(body); err != nil {
+				return 0, err
+			}
+		case packetTypeKeepalive:
+			if err = c.writePacket(newPacket(packetTypeKeepalive, nil)); err != nil {
+				return 0, err
+			}
+		case packetTypeCloseChannel:
+			_ = c.writePacket(newCloseChannelPacket(packetTypeCloseChannelResponse, 0))
+
+			return 0, io.EOF
+		case packetTypeServiceMessage, packetTypeReauthMessage:
+			log.Printf("RDG: ignored packet type %d\n", t)
+		default:
+			return 0, fmt.Errorf("%w: %d", ErrUnexpectedPacket, t)
+		}
+	}
+
+	n := copy(b, c.data)
+	c.data = c.data[n:]
+
+	return n, nil
+}
+
+// Write sends b in data packets.
+func (c *Conn) Write(b []byte) (int, error) {
+	for written := 0; written < len(b); {
+		n := len(b) - written
+		if n > maxDataLen {
+			n = maxDataLen
+		}
+
+		if err := c.writePacket(newDataPacket(b[written : written+n])); err != nil {
+			return written, err
+		}
+
+		written += n
+	}
+
+	return len(b), nil
+}
+
+// Close closes the channel and the tunnel.
+func (c *Conn) Close() error {
+	_ = c.writePacket(newCloseChannelPacket(packetTypeCloseChannel, 0))
+
+	return c.t.Close()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.t.out.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.t.out.RemoteAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.t.in.SetWriteDeadline(t); err != nil {
+		return err
+	}
+
+	return c.t.out.SetReadDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.t.out.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.t.in.SetWriteDeadline(t)
+}
@@ -0,0 +1,37 @@
+package gateway
+
+import (
+	"context"
+	"net"
+)
+
+// Dialer opens connections to RDP servers through the gateway.
+type Dialer struct {
+	Config Config
+}
+
+func NewDialer(config Config) *Dialer {
+	return &Dialer{
+		Config: config,
+	}
+}
+
+// Dial opens the tunnel and the channel to address, the network is always TCP.
+func (d *Dialer) Dial(_, address string) (net.Conn, error) {
+	conn, err := Dial(d.Config, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// DialContext is Dial canceled by ctx.
+func (d *Dialer) DialContext(ctx context.Context, _, address string) (net.Conn, error) {
+	conn, err := DialContext(ctx, d.Config, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidPacket             = errors.New("invalid gateway packet")
+	ErrUnexpectedPacket          = errors.New("unexpected gateway packet")
+	ErrAuthenticationFailed      = errors.New("gateway authentication failed")
+	ErrUnsupportedAuthentication = errors.New("gateway offers no supported authentication scheme")
+	ErrWebSocketNotSupported     = errors.New("gateway does not support the WebSocket transport")
+	ErrInvalidWebSocketAccept    = errors.New("invalid Sec-WebSocket-Accept of the gateway")
+	ErrUnexpectedHTTPStatusCode  = errors.New("unexpected HTTP status of the gateway")
+)
+
+// ServerError error code of the gateway response to the tunnel setup packet.
+type ServerError struct {
+	Packet string
+	Code   uint32
+}
+
+var errorCodeMap = map[uint32]string{
+	0x800759D8: "E_PROXY_INTERNALERROR",
+	0x800759DA: "E_PROXY_RAP_ACCESSDENIED",
+	0x800759DB: "E_PROXY_NAP_ACCESSDENIED",
+	0x800759DD: "E_PROXY_TS_CONNECTFAILED",
+	0x800759DF: "E_PROXY_MAXCONNECTIONSREACHED",
+}
+
+func (e ServerError) Error() string {
+	if name, ok := errorCodeMap[e.Code]; ok {
+		return fmt.Sprintf("gateway %s error: %s (0x%08X)", e.Packet, name, e.Code)
+	}
+
+	return fmt.Sprintf("gateway %s error: 0x%08X", e.Packet, e.Code)
+}
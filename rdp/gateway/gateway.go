@@ -0,0 +1,201 @@
+// Package gateway tunnels RDP connections through Remote Desktop Gateway, MS-TSGU,
+// over the HTTP transport or its WebSocket variant.
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lunnik9/rdp/rdp/dialctx"
+)
+
+// Transport of the tunnel to the gateway.
+type Transport uint8
+
+const (
+	// TransportAuto tries the WebSocket transport and falls back to the HTTP transport.
+	TransportAuto Transport = iota
+
+	// TransportHTTP carries the packets in the bodies of RDG_IN_DATA and RDG_OUT_DATA requests.
+	TransportHTTP
+
+	// TransportWebSocket carries the packets in WebSocket frames of the upgraded RDG_OUT_DATA request.
+	TransportWebSocket
+)
+
+// Forward opens the connections to the gateway, *net.Dialer and the proxy and jump host dialers are forward dialers.
+type Forward interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// Config of the connection to the gateway.
+type Config struct {
+	Address   string // host[:port] of the gateway, port 443 by default
+	Domain    string
+	Username  string
+	Password  string
+	Transport Transport
+	TLSConfig *tls.Config   // verification of the gateway certificate, the system roots by default
+	Forward   Forward       // TCP by default
+	Timeout   time.Duration // of the tunnel setup, 10 seconds by default
+}
+
+const (
+	defaultGatewayPort = "443"
+	defaultRDPPort     = 3389
+	defaultTimeout     = 10 * time.Second
+)
+
+// Dial opens the tunnel through the gateway and the channel to the RDP server at target host:port.
+func Dial(config Config, target string) (*Conn, error) {
+	return DialContext(context.Background(), config, target)
+}
+
+// DialContext is Dial canceled by ctx, the cancellation interrupts the tunnel setup.
+func DialContext(ctx context.Context, config Config, target string) (*Conn, error) {
+	resource, port, err := splitTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := newGateway(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := g.setup(resource, port)
+
+	g.stopInterrupts()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if err == nil {
+			_ = conn.Close()
+		}
+
+		return nil, ctxErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// setup opens the tunnel and the channel.
+func (g *gateway) setup(resource string, port uint16) (*Conn, error) {
+	t, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newConn(t)
+
+	if err = conn.setup(resource, port, clientName()); err != nil {
+		_ = t.Close()
+
+		return nil, err
+	}
+
+	for _, c := range []net.Conn{t.in, t.out} { // the deadline of the setup
+		if err = c.SetDeadline(time.Time{}); err != nil {
+			_ = t.Close()
+
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// gateway opens the HTTP connections of a single tunnel.
+type gateway struct {
+	config       Config
+	host         string
+	address      string
+	connectionID string
+	scheme       string // of the authentication offered by the gateway
+	deadline     time.Time
+
+	ctx   context.Context
+	stops []func() // of the interruption of the connections by ctx
+}
+
+func newGateway(ctx context.Context, config Config) (*gateway, error) {
+	host, port, err := net.SplitHostPort(config.Address)
+	if err != nil {
+		host, port = config.Address, defaultGatewayPort
+	}
+
+	connectionID, err := newGUID()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &gateway{
+		config:       config,
+		host:         host,
+		address:      net.JoinHostPort(host, port),
+		connectionID: connectionID,
+		deadline:     dialctx.Deadline(ctx, timeout),
+		ctx:          ctx,
+	}, nil
+}
+
+// stopInterrupts stops the interruption of the dialed connections by the context.
+func (g *gateway) stopInterrupts() {
+	for _, stop := range g.stops {
+		stop()
+	}
+
+	g.stops = nil
+}
+
+// splitTarget returns the host and the port of the RDP server, 3389 by default.
+func splitTarget(target string) (string, uint16, error) {
+	host, portString, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, defaultRDPPort, nil
+	}
+
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("target port: %w", err)
+	}
+
+	return host, uint16(port), nil
+}
+
+// newGUID returns a random GUID in the registry format of RDG-Connection-Id.
+func newGUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0F | 0x40 // version 4
+	b[8] = b[8]&0x3F | 0x80 // variant
+
+	return fmt.Sprintf("{%X-%X-%X-%X-%X}", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// clientName returns the name of the machine running the client.
+func clientName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "rdp-html5"
+	}
+
+	return name
+}
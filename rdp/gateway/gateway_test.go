@@ -0,0 +1,428 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+// testGateway is a stand-in RD Gateway echoing the data of the channel to rdp.example.com:3389.
+type testGateway struct {
+	t         *testing.T
+	listener  net.Listener
+	webSocket bool
+
+	mu   sync.Mutex
+	outs map[string]chan outChannel
+}
+
+// outChannel is the RDG_OUT_DATA response body of the HTTP transport waiting for RDG_IN_DATA.
+type outChannel struct {
+	w    io.Writer
+	done chan struct{}
+}
+
+func newTestGateway(t *testing.T, webSocket bool) (*testGateway, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gateway"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	require.NoError(t, err)
+
+	g := &testGateway{
+		t:         t,
+		listener:  listener,
+		webSocket: webSocket,
+		outs:      make(map[string]chan outChannel),
+	}
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		_ = http.Serve(listener, g)
+	}()
+
+	return g, roots
+}
+
+func (g *testGateway) out(connectionID string) chan outChannel {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.outs[connectionID]; !ok {
+		g.outs[connectionID] = make(chan outChannel, 1)
+	}
+
+	return g.outs[connectionID]
+}
+
+func (g *testGateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	authorization := "Basic " + base64.StdEncoding.EncodeToString([]byte(`EXAMPLE\user:password`))
+
+	if req.Header.Get("Authorization") != authorization {
+		w.Header().Set("WWW-Authenticate", `Basic realm="gateway"`)
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	connectionID := req.Header.Get("RDG-Connection-Id")
+
+	switch {
+	case req.Method == methodOutData && req.Header.Get("Upgrade") == "websocket":
+		if !g.webSocket {
+			w.WriteHeader(http.StatusOK) // the upgrade is ignored
+
+			return
+		}
+
+		req.Method = http.MethodGet // of the upgrader
+
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, nil)
+		if err != nil {
+			g.t.Error(err)
+
+			return
+		}
+
+		defer conn.Close()
+
+		ws := newWebSocket(conn)
+		g.serveTunnel(ws, ws)
+	case req.Method == methodOutData:
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		out := outChannel{w: flushWriter{w}, done: make(chan struct{})}
+		g.out(connectionID) <- out
+		<-out.done
+	case req.Method == methodInData:
+		out := <-g.out(connectionID)
+		g.serveTunnel(req.Body, out.w)
+		close(out.done)
+	default: // the upgrade request sent as GET
+		g.t.Errorf("unexpected %s request", req.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// flushWriter sends every packet of the RDG_OUT_DATA response body in its own chunk.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(b []byte) (int, error) {
+	n, err := f.w.Write(b)
+	f.w.(http.Flusher).Flush()
+
+	return n, err
+}
+
+func (g *testGateway) serveTunnel(r io.Reader, w io.Writer) {
+	var body []byte
+
+	expect := func(want packetType) bool {
+		var (
+			t   packetType
+			err error
+		)
+
+		if t, body, err = readPacket(r); err != nil || t != want {
+			g.t.Errorf("packet %d expected, got %d: %v", want, t, err)
+
+			return false
+		}
+
+		return true
+	}
+
+	if !expect(packetTypeHandshakeRequest) {
+		return
+	}
+
+	_, _ = w.Write(newPacket(packetTypeHandshakeResponse, []byte{0, 0, 0, 0, 1, 0, 0, 0, 0, 0}))
+
+	if !expect(packetTypeTunnelCreate) {
+		return
+	}
+
+	_, _ = w.Write(newPacket(packetTypeTunnelResponse, []byte{
+		0, 0, 0, 0, 0, 0, // serverVersion, statusCode
+		0x03, 0x00, 0x00, 0x00, // HTTP_TUNNEL_RESPONSE_FIELD_TUNNEL_ID | HTTP_TUNNEL_RESPONSE_FIELD_CAPS, reserved
+		0x07, 0x00, 0x00, 0x00, // tunnelId
+		0x02, 0x00, 0x00, 0x00, // caps HTTP_CAPABILITY_IDLE_TIMEOUT
+	}))
+
+	if !expect(packetTypeTunnelAuth) {
+		return
+	}
+
+	_, _ = w.Write(newPacket(packetTypeTunnelAuthResponse, []byte{
+		0, 0, 0, 0, // errorCode
+		0x02, 0x00, 0x00, 0x00, // HTTP_TUNNEL_AUTH_RESPONSE_FIELD_IDLE_TIMEOUT, reserved
+		0x1e, 0x00, 0x00, 0x00, // idleTimeout
+	}))
+
+	if !expect(packetTypeChannelCreate) {
+		return
+	}
+
+	port := binary.LittleEndian.Uint16(body[2:])
+	resource := utf16.Decode(body[8:])
+
+	if resource != "rdp.example.com" || port != 3389 {
+		_, _ = w.Write(newPacket(packetTypeChannelResponse, []byte{0xda, 0x59, 0x07, 0x80, 0, 0, 0, 0})) // E_PROXY_RAP_ACCESSDENIED
+
+		return
+	}
+
+	_, _ = w.Write(newPacket(packetTypeChannelResponse, []byte{
+		0, 0, 0, 0, // errorCode
+		0x01, 0x00, 0x00, 0x00, // HTTP_CHANNEL_RESPONSE_FIELD_CHANNELID, reserved
+		0x01, 0x00, 0x00, 0x00, // channelId
+	}))
+
+	_, _ = w.Write(newPacket(packetTypeKeepalive, nil))
+
+	for {
+		t, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch t {
+		case packetTypeData:
+			data, err := dataPacketData(body)
+			if err != nil {
+				g.t.Error(err)
+
+				return
+			}
+
+			_, _ = w.Write(newDataPacket(data))
+		case packetTypeCloseChannel:
+			_, _ = w.Write(newCloseChannelPacket(packetTypeCloseChannelResponse, 0))
+
+			return
+		}
+	}
+}
+
+func TestDial(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		transport Transport
+		webSocket bool
+	}{
+		{"HTTP", TransportHTTP, true},
+		{"WebSocket", TransportWebSocket, true},
+		{"auto WebSocket", TransportAuto, true},
+		{"auto fallback to HTTP", TransportAuto, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g, roots := newTestGateway(t, tt.webSocket)
+
+			conn, err := Dial(Config{
+				Address:   g.listener.Addr().String(),
+				Domain:    "EXAMPLE",
+				Username:  "user",
+				Password:  "password",
+				Transport: tt.transport,
+				TLSConfig: &tls.Config{RootCAs: roots},
+			}, "rdp.example.com:3389")
+			require.NoError(t, err)
+
+			defer conn.Close()
+
+			require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+			data := make([]byte, 3*maxDataLen+1)
+			_, _ = rand.Read(data)
+
+			go func() {
+				_, _ = conn.Write(data)
+			}()
+
+			echo := make([]byte, len(data))
+			_, err = io.ReadFull(conn, echo)
+			require.NoError(t, err)
+			require.True(t, bytes.Equal(data, echo))
+		})
+	}
+}
+
+func TestDial_Errors(t *testing.T) {
+	g, roots := newTestGateway(t, false)
+
+	config := Config{
+		Address:   g.listener.Addr().String(),
+		Domain:    "EXAMPLE",
+		Username:  "user",
+		Password:  "password",
+		TLSConfig: &tls.Config{RootCAs: roots},
+	}
+
+	_, err := Dial(config, "denied.example.com")
+
+	var serverErr ServerError
+	require.True(t, errors.As(err, &serverErr))
+	require.Equal(t, uint32(0x800759DA), serverErr.Code)
+
+	wrongPassword := config
+	wrongPassword.Password = "wrong"
+
+	_, err = Dial(wrongPassword, "rdp.example.com:3389")
+	require.ErrorIs(t, err, ErrAuthenticationFailed)
+
+	webSocket := config
+	webSocket.Transport = TransportWebSocket
+
+	_, err = Dial(webSocket, "rdp.example.com:3389")
+	require.ErrorIs(t, err, ErrWebSocketNotSupported)
+}
+
+// recordingForward dials the gateway directly and records the addresses.
+type recordingForward struct {
+	mu        sync.Mutex
+	addresses []string
+}
+
+func (f *recordingForward) Dial(network, address string) (net.Conn, error) {
+	f.mu.Lock()
+	f.addresses = append(f.addresses, address)
+	f.mu.Unlock()
+
+	return net.Dial(network, address)
+}
+
+func TestDial_Forward(t *testing.T) {
+	g, roots := newTestGateway(t, true)
+	forward := &recordingForward{}
+
+	conn, err := Dial(Config{
+		Address:   g.listener.Addr().String(),
+		Domain:    "EXAMPLE",
+		Username:  "user",
+		Password:  "password",
+		TLSConfig: &tls.Config{RootCAs: roots},
+		Forward:   forward,
+	}, "rdp.example.com:3389")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	require.NotEmpty(t, forward.addresses)
+
+	for _, address := range forward.addresses {
+		require.Equal(t, g.listener.Addr().String(), address)
+	}
+}
+
+func TestDialContext_Canceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() { // never answers the TLS handshake
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	_, err = DialContext(ctx, Config{
+		Address:  listener.Addr().String(),
+		Username: "user",
+		Password: "password",
+	}, "rdp.example.com:3389")
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), defaultTimeout)
+}
+
+func TestOutDataConn_Write(t *testing.T) {
+	request := "GET /remoteDesktopGateway/ HTTP/1.1\r\nHost: gateway.example.com\r\n\r\n"
+
+	for _, size := range []int{1, 3, 4, len(request)} {
+		clientConn, serverConn := net.Pipe()
+
+		go func() {
+			defer clientConn.Close()
+
+			conn := &outDataConn{Conn: clientConn}
+
+			for b := []byte(request); len(b) > 0; {
+				chunk := b
+				if len(chunk) > size {
+					chunk = chunk[:size]
+				}
+
+				n, err := conn.Write(chunk)
+				if err != nil || n != len(chunk) {
+					return
+				}
+
+				b = b[n:]
+			}
+		}()
+
+		received, err := io.ReadAll(serverConn)
+		require.NoError(t, err)
+		require.Equal(t, methodOutData+request[len(http.MethodGet):], string(received), "writes of %d bytes", size)
+	}
+}
@@ -0,0 +1,376 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/lunnik9/rdp/rdp/dialctx"
+	"github.com/lunnik9/rdp/rdp/ntlm"
+)
+
+const (
+	gatewayPath = "/remoteDesktopGateway/"
+	userAgent   = "MS-RDGateway/1.0"
+
+	methodOutData = "RDG_OUT_DATA"
+	methodInData  = "RDG_IN_DATA"
+)
+
+// httpConn is a connection to the gateway with buffered responses.
+type httpConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// open opens the WebSocket transport, or the RDG_OUT_DATA and RDG_IN_DATA channels of the HTTP transport
+// when the gateway does not upgrade RDG_OUT_DATA.
+func (g *gateway) open() (*transport, error) {
+	if g.config.Transport != TransportHTTP {
+		ws, err := g.openWebSocket()
+		switch {
+		case err == nil:
+			log.Println("RDG: WebSocket transport")
+
+			conn := ws.conn.UnderlyingConn()
+
+			return &transport{r: ws, w: ws, in: conn, out: conn}, nil
+		case !errors.Is(err, ErrWebSocketNotSupported) || g.config.Transport == TransportWebSocket:
+			return nil, err
+		}
+
+		// the channels of the HTTP transport do not join the dropped RDG_OUT_DATA
+		if g.connectionID, err = newGUID(); err != nil {
+			return nil, err
+		}
+	}
+
+	out, resp, err := g.openOut()
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = out.Close()
+
+		return nil, fmt.Errorf("%w: %s: %s", ErrUnexpectedHTTPStatusCode, methodOutData, resp.Status)
+	}
+
+	in, err := g.openIn()
+	if err != nil {
+		_ = out.Close()
+
+		return nil, err
+	}
+
+	log.Println("RDG: HTTP transport")
+
+	return &transport{r: resp.Body, w: httputil.NewChunkedWriter(in), in: in.Conn, out: out.Conn}, nil
+}
+
+// openWebSocket sends RDG_OUT_DATA upgraded to WebSocket with the authentication offered by the gateway.
+func (g *gateway) openWebSocket() (*webSocket, error) {
+	header := g.header()
+
+	for {
+		conn, err := g.dial()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = g.authorize(conn, methodOutData, header); err != nil {
+			_ = conn.Close()
+
+			return nil, err
+		}
+
+		ws, resp, err := g.dialWebSocket(conn, header)
+
+		switch {
+		case err == nil:
+			return ws, nil
+		case resp == nil:
+			return nil, fmt.Errorf("%s WebSocket handshake: %w", methodOutData, err)
+		case resp.StatusCode == http.StatusUnauthorized && g.scheme == "":
+			if g.scheme = authenticationScheme(resp); g.scheme == "" {
+				return nil, ErrUnsupportedAuthentication
+			}
+		case resp.StatusCode == http.StatusUnauthorized:
+			return nil, ErrAuthenticationFailed
+		case resp.StatusCode == http.StatusSwitchingProtocols:
+			return nil, ErrInvalidWebSocketAccept
+		case resp.StatusCode == http.StatusOK:
+			return nil, ErrWebSocketNotSupported
+		default:
+			return nil, fmt.Errorf("%w: %s: %s", ErrUnexpectedHTTPStatusCode, methodOutData, resp.Status)
+		}
+	}
+}
+
+// openOut sends RDG_OUT_DATA with the authentication offered by the gateway,
+// the response body of the HTTP transport carries the packets from the gateway.
+func (g *gateway) openOut() (*httpConn, *http.Response, error) {
+	conn, err := g.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := g.header()
+	header.Set("Content-Length", "0")
+
+	if g.scheme != "" { // learned by the WebSocket handshake
+		if err = g.authorize(conn, methodOutData, header); err != nil {
+			_ = conn.Close()
+
+			return nil, nil, err
+		}
+	}
+
+	resp, err := g.roundTrip(conn, methodOutData, header)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return conn, resp, nil
+	}
+
+	if g.scheme != "" {
+		_ = conn.Close()
+
+		return nil, nil, ErrAuthenticationFailed
+	}
+
+	if g.scheme = authenticationScheme(resp); g.scheme == "" {
+		_ = conn.Close()
+
+		return nil, nil, ErrUnsupportedAuthentication
+	}
+
+	if resp.Close {
+		_ = conn.Close()
+
+		if conn, err = g.dial(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = g.authorize(conn, methodOutData, header); err == nil {
+		resp, err = g.roundTrip(conn, methodOutData, header)
+	}
+
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		err = ErrAuthenticationFailed
+	}
+
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, nil, err
+	}
+
+	return conn, resp, nil
+}
+
+// openIn sends RDG_IN_DATA with the chunked body carrying the packets to the gateway,
+// the gateway responds when the body ends.
+func (g *gateway) openIn() (*httpConn, error) {
+	conn, err := g.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	header := g.header()
+	header.Set("Content-Length", "0")
+
+	if err = g.authorize(conn, methodInData, header); err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	header.Del("Content-Length")
+	header.Set("Transfer-Encoding", "chunked")
+
+	if err = g.send(conn, methodInData, header); err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// authorize sets the Authorization of the request, NTLM takes the challenge round trip
+// with the negotiate message.
+func (g *gateway) authorize(conn *httpConn, method string, header http.Header) error {
+	switch g.scheme {
+	case "":
+		return nil
+	case "Basic":
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(g.basicUsername()+":"+g.config.Password)))
+
+		return nil
+	}
+
+	n := ntlm.NewNTLMv2(g.config.Domain, g.config.Username, g.config.Password)
+
+	negotiate := header.Clone()
+	negotiate.Set("Content-Length", "0")
+	negotiate.Set("Authorization", g.scheme+" "+base64.StdEncoding.EncodeToString(n.Negotiate()))
+
+	resp, err := g.roundTrip(conn, method, negotiate)
+	if err != nil {
+		return err
+	}
+
+	challenge, err := authenticationToken(resp, g.scheme)
+	if err != nil {
+		return err
+	}
+
+	authenticate, err := n.Authenticate(challenge)
+	if err != nil {
+		return fmt.Errorf("NTLM: %w", err)
+	}
+
+	header.Set("Authorization", g.scheme+" "+base64.StdEncoding.EncodeToString(authenticate))
+
+	return nil
+}
+
+func (g *gateway) basicUsername() string {
+	if g.config.Domain == "" {
+		return g.config.Username
+	}
+
+	return g.config.Domain + "\\" + g.config.Username
+}
+
+// authenticationScheme returns the scheme of WWW-Authenticate preferring NTLM.
+func authenticationScheme(resp *http.Response) string {
+	var scheme string
+
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		name := strings.Fields(challenge + " ")[0]
+
+		switch {
+		case strings.EqualFold(name, "NTLM"):
+			return "NTLM"
+		case strings.EqualFold(name, "Negotiate"): // raw NTLM tokens are accepted
+			scheme = "Negotiate"
+		case strings.EqualFold(name, "Basic") && scheme == "":
+			scheme = "Basic"
+		}
+	}
+
+	return scheme
+}
+
+// authenticationToken returns the token of the scheme challenge in the 401 response.
+func authenticationToken(resp *http.Response, scheme string) ([]byte, error) {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedHTTPStatusCode, resp.Status)
+	}
+
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		fields := strings.Fields(challenge)
+		if len(fields) != 2 || !strings.EqualFold(fields[0], scheme) {
+			continue
+		}
+
+		return base64.StdEncoding.DecodeString(fields[1])
+	}
+
+	return nil, ErrAuthenticationFailed
+}
+
+func (g *gateway) dial() (*httpConn, error) {
+	config := &tls.Config{}
+	if g.config.TLSConfig != nil {
+		config = g.config.TLSConfig.Clone()
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = g.host
+	}
+
+	forward := g.config.Forward
+	if forward == nil {
+		forward = &net.Dialer{Deadline: g.deadline}
+	}
+
+	tcpConn, err := dialctx.Dial(g.ctx, forward, "tcp", g.address)
+	if err != nil {
+		return nil, fmt.Errorf("gateway connect: %w", err)
+	}
+
+	if err = tcpConn.SetDeadline(g.deadline); err != nil {
+		_ = tcpConn.Close()
+
+		return nil, err
+	}
+
+	g.stops = append(g.stops, dialctx.InterruptOnDone(g.ctx, tcpConn))
+
+	conn := tls.Client(tcpConn, config)
+	if err = conn.Handshake(); err != nil {
+		_ = tcpConn.Close()
+
+		return nil, fmt.Errorf("gateway TLS handshake: %w", err)
+	}
+
+	return &httpConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (g *gateway) header() http.Header {
+	return http.Header{
+		"Accept":            {"*/*"},
+		"Cache-Control":     {"no-cache"},
+		"Pragma":            {"no-cache"},
+		"User-Agent":        {userAgent},
+		"RDG-Connection-Id": {g.connectionID},
+	}
+}
+
+func (g *gateway) send(conn *httpConn, method string, header http.Header) error {
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, gatewayPath, g.host)
+	_ = header.Write(buf)
+	buf.WriteString("\r\n")
+
+	_, err := conn.Write(buf.Bytes())
+
+	return err
+}
+
+// roundTrip sends the request and reads the response, the body of 401 responses is discarded.
+func (g *gateway) roundTrip(conn *httpConn, method string, header http.Header) (*http.Response, error) {
+	if err := g.send(conn, method, header); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(conn.r, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s response: %w", method, err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	return resp, nil
+}
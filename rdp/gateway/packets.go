@@ -0,0 +1,346 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+// packetType HTTP_PACKET_HEADER packetType.
+type packetType uint16
+
+const (
+	// packetTypeHandshakeRequest PKT_TYPE_HANDSHAKE_REQUEST
+	packetTypeHandshakeRequest packetType = 0x01
+
+	// packetTypeHandshakeResponse PKT_TYPE_HANDSHAKE_RESPONSE
+	packetTypeHandshakeResponse packetType = 0x02
+
+	// packetTypeTunnelCreate PKT_TYPE_TUNNEL_CREATE
+	packetTypeTunnelCreate packetType = 0x04
+
+	// packetTypeTunnelResponse PKT_TYPE_TUNNEL_RESPONSE
+	packetTypeTunnelResponse packetType = 0x05
+
+	// packetTypeTunnelAuth PKT_TYPE_TUNNEL_AUTH
+	packetTypeTunnelAuth packetType = 0x06
+
+	// packetTypeTunnelAuthResponse PKT_TYPE_TUNNEL_AUTH_RESPONSE
+	packetTypeTunnelAuthResponse packetType = 0x07
+
+	// packetTypeChannelCreate PKT_TYPE_CHANNEL_CREATE
+	packetTypeChannelCreate packetType = 0x08
+
+	// packetTypeChannelResponse PKT_TYPE_CHANNEL_RESPONSE
+	packetTypeChannelResponse packetType = 0x09
+
+	// packetTypeData PKT_TYPE_DATA
+	packetTypeData packetType = 0x0A
+
+	// packetTypeServiceMessage PKT_TYPE_SERVICE_MESSAGE
+	packetTypeServiceMessage packetType = 0x0B
+
+	// packetTypeReauthMessage PKT_TYPE_REAUTH_MESSAGE
+	packetTypeReauthMessage packetType = 0x0C
+
+	// packetTypeKeepalive PKT_TYPE_KEEPALIVE
+	packetTypeKeepalive packetType = 0x0D
+
+	// packetTypeCloseChannel PKT_TYPE_CLOSE_CHANNEL
+	packetTypeCloseChannel packetType = 0x10
+
+	// packetTypeCloseChannelResponse PKT_TYPE_CLOSE_CHANNEL_RESPONSE
+	packetTypeCloseChannelResponse packetType = 0x11
+)
+
+const (
+	packetHeaderLen = 8
+
+	// maxDataLen of a data packet, the length is a 16-bit field
+	maxDataLen = 0x8000
+
+	// capabilityIdleTimeout HTTP_CAPABILITY_IDLE_TIMEOUT
+	capabilityIdleTimeout uint32 = 0x00000002
+
+	// channelProtocolRDP protocol of the channel to the RDP server
+	channelProtocolRDP uint16 = 3
+)
+
+// newPacket prepends HTTP_PACKET_HEADER to the packet body.
+func newPacket(t packetType, body []byte) []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint16(t))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0)) // reserved
+	_ = binary.Write(buf, binary.LittleEndian, uint32(packetHeaderLen+len(body)))
+	buf.Write(body)
+
+	return buf.Bytes()
+}
+
+// readPacket reads HTTP_PACKET_HEADER and the packet body.
+func readPacket(r io.Reader) (packetType, []byte, error) {
+	header := make([]byte, packetHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[4:])
+	if length < packetHeaderLen {
+		return 0, nil, ErrInvalidPacket
+	}
+
+	body := make([]byte, length-packetHeaderLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return packetType(binary.LittleEndian.Uint16(header)), body, nil
+}
+
+// handshakeRequest HTTP_HANDSHAKE_REQUEST_PACKET
+type handshakeRequest struct {
+	ExtendedAuth uint16
+}
+
+func (p *handshakeRequest) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(1)                                           // verMajor
+	buf.WriteByte(0)                                           // verMinor
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0))      // clientVersion
+	_ = binary.Write(buf, binary.LittleEndian, p.ExtendedAuth) // HTTP_EXTENDED_AUTH_NONE
+
+	return newPacket(packetTypeHandshakeRequest, buf.Bytes())
+}
+
+// handshakeResponse HTTP_HANDSHAKE_RESPONSE_PACKET
+type handshakeResponse struct {
+	ErrorCode     uint32
+	VerMajor      uint8
+	VerMinor      uint8
+	ServerVersion uint16
+	ExtendedAuth  uint16
+}
+
+func (p *handshakeResponse) Deserialize(wire io.Reader) error {
+	return binary.Read(wire, binary.LittleEndian, p)
+}
+
+// tunnelCreate HTTP_TUNNEL_PACKET
+type tunnelCreate struct {
+	CapsFlags uint32
+}
+
+func (p *tunnelCreate) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, p.CapsFlags)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0)) // fieldsPresent
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0)) // reserved
+
+	return newPacket(packetTypeTunnelCreate, buf.Bytes())
+}
+
+const (
+	// tunnelResponseFieldTunnelID HTTP_TUNNEL_RESPONSE_FIELD_TUNNEL_ID
+	tunnelResponseFieldTunnelID uint16 = 0x0001
+
+	// tunnelResponseFieldCaps HTTP_TUNNEL_RESPONSE_FIELD_CAPS
+	tunnelResponseFieldCaps uint16 = 0x0002
+)
+
+// tunnelResponse HTTP_TUNNEL_RESPONSE, the optional fields following the capabilities are ignored.
+type tunnelResponse struct {
+	ServerVersion uint16
+	StatusCode    uint32
+	TunnelID      uint32
+	CapsFlags     uint32
+}
+
+func (p *tunnelResponse) Deserialize(wire io.Reader) error {
+	var (
+		fieldsPresent, reserved uint16
+		err                     error
+	)
+
+	if err = binary.Read(wire, binary.LittleEndian, &p.ServerVersion); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &p.StatusCode); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &fieldsPresent); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &reserved); err != nil {
+		return err
+	}
+
+	if fieldsPresent&tunnelResponseFieldTunnelID == tunnelResponseFieldTunnelID {
+		if err = binary.Read(wire, binary.LittleEndian, &p.TunnelID); err != nil {
+			return err
+		}
+	}
+
+	if fieldsPresent&tunnelResponseFieldCaps == tunnelResponseFieldCaps {
+		if err = binary.Read(wire, binary.LittleEndian, &p.CapsFlags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tunnelAuth HTTP_TUNNEL_AUTH_PACKET
+type tunnelAuth struct {
+	ClientName string
+}
+
+func (p *tunnelAuth) Serialize() []byte {
+	clientName := utf16.Encode(p.ClientName + "\x00")
+
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0)) // fieldsPresent
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(clientName)))
+	buf.Write(clientName)
+
+	return newPacket(packetTypeTunnelAuth, buf.Bytes())
+}
+
+const (
+	// tunnelAuthResponseFieldRedirFlags HTTP_TUNNEL_AUTH_RESPONSE_FIELD_REDIR_FLAGS
+	tunnelAuthResponseFieldRedirFlags uint16 = 0x0001
+
+	// tunnelAuthResponseFieldIdleTimeout HTTP_TUNNEL_AUTH_RESPONSE_FIELD_IDLE_TIMEOUT
+	tunnelAuthResponseFieldIdleTimeout uint16 = 0x0002
+)
+
+// tunnelAuthResponse HTTP_TUNNEL_AUTH_RESPONSE, the statement of health response is ignored.
+type tunnelAuthResponse struct {
+	ErrorCode   uint32
+	RedirFlags  uint32
+	IdleTimeout uint32 // minutes
+}
+
+func (p *tunnelAuthResponse) Deserialize(wire io.Reader) error {
+	var (
+		fieldsPresent, reserved uint16
+		err                     error
+	)
+
+	if err = binary.Read(wire, binary.LittleEndian, &p.ErrorCode); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &fieldsPresent); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &reserved); err != nil {
+		return err
+	}
+
+	if fieldsPresent&tunnelAuthResponseFieldRedirFlags == tunnelAuthResponseFieldRedirFlags {
+		if err = binary.Read(wire, binary.LittleEndian, &p.RedirFlags); err != nil {
+			return err
+		}
+	}
+
+	if fieldsPresent&tunnelAuthResponseFieldIdleTimeout == tunnelAuthResponseFieldIdleTimeout {
+		if err = binary.Read(wire, binary.LittleEndian, &p.IdleTimeout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// channelCreate HTTP_CHANNEL_PACKET
+type channelCreate struct {
+	Resource string
+	Port     uint16
+}
+
+func (p *channelCreate) Serialize() []byte {
+	resource := utf16.Encode(p.Resource + "\x00")
+
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(1) // numResources
+	buf.WriteByte(0) // numAltResource
+	_ = binary.Write(buf, binary.LittleEndian, p.Port)
+	_ = binary.Write(buf, binary.LittleEndian, channelProtocolRDP)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(resource)))
+	buf.Write(resource)
+
+	return newPacket(packetTypeChannelCreate, buf.Bytes())
+}
+
+// channelResponseFieldChannelID HTTP_CHANNEL_RESPONSE_FIELD_CHANNELID
+const channelResponseFieldChannelID uint16 = 0x0001
+
+// channelResponse HTTP_CHANNEL_RESPONSE, the optional fields following the channel ID are ignored.
+type channelResponse struct {
+	ErrorCode uint32
+	ChannelID uint32
+}
+
+func (p *channelResponse) Deserialize(wire io.Reader) error {
+	var (
+		fieldsPresent, reserved uint16
+		err                     error
+	)
+
+	if err = binary.Read(wire, binary.LittleEndian, &p.ErrorCode); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &fieldsPresent); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &reserved); err != nil {
+		return err
+	}
+
+	if fieldsPresent&channelResponseFieldChannelID == channelResponseFieldChannelID {
+		return binary.Read(wire, binary.LittleEndian, &p.ChannelID)
+	}
+
+	return nil
+}
+
+// newDataPacket HTTP_DATA_PACKET
+func newDataPacket(data []byte) []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(data)))
+	buf.Write(data)
+
+	return newPacket(packetTypeData, buf.Bytes())
+}
+
+// dataPacketData returns the data of HTTP_DATA_PACKET.
+func dataPacketData(body []byte) ([]byte, error) {
+	if len(body) < 2 || int(binary.LittleEndian.Uint16(body)) > len(body)-2 {
+		return nil, ErrInvalidPacket
+	}
+
+	return body[2 : 2+binary.LittleEndian.Uint16(body)], nil
+}
+
+// newCloseChannelPacket HTTP_CLOSE_PACKET of the PKT_TYPE_CLOSE_CHANNEL or PKT_TYPE_CLOSE_CHANNEL_RESPONSE.
+func newCloseChannelPacket(t packetType, statusCode uint32) []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, statusCode)
+
+	return newPacket(t, buf.Bytes())
+}
@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// webSocket carries the gateway packets in binary WebSocket messages.
+type webSocket struct {
+	conn   *websocket.Conn
+	reader io.Reader // of the current message
+
+	writeMu sync.Mutex
+}
+
+func newWebSocket(conn *websocket.Conn) *webSocket {
+	return &webSocket{
+		conn: conn,
+	}
+}
+
+func (ws *webSocket) Read(b []byte) (int, error) {
+	for {
+		if ws.reader == nil {
+			_, reader, err := ws.conn.NextReader()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					return 0, io.EOF
+				}
+
+				return 0, err
+			}
+
+			ws.reader = reader
+		}
+
+		n, err := ws.reader.Read(b)
+		if err == io.EOF {
+			ws.reader = nil
+
+			if n == 0 {
+				continue
+			}
+
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+func (ws *webSocket) Write(b []byte) (int, error) {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if err := ws.conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Close sends the close frame, the connection is closed by the transport.
+func (ws *webSocket) Close() error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	return ws.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// dialWebSocket sends the opening handshake over the connection, the response is returned
+// when the gateway does not upgrade. The connection is closed on failure.
+func (g *gateway) dialWebSocket(conn *httpConn, header http.Header) (*webSocket, *http.Response, error) {
+	dialer := websocket.Dialer{
+		NetDialContext: func(context.Context, string, string) (net.Conn, error) {
+			return &outDataConn{Conn: conn.Conn}, nil
+		},
+	}
+
+	header = header.Clone()
+	header.Set("Host", g.host)
+
+	wsConn, resp, err := dialer.Dial("ws://"+g.address+gatewayPath, header)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	if err = wsConn.UnderlyingConn().SetDeadline(g.deadline); err != nil { // cleared by the dialer
+		_ = wsConn.Close()
+
+		return nil, nil, err
+	}
+
+	return newWebSocket(wsConn), resp, nil
+}
+
+// outDataConn sends the opening handshake as RDG_OUT_DATA, the method of the WebSocket transport,
+// instead of GET of websocket.Dialer. The start of the request is held back until the method is complete,
+// however the dialer splits the writes.
+type outDataConn struct {
+	net.Conn
+	pending   []byte // of the request line before the method is known
+	rewritten bool
+}
+
+func (c *outDataConn) Write(b []byte) (int, error) {
+	if c.rewritten {
+		return c.Conn.Write(b)
+	}
+
+	method := []byte(http.MethodGet + " ")
+
+	c.pending = append(c.pending, b...)
+	if len(c.pending) < len(method) && bytes.HasPrefix(method, c.pending) {
+		return len(b), nil
+	}
+
+	request := c.pending
+	if bytes.HasPrefix(request, method) {
+		request = append([]byte(methodOutData), request[len(http.MethodGet):]...)
+	}
+
+	c.pending, c.rewritten = nil, true
+
+	if _, err := c.Conn.Write(request); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
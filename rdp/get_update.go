@@ -9,7 +9,49 @@ import (
 	"github.com/lunnik9/rdp/rdp/pdu"
 )
 
-func (c *client) GetUpdate() (*fastpath.UpdatePDU, error) {
+// GetUpdate returns the next update, the session is resumed transparently
+// when the connection is lost and the server sent the auto-reconnect cookie.
+func (c *Client) GetUpdate() (*fastpath.UpdatePDU, error) {
+	update, err := c.getUpdate()
+	if err == nil || !c.canAutoReconnect(err) {
+		return update, err
+	}
+
+	log.Println(fmt.Errorf("RDP: connection lost: %w", err))
+
+	if err = c.autoReconnect(); err != nil {
+		return nil, fmt.Errorf("auto-reconnect: %w", err)
+	}
+
+	return c.GetUpdate()
+}
+
+// GetUpdates returns the next complete updates, the fragmented ones are reassembled
+// up to the MaxRequestSize of the Multifragment Update Capability Set.
+func (c *Client) GetUpdates() ([]fastpath.Update, error) {
+	for {
+		updatePDU, err := c.GetUpdate()
+		if err != nil {
+			return nil, err
+		}
+
+		updates, err := updatePDU.Updates()
+		if err != nil {
+			return nil, fmt.Errorf("fastpath updates: %w", err)
+		}
+
+		updates, err = c.reassembler.Reassemble(updates)
+		if err != nil {
+			return nil, fmt.Errorf("reassemble fastpath updates: %w", err)
+		}
+
+		if len(updates) > 0 {
+			return updates, nil
+		}
+	}
+}
+
+func (c *Client) getUpdate() (*fastpath.UpdatePDU, error) {
 	protocol, err := receiveProtocol(c.buffReader)
 	if err != nil {
 		return nil, err
@@ -20,20 +62,26 @@ func (c *client) GetUpdate() (*fastpath.UpdatePDU, error) {
 		switch {
 		case err == nil: // pass
 		case errors.Is(err, pdu.ErrDeactiateAll):
-			return nil, err
+			if err = c.reactivate(); err != nil {
+				return nil, fmt.Errorf("reactivation: %w", err)
+			}
+		case errors.Is(err, errRedirected):
+			if err = c.followRedirection(); err != nil {
+				return nil, fmt.Errorf("server redirection: %w", err)
+			}
 
 		default:
 			return nil, fmt.Errorf("get X.224 update: %w", err)
 		}
 
-		return c.GetUpdate()
+		return c.getUpdate()
 	}
 
 	return c.fastPath.Receive()
 }
 
-func (c *client) getX224Update() error {
-	channelID, wire, err := c.mcsLayer.Receive()
+func (c *Client) getX224Update() error {
+	channelID, wire, err := c.secLayer.Receive()
 	if err != nil {
 		return err
 	}
@@ -52,6 +100,14 @@ func (c *client) getX224Update() error {
 		return err
 	}
 
+	if data.ServerRedirection != nil {
+		return c.handleServerRedirection(&data.ServerRedirection.RedirectionPacket)
+	}
+
+	if data.SaveSessionInfo != nil {
+		c.handleSaveSessionInfo(data.SaveSessionInfo)
+	}
+
 	if data.ShareDataHeader.PDUType2.IsErrorInfo() {
 		log.Printf("received error info: %s\n", data.ErrorInfoPDUData.String())
 	}
@@ -0,0 +1,103 @@
+package rdp
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/fastpath"
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+// fastPathUpdatePDU wraps the updates into the Fast-Path Update PDU with the 2-byte length.
+func fastPathUpdatePDU(updates ...fastpath.Update) []byte {
+	var data []byte
+
+	for i := range updates {
+		data = append(data, updates[i].Serialize()...)
+	}
+
+	frame := []byte{0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(frame[1:], uint16(len(data)+len(frame))|0x8000)
+
+	return append(frame, data...)
+}
+
+func TestClient_GetUpdatesReassembly(t *testing.T) {
+	bitmap := fastpath.Update{UpdateCode: fastpath.UpdateCodeBitmap, Bitmap: &fastpath.BitmapUpdateData{Rectangles: []fastpath.BitmapData{
+		{Width: 8, Height: 8, BitsPerPixel: 8, BitmapLength: 64, BitmapDataStream: make([]byte, 64)},
+	}}}
+	bitmap.Data = bitmap.Serialize()[3:] // updateHeader and size
+
+	pointer := fastpath.Update{UpdateCode: fastpath.UpdateCodePTRPosition, PointerPosition: &fastpath.PointerPositionUpdateData{XPos: 5, YPos: 6}}
+	pointer.Data = pointer.Serialize()[3:]
+
+	fragments := []fastpath.Update{
+		{UpdateCode: fastpath.UpdateCodeBitmap, Fragmentation: fastpath.FragmentFirst, Data: bitmap.Data[:30]},
+		{UpdateCode: fastpath.UpdateCodeBitmap, Fragmentation: fastpath.FragmentNext, Data: bitmap.Data[30:60]},
+		{UpdateCode: fastpath.UpdateCodeBitmap, Fragmentation: fastpath.FragmentLast, Data: bitmap.Data[60:]},
+	}
+
+	testCases := []struct {
+		name           string
+		maxRequestSize uint32
+		expectedErr    error
+	}{
+		{
+			name:           "reassembled",
+			maxRequestSize: pdu.MaxRequestSizeDefault,
+		},
+		{
+			name:           "exceeds max request size",
+			maxRequestSize: uint32(len(bitmap.Data) - 1),
+			expectedErr:    fastpath.ErrUpdateTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+
+			go func() {
+				for _, frame := range [][]byte{
+					fastPathUpdatePDU(pointer, fragments[0]),
+					fastPathUpdatePDU(fragments[1]),
+					fastPathUpdatePDU(fragments[2], pointer),
+				} {
+					if _, err := serverConn.Write(frame); err != nil {
+						return
+					}
+				}
+
+				_, _ = io.Copy(io.Discard, serverConn)
+			}()
+
+			settings := pdu.NewClientSettings(800, 600)
+			settings.MaxRequestSize = tc.maxRequestSize
+
+			c := Client{settings: *settings}
+			c.setConn(clientConn)
+
+			require.NoError(t, clientConn.SetDeadline(time.Now().Add(5*time.Second)))
+
+			updates, err := c.GetUpdates()
+			require.NoError(t, err)
+			require.Equal(t, []fastpath.Update{pointer}, updates)
+
+			updates, err = c.GetUpdates()
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, []fastpath.Update{bitmap, pointer}, updates)
+		})
+	}
+}
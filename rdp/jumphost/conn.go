@@ -0,0 +1,36 @@
+package jumphost
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Conn is the channel to the RDP server, it owns the SSH connection to the jump host.
+type Conn struct {
+	net.Conn
+	tcpConn net.Conn
+	client  *ssh.Client
+}
+
+// Close closes the channel and the connection to the jump host.
+func (c *Conn) Close() error {
+	_ = c.Conn.Close()
+
+	return c.client.Close()
+}
+
+// SetDeadline sets the deadline of the connection to the jump host, channels have no deadlines of their own,
+// so an expired deadline ends the SSH connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.tcpConn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.tcpConn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.tcpConn.SetWriteDeadline(t)
+}
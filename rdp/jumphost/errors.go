@@ -0,0 +1,8 @@
+package jumphost
+
+import "errors"
+
+var (
+	ErrNoAuthMethod     = errors.New("no SSH authentication method, password or private key required")
+	ErrNoHostKeyChecker = errors.New("no SSH host key verification, known_hosts files or callback required")
+)
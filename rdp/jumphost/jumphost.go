@@ -0,0 +1,172 @@
+// Package jumphost tunnels RDP connections through an SSH jump host in direct-tcpip channels, RFC 4254 7.2.
+package jumphost
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/lunnik9/rdp/rdp/dialctx"
+)
+
+// Forward opens the connections to the jump host, *net.Dialer and proxy.Dialer are forward dialers.
+type Forward interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// Config of the connection to the jump host.
+type Config struct {
+	Address         string // host[:port] of the jump host, port 22 by default
+	Username        string
+	Password        string
+	PrivateKey      []byte // PEM encoded private key
+	Passphrase      string // of the private key
+	KnownHostsFiles []string
+	HostKeyCallback ssh.HostKeyCallback // verification of the host key, overrides KnownHostsFiles
+	Forward         Forward             // TCP by default
+	Timeout         time.Duration       // of the SSH handshake, 10 seconds by default
+}
+
+const (
+	defaultSSHPort = "22"
+	defaultTimeout = 10 * time.Second
+)
+
+// Dial connects to the jump host and opens the channel to the RDP server at target host:port.
+func Dial(config Config, target string) (*Conn, error) {
+	return DialContext(context.Background(), config, target)
+}
+
+// DialContext is Dial canceled by ctx, the cancellation interrupts the SSH handshake and the channel opening.
+func DialContext(ctx context.Context, config Config, target string) (*Conn, error) {
+	clientConfig, err := config.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	address := config.Address
+	if _, _, err = net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultSSHPort)
+	}
+
+	forward := config.Forward
+	if forward == nil {
+		forward = &net.Dialer{Timeout: clientConfig.Timeout}
+	}
+
+	tcpConn, err := dialctx.Dial(ctx, forward, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("jump host connect: %w", err)
+	}
+
+	if err = tcpConn.SetDeadline(dialctx.Deadline(ctx, clientConfig.Timeout)); err != nil {
+		_ = tcpConn.Close()
+
+		return nil, err
+	}
+
+	stop := dialctx.InterruptOnDone(ctx, tcpConn)
+	defer stop()
+
+	sshConn, channels, requests, err := ssh.NewClientConn(tcpConn, address, clientConfig)
+	if err != nil {
+		_ = tcpConn.Close()
+
+		return nil, fmt.Errorf("jump host handshake: %w", contextErr(ctx, err))
+	}
+
+	client := ssh.NewClient(sshConn, channels, requests)
+
+	channel, err := client.Dial("tcp", target)
+	if err != nil {
+		_ = client.Close()
+
+		return nil, fmt.Errorf("jump host channel to %s: %w", target, contextErr(ctx, err))
+	}
+
+	stop()
+
+	if err = ctx.Err(); err != nil {
+		_ = client.Close()
+
+		return nil, err
+	}
+
+	if err = tcpConn.SetDeadline(time.Time{}); err != nil {
+		_ = client.Close()
+
+		return nil, err
+	}
+
+	return &Conn{
+		Conn:    channel,
+		tcpConn: tcpConn,
+		client:  client,
+	}, nil
+}
+
+// contextErr returns the error of ctx when the cancellation interrupted the handshake.
+func contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+// clientConfig returns the SSH client configuration with the password and public key authentication.
+func (c *Config) clientConfig() (*ssh.ClientConfig, error) {
+	clientConfig := ssh.ClientConfig{
+		User:            c.Username,
+		HostKeyCallback: c.HostKeyCallback,
+		Timeout:         c.Timeout,
+	}
+
+	if clientConfig.Timeout == 0 {
+		clientConfig.Timeout = defaultTimeout
+	}
+
+	if len(c.PrivateKey) != 0 {
+		signer, err := c.signer()
+		if err != nil {
+			return nil, fmt.Errorf("private key: %w", err)
+		}
+
+		clientConfig.Auth = append(clientConfig.Auth, ssh.PublicKeys(signer))
+	}
+
+	if c.Password != "" {
+		clientConfig.Auth = append(clientConfig.Auth, ssh.Password(c.Password))
+	}
+
+	if len(clientConfig.Auth) == 0 {
+		return nil, ErrNoAuthMethod
+	}
+
+	if clientConfig.HostKeyCallback == nil {
+		if len(c.KnownHostsFiles) == 0 {
+			return nil, ErrNoHostKeyChecker
+		}
+
+		hostKeyCallback, err := knownhosts.New(c.KnownHostsFiles...)
+		if err != nil {
+			return nil, fmt.Errorf("known hosts: %w", err)
+		}
+
+		clientConfig.HostKeyCallback = hostKeyCallback
+	}
+
+	return &clientConfig, nil
+}
+
+func (c *Config) signer() (ssh.Signer, error) {
+	if c.Passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(c.PrivateKey, []byte(c.Passphrase))
+	}
+
+	return ssh.ParsePrivateKey(c.PrivateKey)
+}
@@ -0,0 +1,277 @@
+package jumphost
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testJumpHost is an in-process SSH server relaying direct-tcpip channels.
+type testJumpHost struct {
+	address   string
+	hostKey   ssh.Signer
+	clientKey []byte // PEM of the authorized client key
+}
+
+func newTestJumpHost(t *testing.T) *testJumpHost {
+	_, hostPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	hostKey, err := ssh.NewSignerFromKey(hostPrivateKey)
+	require.NoError(t, err)
+
+	_, clientPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	clientBlock, err := ssh.MarshalPrivateKey(clientPrivateKey, "")
+	require.NoError(t, err)
+
+	clientSigner, err := ssh.NewSignerFromKey(clientPrivateKey)
+	require.NoError(t, err)
+
+	authorizedKey := clientSigner.PublicKey().Marshal()
+
+	config := ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "user" && string(password) == "password" {
+				return nil, nil
+			}
+
+			return nil, ErrNoAuthMethod
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "user" && string(key.Marshal()) == string(authorizedKey) {
+				return nil, nil
+			}
+
+			return nil, ErrNoAuthMethod
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSSH(conn, &config)
+		}
+	}()
+
+	return &testJumpHost{
+		address:   listener.Addr().String(),
+		hostKey:   hostKey,
+		clientKey: pem.EncodeToMemory(clientBlock),
+	}
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "direct-tcpip only")
+
+			continue
+		}
+
+		go relay(newChannel)
+	}
+}
+
+// relay connects the channel to the target of the direct-tcpip payload, RFC 4254 7.2.
+func relay(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+
+		return
+	}
+	defer target.Close()
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	go ssh.DiscardRequests(requests)
+	go func() { _, _ = io.Copy(target, channel) }()
+
+	_, _ = io.Copy(channel, target)
+}
+
+// knownHosts writes the known_hosts file with the host key of the address.
+func knownHosts(t *testing.T, address string, key ssh.PublicKey) string {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(address)}, key)
+
+	require.NoError(t, os.WriteFile(path, []byte(line+"\n"), 0o600))
+
+	return path
+}
+
+func echoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestDial(t *testing.T) {
+	jumpHost := newTestJumpHost(t)
+	target := echoServer(t)
+	knownHostsFile := knownHosts(t, jumpHost.address, jumpHost.hostKey.PublicKey())
+
+	for name, config := range map[string]Config{
+		"password":    {Username: "user", Password: "password"},
+		"private key": {Username: "user", PrivateKey: jumpHost.clientKey},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config.Address = jumpHost.address
+			config.KnownHostsFiles = []string{knownHostsFile}
+
+			conn, err := NewDialer(config).Dial("tcp", target)
+			require.NoError(t, err)
+
+			defer conn.Close()
+
+			message := []byte("RDP through the jump host")
+
+			_, err = conn.Write(message)
+			require.NoError(t, err)
+
+			reply := make([]byte, len(message))
+			_, err = io.ReadFull(conn, reply)
+			require.NoError(t, err)
+			require.Equal(t, message, reply)
+		})
+	}
+}
+
+func TestDial_Errors(t *testing.T) {
+	jumpHost := newTestJumpHost(t)
+	target := echoServer(t)
+	knownHostsFile := knownHosts(t, jumpHost.address, jumpHost.hostKey.PublicKey())
+
+	otherSigner, err := ssh.NewSignerFromKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	require.NoError(t, err)
+
+	otherKnownHostsFile := knownHosts(t, jumpHost.address, otherSigner.PublicKey())
+
+	for _, tc := range []struct {
+		name   string
+		config Config
+		target string
+		err    error
+	}{
+		{"no auth method", Config{Username: "user", KnownHostsFiles: []string{knownHostsFile}}, target, ErrNoAuthMethod},
+		{"no host key check", Config{Username: "user", Password: "password"}, target, ErrNoHostKeyChecker},
+		{"wrong password", Config{Username: "user", Password: "wrong", KnownHostsFiles: []string{knownHostsFile}}, target, nil},
+		{"unknown host key", Config{Username: "user", Password: "password", KnownHostsFiles: []string{otherKnownHostsFile}}, target, nil},
+		{"refused target", Config{Username: "user", Password: "password", KnownHostsFiles: []string{knownHostsFile}}, "127.0.0.1:1", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Address = jumpHost.address
+
+			_, err := Dial(tc.config, tc.target)
+			require.Error(t, err)
+
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestDialContext_Canceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() { // never sends the SSH version
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	_, err = DialContext(ctx, Config{
+		Address:         listener.Addr().String(),
+		Username:        "user",
+		Password:        "password",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}, echoServer(t))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), defaultTimeout)
+}
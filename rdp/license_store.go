@@ -0,0 +1,70 @@
+package rdp
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LicenseStore keeps client access licenses issued by license servers,
+// so they are presented again on later connections. The servers are keyed by host on the default port 3389
+// and by [host]:port on the other ports.
+type LicenseStore interface {
+	// License returns the license issued for server or nil when there is none.
+	License(server string) ([]byte, error)
+
+	// StoreLicense remembers the license issued for server.
+	StoreLicense(server string, license []byte) error
+}
+
+// FileLicenseStore is a LicenseStore keeping one file per server in a directory.
+type FileLicenseStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewFileLicenseStore(dir string) *FileLicenseStore {
+	return &FileLicenseStore{
+		dir: dir,
+	}
+}
+
+func (s *FileLicenseStore) License(server string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	license, err := os.ReadFile(s.path(server))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	return license, err
+}
+
+func (s *FileLicenseStore) StoreLicense(server string, license []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+
+	path := s.path(server)
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, license, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// path maps the server to a file name valid on any platform.
+func (s *FileLicenseStore) path(server string) string {
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(strings.ToLower(server))
+
+	return filepath.Join(s.dir, name+".lic")
+}
@@ -0,0 +1,224 @@
+package rdp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+	"github.com/lunnik9/rdp/rdp/sec"
+)
+
+// licensingPlatformID CLIENT_OS_ID_WINNT_POST_52 | CLIENT_IMAGE_ID_MICROSOFT
+const licensingPlatformID = 0x04000000 | 0x00010000
+
+// licensing runs the licensing exchange, MS-RDPELE 1.3.3: the stored license is presented
+// or a new one is requested, the platform challenge is answered and the issued license is stored.
+func (c *Client) licensing() error {
+	var keys *sec.LicensingKeys
+
+	for {
+		log.Println("RDP: Server License")
+
+		_, flags, wire, err := c.secLayer.ReceiveWithFlags()
+		if err != nil {
+			return err
+		}
+
+		if !flags.IsSet(sec.FlagLicensePkt) {
+			return errors.New("bad license header")
+		}
+
+		var resp pdu.ServerLicensing
+		if err = resp.Deserialize(wire); err != nil {
+			return fmt.Errorf("server licensing: %w", err)
+		}
+
+		switch {
+		case resp.LicenseRequest != nil:
+			if keys, err = c.licenseRequest(resp.LicenseRequest); err != nil {
+				return fmt.Errorf("license request: %w", err)
+			}
+		case resp.PlatformChallenge != nil:
+			if keys == nil {
+				return ErrUnexpectedLicensingMessage
+			}
+
+			if err = c.platformChallenge(keys, resp.PlatformChallenge); err != nil {
+				return fmt.Errorf("platform challenge: %w", err)
+			}
+		case resp.NewLicense != nil:
+			if keys == nil { // the license is not encrypted for us
+				return nil
+			}
+
+			if err = c.newLicense(keys, resp.NewLicense); err != nil {
+				return fmt.Errorf("new license: %w", err)
+			}
+
+			return nil
+		case resp.ErrorMessage != nil:
+			if resp.ErrorMessage.IsValidClient() {
+				return nil
+			}
+
+			return fmt.Errorf("license error: errorCode = %d, stateTransition = %d",
+				resp.ErrorMessage.ErrorCode, resp.ErrorMessage.StateTransition)
+		}
+	}
+}
+
+// licenseRequest answers the Server License Request with the Client License Information
+// when a license is stored for the server, or with the Client New License Request.
+func (c *Client) licenseRequest(req *pdu.ServerLicenseRequest) (*sec.LicensingKeys, error) {
+	publicKey, err := c.licensingPublicKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	clientRandom := make([]byte, 32)
+	if _, err = rand.Read(clientRandom); err != nil {
+		return nil, err
+	}
+
+	preMasterSecret := make([]byte, 48)
+	if _, err = rand.Read(preMasterSecret); err != nil {
+		return nil, err
+	}
+
+	keys := sec.NewLicensingKeys(clientRandom, req.ServerRandom[:], preMasterSecret)
+	encryptedPreMasterSecret := sec.EncryptClientRandom(publicKey, preMasterSecret)
+
+	var license []byte
+
+	if c.licenseStore != nil {
+		if license, err = c.licenseStore.License(serverKey(c.hostname)); err != nil {
+			return nil, fmt.Errorf("license store: %w", err)
+		}
+	}
+
+	if license != nil {
+		log.Println("RDP: Client License Information")
+
+		hwid := licensingHardwareID().Serialize()
+
+		info := pdu.ClientLicenseInfo{
+			PlatformID:               licensingPlatformID,
+			ClientRandom:             clientRandom,
+			EncryptedPreMasterSecret: encryptedPreMasterSecret,
+			LicenseInfo:              license,
+			EncryptedHWID:            keys.Encrypt(hwid),
+			MACData:                  keys.MAC(hwid),
+		}
+
+		return keys, c.sendLicensing(info.Serialize())
+	}
+
+	log.Println("RDP: Client New License Request")
+
+	newLicenseRequest := pdu.ClientNewLicenseRequest{
+		PlatformID:               licensingPlatformID,
+		ClientRandom:             clientRandom,
+		EncryptedPreMasterSecret: encryptedPreMasterSecret,
+		ClientUserName:           c.username,
+		ClientMachineName:        licensingMachineName(),
+	}
+
+	return keys, c.sendLicensing(newLicenseRequest.Serialize())
+}
+
+// licensingPublicKey returns the key of the license server certificate,
+// which is the certificate of the Server Security Data when the request does not carry one.
+func (c *Client) licensingPublicKey(req *pdu.ServerLicenseRequest) (*rsa.PublicKey, error) {
+	if len(req.ServerCertificate.BlobData) == 0 {
+		if c.serverSecurityData == nil {
+			return nil, sec.ErrUnsupportedCertificate
+		}
+
+		return sec.ServerPublicKey(c.serverSecurityData.ServerCertificate)
+	}
+
+	certificate := pdu.ServerCertificate{
+		ServerCertLen: uint32(len(req.ServerCertificate.BlobData)),
+	}
+
+	if err := certificate.Deserialize(bytes.NewReader(req.ServerCertificate.BlobData)); err != nil {
+		return nil, fmt.Errorf("server certificate: %w", err)
+	}
+
+	return sec.ServerPublicKey(&certificate)
+}
+
+// platformChallenge answers the Server Platform Challenge with the decrypted challenge and the hardware ID.
+func (c *Client) platformChallenge(keys *sec.LicensingKeys, req *pdu.ServerPlatformChallenge) error {
+	challenge := keys.Decrypt(req.EncryptedPlatformChallenge.BlobData)
+	if !keys.VerifyMAC(challenge, req.MACData[:]) {
+		return ErrInvalidLicensingMAC
+	}
+
+	log.Println("RDP: Client Platform Challenge Response")
+
+	responseData := (&pdu.PlatformChallengeResponseData{Challenge: challenge}).Serialize()
+	hwid := licensingHardwareID().Serialize()
+
+	resp := pdu.ClientPlatformChallengeResponse{
+		EncryptedPlatformChallengeResponse: keys.Encrypt(responseData),
+		EncryptedHWID:                      keys.Encrypt(hwid),
+		MACData:                            keys.MAC(append(responseData, hwid...)),
+	}
+
+	return c.sendLicensing(resp.Serialize())
+}
+
+// newLicense stores the license of the Server New License or Server Upgrade License.
+func (c *Client) newLicense(keys *sec.LicensingKeys, resp *pdu.ServerNewLicense) error {
+	data := keys.Decrypt(resp.EncryptedLicenseInfo.BlobData)
+	if !keys.VerifyMAC(data, resp.MACData[:]) {
+		return ErrInvalidLicensingMAC
+	}
+
+	var info pdu.NewLicenseInfo
+	if err := info.Deserialize(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	log.Printf("RDP: New License: version = 0x%08x, scope = %s\n", info.Version, bytes.TrimRight(info.Scope, "\x00"))
+
+	if c.licenseStore == nil {
+		return nil
+	}
+
+	if err := c.licenseStore.StoreLicense(serverKey(c.hostname), info.LicenseInfo); err != nil {
+		return fmt.Errorf("license store: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) sendLicensing(data []byte) error {
+	return c.secLayer.SendWithFlags(c.userID, c.channelIDMap["global"], sec.FlagLicensePkt, data)
+}
+
+// licensingMachineName returns the name of the machine running the client.
+func licensingMachineName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "rdp-html5"
+	}
+
+	return name
+}
+
+// licensingHardwareID identifies the machine running the client, it must not change
+// for the license server to accept the stored licenses.
+func licensingHardwareID() *pdu.ClientHardwareID {
+	return &pdu.ClientHardwareID{
+		PlatformID: licensingPlatformID,
+		Data:       md5.Sum([]byte(licensingMachineName())),
+	}
+}
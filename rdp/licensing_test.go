@@ -0,0 +1,273 @@
+package rdp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+	"github.com/lunnik9/rdp/rdp/sec"
+)
+
+// licenseServer plays the server side of the licensing exchange over the pipe.
+type licenseServer struct {
+	t          *testing.T
+	conn       net.Conn
+	privateKey *rsa.PrivateKey
+}
+
+func (s *licenseServer) send(msgType pdu.LicensingMessageType, message []byte) {
+	licensing := new(bytes.Buffer)
+	_ = binary.Write(licensing, binary.LittleEndian, uint16(0x0080)) // SEC_LICENSE_PKT
+	_ = binary.Write(licensing, binary.LittleEndian, uint16(0))
+	licensing.Write([]byte{byte(msgType), 0x03})
+	_ = binary.Write(licensing, binary.LittleEndian, uint16(4+len(message)))
+	licensing.Write(message)
+
+	_, err := s.conn.Write(serverFrame(licensing.Bytes()))
+	require.NoError(s.t, err)
+}
+
+// serverFrame wraps data of the global channel into MCS Send Data Indication, X.224 Data and TPKT.
+func serverFrame(data []byte) []byte {
+	frame := []byte{0x03, 0x00, 0x00, 0x00, 0x02, 0xf0, 0x80}
+	frame = append(frame, 0x68, 0x00, 0x00, 0x03, 0xeb, 0x70, 0x80|byte(len(data)>>8), byte(len(data)))
+	frame = append(frame, data...)
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(frame)))
+
+	return frame
+}
+
+// receive returns the client licensing message type and the message following the preamble.
+func (s *licenseServer) receive() (pdu.LicensingMessageType, *bytes.Reader) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(s.conn, header)
+	require.NoError(s.t, err)
+
+	frame := make([]byte, binary.BigEndian.Uint16(header[2:])-4)
+	_, err = io.ReadFull(s.conn, frame)
+	require.NoError(s.t, err)
+
+	data := frame[3+7:] // X.224 data, MCS send data request up to the length
+	if frame[3+6]&0x80 == 0x80 {
+		data = data[1:]
+	}
+
+	require.Equal(s.t, []byte{0x80, 0x00, 0x00, 0x00}, data[:4]) // SEC_LICENSE_PKT
+
+	return pdu.LicensingMessageType(data[4]), bytes.NewReader(data[8:])
+}
+
+func (s *licenseServer) licenseRequest(serverRandom []byte) {
+	modulus := make([]byte, s.privateKey.Size())
+	s.privateKey.N.FillBytes(modulus)
+
+	cert := new(bytes.Buffer)
+	for _, v := range []uint32{1, 1, 1} { // dwVersion, dwSigAlgId, dwKeyAlgId
+		_ = binary.Write(cert, binary.LittleEndian, v)
+	}
+
+	_ = binary.Write(cert, binary.LittleEndian, uint16(0x0006)) // BB_RSA_KEY_BLOB
+	_ = binary.Write(cert, binary.LittleEndian, uint16(20+len(modulus)+8))
+
+	for _, v := range []uint32{0x31415352, uint32(len(modulus) + 8), uint32(len(modulus) * 8), uint32(len(modulus) - 1), uint32(s.privateKey.E)} {
+		_ = binary.Write(cert, binary.LittleEndian, v)
+	}
+
+	cert.Write(reverseBytes(modulus))
+	cert.Write(make([]byte, 8))
+	_ = binary.Write(cert, binary.LittleEndian, uint16(0x0008)) // BB_RSA_SIGNATURE_BLOB
+	_ = binary.Write(cert, binary.LittleEndian, uint16(8))
+	cert.Write(make([]byte, 8))
+
+	req := new(bytes.Buffer)
+	req.Write(serverRandom)
+	_ = binary.Write(req, binary.LittleEndian, uint32(0x00060000)) // dwVersion
+	_ = binary.Write(req, binary.LittleEndian, uint32(4))
+	req.Write([]byte("M\x00\x00\x00"))
+	_ = binary.Write(req, binary.LittleEndian, uint32(4))
+	req.Write([]byte("A\x00\x00\x00"))
+	req.Write((&pdu.LicensingBinaryBlob{BlobType: 0x000D, BlobData: []byte{1, 0, 0, 0}}).Serialize())
+	req.Write((&pdu.LicensingBinaryBlob{BlobType: 0x0003, BlobData: cert.Bytes()}).Serialize())
+	_ = binary.Write(req, binary.LittleEndian, uint32(1))
+	req.Write((&pdu.LicensingBinaryBlob{BlobType: 0x000E, BlobData: []byte("microsoft.com\x00")}).Serialize())
+
+	s.send(pdu.LicensingMessageTypeLicenseRequest, req.Bytes())
+}
+
+// keys reads the client random and the premaster secret common to the new license request and license info.
+func (s *licenseServer) keys(wire *bytes.Reader, serverRandom []byte) *sec.LicensingKeys {
+	var preferredKeyExchangeAlg, platformID uint32
+	require.NoError(s.t, binary.Read(wire, binary.LittleEndian, &preferredKeyExchangeAlg))
+	require.NoError(s.t, binary.Read(wire, binary.LittleEndian, &platformID))
+	require.Equal(s.t, pdu.KeyExchangeAlgRSA, preferredKeyExchangeAlg)
+
+	clientRandom := make([]byte, 32)
+	_, err := io.ReadFull(wire, clientRandom)
+	require.NoError(s.t, err)
+
+	var encryptedPreMasterSecret pdu.LicensingBinaryBlob
+	require.NoError(s.t, encryptedPreMasterSecret.Deserialize(wire))
+	require.Len(s.t, encryptedPreMasterSecret.BlobData, s.privateKey.Size()+8)
+
+	c := new(big.Int).SetBytes(reverseBytes(encryptedPreMasterSecret.BlobData[:s.privateKey.Size()]))
+	preMasterSecret := make([]byte, 48)
+	new(big.Int).Exp(c, s.privateKey.D, s.privateKey.N).FillBytes(preMasterSecret)
+
+	return sec.NewLicensingKeys(clientRandom, serverRandom, reverseBytes(preMasterSecret))
+}
+
+func (s *licenseServer) issueLicense(license []byte) {
+	serverRandom := make([]byte, 32)
+	_, _ = rand.Read(serverRandom)
+
+	s.licenseRequest(serverRandom)
+
+	msgType, wire := s.receive()
+	require.Equal(s.t, pdu.LicensingMessageTypeNewLicenseRequest, msgType)
+
+	keys := s.keys(wire, serverRandom)
+
+	var userName pdu.LicensingBinaryBlob
+	require.NoError(s.t, userName.Deserialize(wire))
+	require.Equal(s.t, []byte("user\x00"), userName.BlobData)
+
+	challenge := []byte("TEST\x00\x00\x00\x00\x00\x00")
+
+	platformChallenge := new(bytes.Buffer)
+	_ = binary.Write(platformChallenge, binary.LittleEndian, uint32(0))
+	platformChallenge.Write((&pdu.LicensingBinaryBlob{BlobType: 0x0009, BlobData: keys.Encrypt(challenge)}).Serialize())
+	platformChallenge.Write(keys.MAC(challenge))
+
+	s.send(pdu.LicensingMessageTypePlatformChallenge, platformChallenge.Bytes())
+
+	msgType, wire = s.receive()
+	require.Equal(s.t, pdu.LicensingMessageTypePlatformChallengeResponse, msgType)
+
+	var encryptedResponse, encryptedHWID pdu.LicensingBinaryBlob
+	require.NoError(s.t, encryptedResponse.Deserialize(wire))
+	require.NoError(s.t, encryptedHWID.Deserialize(wire))
+
+	mac := make([]byte, 16)
+	_, err := io.ReadFull(wire, mac)
+	require.NoError(s.t, err)
+
+	response, hwid := keys.Decrypt(encryptedResponse.BlobData), keys.Decrypt(encryptedHWID.BlobData)
+	require.Equal(s.t, challenge, response[8:])
+	require.Len(s.t, hwid, 20)
+	require.True(s.t, keys.VerifyMAC(append(response, hwid...), mac))
+
+	info := new(bytes.Buffer)
+	_ = binary.Write(info, binary.LittleEndian, uint32(0x00060000))
+
+	for _, field := range [][]byte{[]byte("microsoft.com\x00"), []byte("M\x00\x00\x00"), []byte("A\x00\x00\x00"), license} {
+		_ = binary.Write(info, binary.LittleEndian, uint32(len(field)))
+		info.Write(field)
+	}
+
+	newLicense := new(bytes.Buffer)
+	newLicense.Write((&pdu.LicensingBinaryBlob{BlobType: 0x0009, BlobData: keys.Encrypt(info.Bytes())}).Serialize())
+	newLicense.Write(keys.MAC(info.Bytes()))
+
+	s.send(pdu.LicensingMessageTypeNewLicense, newLicense.Bytes())
+}
+
+func (s *licenseServer) acceptLicense(license []byte) {
+	serverRandom := make([]byte, 32)
+	_, _ = rand.Read(serverRandom)
+
+	s.licenseRequest(serverRandom)
+
+	msgType, wire := s.receive()
+	require.Equal(s.t, pdu.LicensingMessageTypeLicenseInfo, msgType)
+
+	keys := s.keys(wire, serverRandom)
+
+	var licenseInfo, encryptedHWID pdu.LicensingBinaryBlob
+	require.NoError(s.t, licenseInfo.Deserialize(wire))
+	require.NoError(s.t, encryptedHWID.Deserialize(wire))
+	require.Equal(s.t, license, licenseInfo.BlobData)
+
+	mac := make([]byte, 16)
+	_, err := io.ReadFull(wire, mac)
+	require.NoError(s.t, err)
+	require.True(s.t, keys.VerifyMAC(keys.Decrypt(encryptedHWID.BlobData), mac))
+
+	s.send(pdu.LicensingMessageTypeErrorAlert, []byte{
+		0x07, 0x00, 0x00, 0x00, // STATUS_VALID_CLIENT
+		0x02, 0x00, 0x00, 0x00, // ST_NO_TRANSITION
+		0x04, 0x00, 0x00, 0x00, // BB_ERROR_BLOB
+	})
+}
+
+func reverseBytes(data []byte) []byte {
+	reversed := make([]byte, len(data))
+
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+
+	return reversed
+}
+
+func TestClient_licensing(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	store := NewFileLicenseStore(t.TempDir())
+	license := []byte("client access license")
+	otherLicense := []byte("license of the server on the other port")
+
+	for _, tc := range []struct {
+		hostname string
+		serve    func(s *licenseServer)
+	}{
+		{"rds.example.com:3389", func(s *licenseServer) { s.issueLicense(license) }},
+		{"rds.example.com:3389", func(s *licenseServer) { s.acceptLicense(license) }},
+		{"rds.example.com:3390", func(s *licenseServer) { s.issueLicense(otherLicense) }},
+		{"rds.example.com:3390", func(s *licenseServer) { s.acceptLicense(otherLicense) }},
+	} {
+		clientConn, serverConn := net.Pipe()
+
+		c := Client{
+			hostname:     tc.hostname,
+			username:     "user",
+			licenseStore: store,
+			channelIDMap: map[string]uint16{"global": 1003},
+			userID:       1007,
+		}
+		c.setConn(clientConn)
+
+		server := licenseServer{t: t, conn: serverConn, privateKey: privateKey}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			defer serverConn.Close()
+
+			tc.serve(&server)
+		}()
+
+		require.NoError(t, c.licensing())
+
+		<-done
+
+		_ = clientConn.Close()
+	}
+
+	stored, err := store.License("rds.example.com")
+	require.NoError(t, err)
+	require.Equal(t, license, stored)
+
+	stored, err = store.License("[rds.example.com]:3390")
+	require.NoError(t, err)
+	require.Equal(t, otherLicense, stored)
+}
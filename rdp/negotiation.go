@@ -0,0 +1,119 @@
+package rdp
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+// NegotiationAttempt X.224 negotiation of the security protocol.
+type NegotiationAttempt struct {
+	RequestedProtocols pdu.NegotiationProtocol
+	SelectedProtocol   pdu.NegotiationProtocol
+	FailureCode        pdu.NegotiationFailureCode // zero when the server selected the protocol
+}
+
+// NegotiationFailureError RDP Negotiation Failure of the server.
+type NegotiationFailureError struct {
+	RequestedProtocols pdu.NegotiationProtocol
+	FailureCode        pdu.NegotiationFailureCode
+}
+
+func (e *NegotiationFailureError) Error() string {
+	return fmt.Sprintf("negotiation failure: failureCode = %d %s, requested %s",
+		e.FailureCode, e.FailureCode, e.RequestedProtocols)
+}
+
+// serverProtocols returns the security protocols the server accepts after the negotiation failure.
+func serverProtocols(failureCode pdu.NegotiationFailureCode) []pdu.NegotiationProtocol {
+	switch failureCode {
+	case pdu.NegotiationFailureCodeSSLRequired:
+		return []pdu.NegotiationProtocol{pdu.NegotiationProtocolSSL, pdu.NegotiationProtocolHybrid, pdu.NegotiationProtocolHybridEx}
+	case pdu.NegotiationFailureCodeHybridRequired:
+		return []pdu.NegotiationProtocol{pdu.NegotiationProtocolHybrid, pdu.NegotiationProtocolHybridEx}
+	case pdu.NegotiationFailureCodeSSLNotAllowed, pdu.NegotiationFailureCodeSSLCertNotOnServer:
+		return []pdu.NegotiationProtocol{pdu.NegotiationProtocolRDP}
+	}
+
+	return nil // INCONSISTENT_FLAGS, SSL_WITH_USER_AUTH_REQUIRED_BY_SERVER
+}
+
+// offeredProtocols returns the protocols of the preference list the server accepts after the failure,
+// false when there are none or the failed request offered them already.
+func offeredProtocols(preferences []pdu.NegotiationProtocol, failure *NegotiationFailureError) ([]pdu.NegotiationProtocol, bool) {
+	var offered []pdu.NegotiationProtocol
+
+	for _, protocol := range preferences {
+		for _, accepted := range serverProtocols(failure.FailureCode) {
+			if protocol != accepted {
+				continue
+			}
+
+			if isRequested(failure.RequestedProtocols, protocol) {
+				return nil, false
+			}
+
+			offered = append(offered, protocol)
+		}
+	}
+
+	return offered, len(offered) != 0
+}
+
+// isRequested reports whether the requestedProtocols field offers the protocol,
+// PROTOCOL_RDP is offered by the empty field only.
+func isRequested(requestedProtocols, protocol pdu.NegotiationProtocol) bool {
+	if protocol.IsRDP() {
+		return requestedProtocols.IsRDP()
+	}
+
+	return requestedProtocols&protocol == protocol
+}
+
+// requestedProtocols returns the requestedProtocols field of the most preferred protocols. The server selects
+// the strongest protocol it supports, the one of the highest flag, so the preferences are offered together
+// while each one is weaker than the previous one and the rest are left for the fallback.
+// PROTOCOL_RDP is offered by the empty field only.
+func requestedProtocols(preferences []pdu.NegotiationProtocol) pdu.NegotiationProtocol {
+	var requested pdu.NegotiationProtocol
+
+	for i, protocol := range preferences {
+		if i > 0 && (protocol.IsRDP() || protocol >= preferences[i-1]) {
+			break
+		}
+
+		requested |= protocol
+	}
+
+	return requested
+}
+
+// fallback switches the next negotiation to the protocols the server accepts, false when there are none.
+func (c *Client) fallback(failure *NegotiationFailureError) bool {
+	if len(c.negotiationAttempts) > len(c.securityProtocols) {
+		return false
+	}
+
+	protocols, ok := offeredProtocols(c.securityProtocols, failure)
+	if !ok {
+		return false
+	}
+
+	c.requestedProtocols = requestedProtocols(protocols)
+
+	log.Printf("RDP: %s, falling back to %s\n", failure.FailureCode, c.requestedProtocols)
+
+	return true
+}
+
+// NegotiationAttempts returns the negotiations of the last Connect, the last one selected the protocol
+// unless Connect failed.
+func (c *Client) NegotiationAttempts() []NegotiationAttempt {
+	return c.negotiationAttempts
+}
+
+// SelectedProtocol returns the security protocol selected by the server.
+func (c *Client) SelectedProtocol() pdu.NegotiationProtocol {
+	return c.selectedProtocol
+}
@@ -0,0 +1,221 @@
+package rdp
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+// negotiationDialer connects to stand-in servers answering the X.224 Connection Requests one by one
+// with the RDP Negotiation Response or Failure.
+type negotiationDialer struct {
+	responses []negotiationResponse
+}
+
+type negotiationResponse struct {
+	negotiationType byte   // TYPE_RDP_NEG_RSP or TYPE_RDP_NEG_FAILURE
+	data            uint32 // selectedProtocol or failureCode
+}
+
+func (d *negotiationDialer) Dial(_, _ string) (net.Conn, error) {
+	clientConn, serverConn := net.Pipe()
+
+	response := d.responses[0]
+	d.responses = d.responses[1:]
+
+	go func() {
+		defer serverConn.Close()
+
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(serverConn, header); err != nil {
+			return
+		}
+
+		request := make([]byte, binary.BigEndian.Uint16(header[2:])-4)
+		if _, err := io.ReadFull(serverConn, request); err != nil {
+			return
+		}
+
+		confirm := []byte{
+			0x03, 0x00, 0x00, 0x13, // TPKT
+			0x0e, 0xd0, 0x00, 0x00, 0x12, 0x34, 0x00, // X.224 Connection Confirm
+			response.negotiationType, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}
+		binary.LittleEndian.PutUint32(confirm[15:], response.data)
+
+		_, _ = serverConn.Write(confirm) // the connection is closed after the confirm
+	}()
+
+	return clientConn, nil
+}
+
+func TestClient_ConnectNegotiationFallback(t *testing.T) {
+	dialer := negotiationDialer{
+		responses: []negotiationResponse{
+			{0x03, uint32(pdu.NegotiationFailureCodeSSLNotAllowed)},
+			{0x02, uint32(pdu.NegotiationProtocolRDP)},
+		},
+	}
+
+	config := testConfig
+	config.SecurityProtocols = []pdu.NegotiationProtocol{pdu.NegotiationProtocolSSL, pdu.NegotiationProtocolRDP}
+
+	c, err := New(config, WithDialer(&dialer))
+	require.NoError(t, err)
+
+	err = c.Connect(context.Background())
+	require.ErrorContains(t, err, "basic settings exchange") // the stand-in closes the connection after the confirm
+
+	require.Equal(t, []NegotiationAttempt{
+		{RequestedProtocols: pdu.NegotiationProtocolSSL, FailureCode: pdu.NegotiationFailureCodeSSLNotAllowed},
+		{RequestedProtocols: pdu.NegotiationProtocolRDP, SelectedProtocol: pdu.NegotiationProtocolRDP},
+	}, c.NegotiationAttempts())
+	require.Equal(t, pdu.NegotiationProtocolRDP, c.SelectedProtocol())
+	require.Empty(t, dialer.responses)
+}
+
+func TestClient_ConnectNegotiationFailure(t *testing.T) {
+	dialer := negotiationDialer{
+		responses: []negotiationResponse{
+			{0x03, uint32(pdu.NegotiationFailureCodeSSLNotAllowed)},
+		},
+	}
+
+	c, err := New(testConfig, WithDialer(&dialer)) // no PROTOCOL_RDP to fall back to
+	require.NoError(t, err)
+
+	err = c.Connect(context.Background())
+
+	var failure *NegotiationFailureError
+	require.ErrorAs(t, err, &failure)
+	require.Equal(t, pdu.NegotiationFailureCodeSSLNotAllowed, failure.FailureCode)
+	require.Len(t, c.NegotiationAttempts(), 1)
+}
+
+func Test_offeredProtocols(t *testing.T) {
+	var (
+		rdp      = pdu.NegotiationProtocolRDP
+		ssl      = pdu.NegotiationProtocolSSL
+		hybrid   = pdu.NegotiationProtocolHybrid
+		hybridEx = pdu.NegotiationProtocolHybridEx
+		all      = []pdu.NegotiationProtocol{hybridEx, hybrid, ssl, rdp}
+	)
+
+	for _, tc := range []struct {
+		preferences []pdu.NegotiationProtocol
+		requested   pdu.NegotiationProtocol
+		failureCode pdu.NegotiationFailureCode
+		offered     []pdu.NegotiationProtocol
+	}{
+		{all, ssl, pdu.NegotiationFailureCodeHybridRequired, []pdu.NegotiationProtocol{hybridEx, hybrid}},
+		{all, hybrid | ssl, pdu.NegotiationFailureCodeHybridRequired, nil},
+		{all, ssl | hybrid, pdu.NegotiationFailureCodeSSLNotAllowed, []pdu.NegotiationProtocol{rdp}},
+		{all, ssl, pdu.NegotiationFailureCodeSSLCertNotOnServer, []pdu.NegotiationProtocol{rdp}},
+		{all, rdp, pdu.NegotiationFailureCodeSSLRequired, []pdu.NegotiationProtocol{hybridEx, hybrid, ssl}},
+		{all, rdp, pdu.NegotiationFailureCodeSSLNotAllowed, nil},
+		{[]pdu.NegotiationProtocol{ssl}, ssl, pdu.NegotiationFailureCodeSSLNotAllowed, nil},
+		{all, ssl, pdu.NegotiationFailureCodeInconsistentFlags, nil},
+		{all, ssl, pdu.NegotiationFailureCodeSSLWithUserAuthRequired, nil},
+	} {
+		offered, ok := offeredProtocols(tc.preferences, &NegotiationFailureError{RequestedProtocols: tc.requested, FailureCode: tc.failureCode})
+		require.Equal(t, tc.offered, offered, "%s after %s", tc.failureCode, tc.requested)
+		require.Equal(t, tc.offered != nil, ok)
+	}
+}
+
+// selectingDialer connects to a stand-in server selecting the strongest of the requested protocols it supports.
+type selectingDialer struct {
+	supported pdu.NegotiationProtocol
+}
+
+func (d *selectingDialer) Dial(_, _ string) (net.Conn, error) {
+	clientConn, serverConn := net.Pipe()
+
+	go func() {
+		defer serverConn.Close()
+
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(serverConn, header); err != nil {
+			return
+		}
+
+		request := make([]byte, binary.BigEndian.Uint16(header[2:])-4)
+		if _, err := io.ReadFull(serverConn, request); err != nil {
+			return
+		}
+
+		requested := pdu.NegotiationProtocol(binary.LittleEndian.Uint32(request[len(request)-4:])) & d.supported
+
+		selected := pdu.NegotiationProtocolHybridEx
+		for selected != 0 && requested&selected == 0 {
+			selected >>= 1
+		}
+
+		confirm := []byte{
+			0x03, 0x00, 0x00, 0x13, // TPKT
+			0x0e, 0xd0, 0x00, 0x00, 0x12, 0x34, 0x00, // X.224 Connection Confirm
+			0x02, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}
+		binary.LittleEndian.PutUint32(confirm[15:], uint32(selected))
+
+		_, _ = serverConn.Write(confirm) // the connection is closed after the confirm
+	}()
+
+	return clientConn, nil
+}
+
+func TestClient_ConnectNegotiationOrder(t *testing.T) {
+	var (
+		ssl    = pdu.NegotiationProtocolSSL
+		hybrid = pdu.NegotiationProtocolHybrid
+	)
+
+	for _, tc := range []struct {
+		preferences []pdu.NegotiationProtocol
+		requested   pdu.NegotiationProtocol
+		selected    pdu.NegotiationProtocol
+	}{
+		{[]pdu.NegotiationProtocol{hybrid, ssl}, hybrid | ssl, hybrid},
+		{[]pdu.NegotiationProtocol{ssl, hybrid}, ssl, ssl},
+	} {
+		config := testConfig
+		config.SecurityProtocols = tc.preferences
+
+		c, err := New(config, WithDialer(&selectingDialer{supported: ssl | hybrid}))
+		require.NoError(t, err)
+
+		require.Error(t, c.Connect(context.Background())) // the stand-in closes the connection after the confirm
+		require.Equal(t, []NegotiationAttempt{
+			{RequestedProtocols: tc.requested, SelectedProtocol: tc.selected},
+		}, c.NegotiationAttempts(), "%v", tc.preferences)
+	}
+}
+
+func Test_requestedProtocols(t *testing.T) {
+	var (
+		rdp      = pdu.NegotiationProtocolRDP
+		ssl      = pdu.NegotiationProtocolSSL
+		hybrid   = pdu.NegotiationProtocolHybrid
+		hybridEx = pdu.NegotiationProtocolHybridEx
+	)
+
+	for _, tc := range []struct {
+		preferences []pdu.NegotiationProtocol
+		requested   pdu.NegotiationProtocol
+	}{
+		{[]pdu.NegotiationProtocol{hybridEx, hybrid, ssl}, hybridEx | hybrid | ssl},
+		{[]pdu.NegotiationProtocol{hybridEx, ssl, rdp}, hybridEx | ssl},
+		{[]pdu.NegotiationProtocol{ssl, hybrid, hybridEx}, ssl},
+		{[]pdu.NegotiationProtocol{hybrid, ssl, hybridEx}, hybrid | ssl},
+		{[]pdu.NegotiationProtocol{rdp, ssl}, rdp},
+		{nil, rdp},
+	} {
+		require.Equal(t, tc.requested, requestedProtocols(tc.preferences), "%v", tc.preferences)
+	}
+}
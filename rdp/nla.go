@@ -0,0 +1,53 @@
+package rdp
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/lunnik9/rdp/rdp/credssp"
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+func (c *Client) StartNLA() error {
+	publicKey, err := c.serverPublicKey()
+	if err != nil {
+		return err
+	}
+
+	log.Println("RDP: StartNLA")
+
+	credentials := credssp.Credentials{
+		Domain:   c.domain,
+		Username: c.username,
+		Password: c.password,
+
+		RestrictedAdmin: c.negotiationFlags.IsRestrictedAdminModeRequired(),
+	}
+
+	if err = credssp.New(c).Authenticate(credentials, publicKey); err != nil {
+		return fmt.Errorf("CredSSP: %w", err)
+	}
+
+	return nil
+}
+
+// earlyUserAuthorization reads the Early User Authorization Result PDU (PROTOCOL_HYBRID_EX),
+// so an authenticated but not authorized user is rejected before MCS Connect Initial.
+func (c *Client) earlyUserAuthorization() error {
+	var result pdu.EarlyUserAuthorizationResult
+
+	if err := result.Deserialize(c); err != nil {
+		return fmt.Errorf("early user authorization result: %w", err)
+	}
+
+	log.Println("RDP: Early User Authorization Result: ", uint32(result))
+
+	switch {
+	case result.IsSuccess():
+		return nil
+	case result.IsAccessDenied():
+		return ErrAccessDenied
+	}
+
+	return fmt.Errorf("unknown early user authorization result: %d", result)
+}
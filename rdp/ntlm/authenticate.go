@@ -0,0 +1,76 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+// AuthenticateMessage AUTHENTICATE_MESSAGE
+type AuthenticateMessage struct {
+	LmChallengeResponse       []byte
+	NtChallengeResponse       []byte
+	DomainName                string
+	UserName                  string
+	Workstation               string
+	EncryptedRandomSessionKey []byte
+	NegotiateFlags            NegotiateFlag
+	MIC                       [16]byte
+}
+
+// micOffset position of the MIC field in a serialized AUTHENTICATE_MESSAGE
+const micOffset = 72
+
+func (m *AuthenticateMessage) Serialize() []byte {
+	const payloadOffset = 88
+
+	var (
+		header  = new(bytes.Buffer)
+		payload = new(bytes.Buffer)
+	)
+
+	writeField := func(data []byte) {
+		_ = binary.Write(header, binary.LittleEndian, uint16(len(data)))
+		_ = binary.Write(header, binary.LittleEndian, uint16(len(data)))
+		_ = binary.Write(header, binary.LittleEndian, uint32(payloadOffset+payload.Len()))
+
+		payload.Write(data)
+	}
+
+	header.WriteString(signature)
+	_ = binary.Write(header, binary.LittleEndian, MessageTypeAuthenticate)
+
+	domainName := utf16.Encode(m.DomainName)
+	userName := utf16.Encode(m.UserName)
+	workstation := utf16.Encode(m.Workstation)
+
+	// fields are declared in a fixed order while the payload follows the
+	// order of the data, which is allowed by the spec
+	lmOffset := payloadOffset + len(domainName) + len(userName) + len(workstation)
+
+	_ = binary.Write(header, binary.LittleEndian, uint16(len(m.LmChallengeResponse)))
+	_ = binary.Write(header, binary.LittleEndian, uint16(len(m.LmChallengeResponse)))
+	_ = binary.Write(header, binary.LittleEndian, uint32(lmOffset))
+
+	_ = binary.Write(header, binary.LittleEndian, uint16(len(m.NtChallengeResponse)))
+	_ = binary.Write(header, binary.LittleEndian, uint16(len(m.NtChallengeResponse)))
+	_ = binary.Write(header, binary.LittleEndian, uint32(lmOffset+len(m.LmChallengeResponse)))
+
+	writeField(domainName)
+	writeField(userName)
+	writeField(workstation)
+
+	payload.Write(m.LmChallengeResponse)
+	payload.Write(m.NtChallengeResponse)
+
+	writeField(m.EncryptedRandomSessionKey)
+
+	_ = binary.Write(header, binary.LittleEndian, m.NegotiateFlags)
+	header.Write(version)
+	header.Write(m.MIC[:])
+
+	header.Write(payload.Bytes())
+
+	return header.Bytes()
+}
@@ -0,0 +1,106 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// ChallengeMessage CHALLENGE_MESSAGE
+type ChallengeMessage struct {
+	TargetName      []byte
+	NegotiateFlags  NegotiateFlag
+	ServerChallenge [8]byte
+	TargetInfo      []byte
+}
+
+func (m *ChallengeMessage) Deserialize(wire io.Reader) error {
+	data, err := io.ReadAll(wire)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 48 {
+		return io.ErrUnexpectedEOF
+	}
+
+	if !bytes.Equal(data[:8], []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	if MessageType(binary.LittleEndian.Uint32(data[8:])) != MessageTypeChallenge {
+		return ErrUnexpectedMessage
+	}
+
+	if m.TargetName, err = readField(data, 12); err != nil {
+		return err
+	}
+
+	m.NegotiateFlags = NegotiateFlag(binary.LittleEndian.Uint32(data[20:]))
+	copy(m.ServerChallenge[:], data[24:32])
+
+	if m.TargetInfo, err = readField(data, 40); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readField reads the payload referenced by the len/maxLen/offset fields
+// located at position pos of the message.
+func readField(data []byte, pos int) ([]byte, error) {
+	length := int(binary.LittleEndian.Uint16(data[pos:]))
+	offset := int(binary.LittleEndian.Uint32(data[pos+4:]))
+
+	if length == 0 {
+		return nil, nil
+	}
+
+	if offset+length > len(data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return data[offset : offset+length], nil
+}
+
+// AvPair AV_PAIR
+type AvPair struct {
+	ID    AvID
+	Value []byte
+}
+
+func parseAvPairs(data []byte) ([]AvPair, error) {
+	var pairs []AvPair
+
+	for len(data) >= 4 {
+		id := AvID(binary.LittleEndian.Uint16(data))
+		length := int(binary.LittleEndian.Uint16(data[2:]))
+
+		if id == AvIDEOL {
+			return pairs, nil
+		}
+
+		if len(data) < 4+length {
+			return nil, ErrInvalidAvPairs
+		}
+
+		pairs = append(pairs, AvPair{ID: id, Value: data[4 : 4+length]})
+		data = data[4+length:]
+	}
+
+	return nil, ErrInvalidAvPairs
+}
+
+func serializeAvPairs(pairs []AvPair) []byte {
+	buf := new(bytes.Buffer)
+
+	for _, pair := range pairs {
+		_ = binary.Write(buf, binary.LittleEndian, pair.ID)
+		_ = binary.Write(buf, binary.LittleEndian, uint16(len(pair.Value)))
+		buf.Write(pair.Value)
+	}
+
+	buf.Write([]byte{0x00, 0x00, 0x00, 0x00}) // MsvAvEOL
+
+	return buf.Bytes()
+}
@@ -0,0 +1,11 @@
+package ntlm
+
+import "errors"
+
+var (
+	ErrInvalidSignature   = errors.New("invalid NTLM message signature")
+	ErrUnexpectedMessage  = errors.New("unexpected NTLM message type")
+	ErrInvalidAvPairs     = errors.New("invalid NTLM AV pairs")
+	ErrInvalidChecksum    = errors.New("invalid NTLM message checksum")
+	ErrInvalidSequenceNum = errors.New("invalid NTLM message sequence number")
+)
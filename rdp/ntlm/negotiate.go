@@ -0,0 +1,25 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// NegotiateMessage NEGOTIATE_MESSAGE
+type NegotiateMessage struct {
+	NegotiateFlags NegotiateFlag
+}
+
+func (m *NegotiateMessage) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	buf.WriteString(signature)
+	_ = binary.Write(buf, binary.LittleEndian, MessageTypeNegotiate)
+	_ = binary.Write(buf, binary.LittleEndian, m.NegotiateFlags)
+
+	buf.Write(make([]byte, 8)) // DomainNameFields
+	buf.Write(make([]byte, 8)) // WorkstationFields
+	buf.Write(version)
+
+	return buf.Bytes()
+}
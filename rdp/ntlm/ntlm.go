@@ -0,0 +1,205 @@
+package ntlm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/md4"
+
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+// NTLMv2 client side of the NTLM v2 authentication with extended session security.
+type NTLMv2 struct {
+	domain   string
+	username string
+	password string
+
+	negotiateFlags   NegotiateFlag
+	negotiateMessage []byte
+	security         *Security
+
+	random io.Reader
+	now    func() time.Time
+}
+
+func NewNTLMv2(domain, username, password string) *NTLMv2 {
+	return &NTLMv2{
+		domain:   domain,
+		username: username,
+		password: password,
+
+		negotiateFlags: NegotiateFlag56 |
+			NegotiateFlagKeyExchange |
+			NegotiateFlag128 |
+			NegotiateFlagVersion |
+			NegotiateFlagExtendedSessionSecurity |
+			NegotiateFlagAlwaysSign |
+			NegotiateFlagNTLM |
+			NegotiateFlagSeal |
+			NegotiateFlagSign |
+			NegotiateFlagRequestTarget |
+			NegotiateFlagUnicode,
+
+		random: rand.Reader,
+		now:    time.Now,
+	}
+}
+
+// Negotiate returns the NEGOTIATE_MESSAGE starting the authentication.
+func (n *NTLMv2) Negotiate() []byte {
+	msg := NegotiateMessage{
+		NegotiateFlags: n.negotiateFlags,
+	}
+
+	n.negotiateMessage = msg.Serialize()
+
+	return n.negotiateMessage
+}
+
+// Authenticate processes the server CHALLENGE_MESSAGE and returns the AUTHENTICATE_MESSAGE.
+func (n *NTLMv2) Authenticate(challengeMessage []byte) ([]byte, error) {
+	var challenge ChallengeMessage
+
+	if err := challenge.Deserialize(bytes.NewReader(challengeMessage)); err != nil {
+		return nil, err
+	}
+
+	pairs, err := parseAvPairs(challenge.TargetInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var timestamp []byte
+
+	for _, pair := range pairs {
+		if pair.ID == AvIDTimestamp {
+			timestamp = pair.Value
+		}
+	}
+
+	// the MIC is provided only when the server sends MsvAvTimestamp
+	withMIC := timestamp != nil
+	if withMIC {
+		pairs = setAvFlags(pairs, avFlagMICProvided)
+	} else {
+		timestamp = fileTime(n.now())
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err = io.ReadFull(n.random, clientChallenge); err != nil {
+		return nil, err
+	}
+
+	responseKeyNT := ntowfv2(n.password, n.username, n.domain)
+
+	temp := new(bytes.Buffer)
+	temp.Write([]byte{0x01, 0x01})      // RespType, HiRespType
+	temp.Write(make([]byte, 6))         // Reserved1, Reserved2
+	temp.Write(timestamp)               // TimeStamp
+	temp.Write(clientChallenge)         // ChallengeFromClient
+	temp.Write(make([]byte, 4))         // Reserved3
+	temp.Write(serializeAvPairs(pairs)) // AvPairs
+	temp.Write([]byte{0x00, 0x00, 0x00, 0x00})
+
+	ntProofStr := hmacMD5(responseKeyNT, challenge.ServerChallenge[:], temp.Bytes())
+
+	var lmChallengeResponse []byte
+
+	if withMIC {
+		lmChallengeResponse = make([]byte, 24)
+	} else {
+		lmChallengeResponse = append(hmacMD5(responseKeyNT, challenge.ServerChallenge[:], clientChallenge), clientChallenge...)
+	}
+
+	sessionBaseKey := hmacMD5(responseKeyNT, ntProofStr)
+	keyExchangeKey := sessionBaseKey
+
+	msg := AuthenticateMessage{
+		LmChallengeResponse: lmChallengeResponse,
+		NtChallengeResponse: append(ntProofStr, temp.Bytes()...),
+		DomainName:          n.domain,
+		UserName:            n.username,
+		NegotiateFlags:      challenge.NegotiateFlags,
+	}
+
+	exportedSessionKey := keyExchangeKey
+
+	if challenge.NegotiateFlags.IsSet(NegotiateFlagKeyExchange) {
+		exportedSessionKey = make([]byte, 16)
+		if _, err = io.ReadFull(n.random, exportedSessionKey); err != nil {
+			return nil, err
+		}
+
+		cipher, _ := rc4.NewCipher(keyExchangeKey)
+		msg.EncryptedRandomSessionKey = make([]byte, 16)
+		cipher.XORKeyStream(msg.EncryptedRandomSessionKey, exportedSessionKey)
+	}
+
+	authenticateMessage := msg.Serialize()
+
+	if withMIC {
+		mic := hmacMD5(exportedSessionKey, n.negotiateMessage, challengeMessage, authenticateMessage)
+		copy(authenticateMessage[micOffset:], mic)
+	}
+
+	n.security = newSecurity(exportedSessionKey, true)
+
+	return authenticateMessage, nil
+}
+
+// Security returns the session security context established by Authenticate.
+func (n *NTLMv2) Security() *Security {
+	return n.security
+}
+
+func setAvFlags(pairs []AvPair, flags uint32) []AvPair {
+	for i, pair := range pairs {
+		if pair.ID == AvIDFlags {
+			value := make([]byte, 4)
+			binary.LittleEndian.PutUint32(value, binary.LittleEndian.Uint32(pair.Value)|flags)
+			pairs[i].Value = value
+
+			return pairs
+		}
+	}
+
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, flags)
+
+	return append(pairs, AvPair{ID: AvIDFlags, Value: value})
+}
+
+func ntowfv2(password, username, domain string) []byte {
+	hash := md4.New()
+	hash.Write(utf16.Encode(password))
+
+	return hmacMD5(hash.Sum(nil), utf16.Encode(strings.ToUpper(username)+domain))
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+
+	for _, d := range data {
+		mac.Write(d)
+	}
+
+	return mac.Sum(nil)
+}
+
+// fileTime converts t to the little-endian FILETIME representation.
+func fileTime(t time.Time) []byte {
+	const epochDiff = 11644473600 // seconds between 1601 and 1970
+
+	ft := make([]byte, 8)
+	binary.LittleEndian.PutUint64(ft, uint64((t.Unix()+epochDiff)*10000000+int64(t.Nanosecond()/100)))
+
+	return ft
+}
@@ -0,0 +1,111 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// MS-NLMP 4.2.4 NTLMv2 Authentication example
+var challengeMessage = []byte{
+	0x4e, 0x54, 0x4c, 0x4d, 0x53, 0x53, 0x50, 0x00, 0x02, 0x00, 0x00, 0x00, 0x0c, 0x00, 0x0c, 0x00,
+	0x38, 0x00, 0x00, 0x00, 0x33, 0x82, 0x8a, 0xe2, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x24, 0x00, 0x24, 0x00, 0x44, 0x00, 0x00, 0x00,
+	0x06, 0x00, 0x70, 0x17, 0x00, 0x00, 0x00, 0x0f, 0x53, 0x00, 0x65, 0x00, 0x72, 0x00, 0x76, 0x00,
+	0x65, 0x00, 0x72, 0x00, 0x02, 0x00, 0x0c, 0x00, 0x44, 0x00, 0x6f, 0x00, 0x6d, 0x00, 0x61, 0x00,
+	0x69, 0x00, 0x6e, 0x00, 0x01, 0x00, 0x0c, 0x00, 0x53, 0x00, 0x65, 0x00, 0x72, 0x00, 0x76, 0x00,
+	0x65, 0x00, 0x72, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func newTestNTLMv2() *NTLMv2 {
+	n := NewNTLMv2("Domain", "User", "Password")
+	n.random = bytes.NewReader(append(bytes.Repeat([]byte{0xaa}, 8), bytes.Repeat([]byte{0x55}, 16)...))
+	n.now = func() time.Time {
+		return time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return n
+}
+
+func Test_ntowfv2(t *testing.T) {
+	expected := []byte{
+		0x0c, 0x86, 0x8a, 0x40, 0x3b, 0xfd, 0x7a, 0x93, 0xa3, 0x00, 0x1e, 0xf2, 0x2e, 0xf0, 0x2e, 0x3f,
+	}
+
+	require.Equal(t, expected, ntowfv2("Password", "User", "Domain"))
+}
+
+func TestNTLMv2_Authenticate(t *testing.T) {
+	n := newTestNTLMv2()
+	n.Negotiate()
+
+	data, err := n.Authenticate(challengeMessage)
+	require.NoError(t, err)
+
+	field := func(pos int) []byte {
+		length := binary.LittleEndian.Uint16(data[pos:])
+		offset := binary.LittleEndian.Uint32(data[pos+4:])
+
+		return data[offset : offset+uint32(length)]
+	}
+
+	expectedLMv2 := []byte{
+		0x86, 0xc3, 0x50, 0x97, 0xac, 0x9c, 0xec, 0x10, 0x25, 0x54, 0x76, 0x4a, 0x57, 0xcc, 0xcc, 0x19,
+		0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa,
+	}
+	expectedNTProofStr := []byte{
+		0x68, 0xcd, 0x0a, 0xb8, 0x51, 0xe5, 0x1c, 0x96, 0xaa, 0xbc, 0x92, 0x7b, 0xeb, 0xef, 0x6a, 0x1c,
+	}
+	expectedEncryptedSessionKey := []byte{
+		0xc5, 0xda, 0xd2, 0x54, 0x4f, 0xc9, 0x79, 0x90, 0x94, 0xce, 0x1c, 0xe9, 0x0b, 0xc9, 0xd0, 0x3e,
+	}
+
+	require.Equal(t, expectedLMv2, field(12))
+	require.Equal(t, expectedNTProofStr, field(20)[:16])
+	require.Equal(t, []byte("D\x00o\x00m\x00a\x00i\x00n\x00"), field(28))
+	require.Equal(t, []byte("U\x00s\x00e\x00r\x00"), field(36))
+	require.Equal(t, expectedEncryptedSessionKey, field(52))
+	require.Equal(t, make([]byte, 16), data[micOffset:micOffset+16])
+}
+
+func TestSecurity_Seal(t *testing.T) {
+	n := newTestNTLMv2()
+	n.Negotiate()
+
+	_, err := n.Authenticate(challengeMessage)
+	require.NoError(t, err)
+
+	sealed, signature := n.Security().Seal([]byte("P\x00l\x00a\x00i\x00n\x00t\x00e\x00x\x00t\x00"))
+
+	require.Equal(t, []byte{
+		0x54, 0xe5, 0x01, 0x65, 0xbf, 0x19, 0x36, 0xdc, 0x99, 0x60, 0x20, 0xc1, 0x81, 0x1b, 0x0f, 0x06,
+		0xfb, 0x5f,
+	}, sealed)
+	require.Equal(t, []byte{
+		0x01, 0x00, 0x00, 0x00, 0x7f, 0xb3, 0x8e, 0xc5, 0xc5, 0x5d, 0x49, 0x76, 0x00, 0x00, 0x00, 0x00,
+	}, signature)
+}
+
+func TestSecurity_Unseal(t *testing.T) {
+	exportedSessionKey := bytes.Repeat([]byte{0x55}, 16)
+
+	client := newSecurity(exportedSessionKey, true)
+	server := newSecurity(exportedSessionKey, false)
+
+	for _, message := range []string{"first", "second"} {
+		sealed, signature := client.Seal([]byte(message))
+
+		unsealed, err := server.Unseal(sealed, signature)
+		require.NoError(t, err)
+		require.Equal(t, []byte(message), unsealed)
+	}
+
+	sealed, signature := server.Seal([]byte("reply"))
+	signature[5] ^= 0xff
+
+	_, err := client.Unseal(sealed, signature)
+	require.ErrorIs(t, err, ErrInvalidChecksum)
+}
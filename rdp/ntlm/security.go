@@ -0,0 +1,126 @@
+package ntlm
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+)
+
+const (
+	clientSigningMagic = "session key to client-to-server signing key magic constant\x00"
+	serverSigningMagic = "session key to server-to-client signing key magic constant\x00"
+	clientSealingMagic = "session key to client-to-server sealing key magic constant\x00"
+	serverSealingMagic = "session key to server-to-client sealing key magic constant\x00"
+)
+
+// Security NTLM session security with extended session security and key exchange.
+type Security struct {
+	signingKey   []byte
+	verifyingKey []byte
+	sealer       *rc4.Cipher
+	unsealer     *rc4.Cipher
+	seqNum       uint32
+	peerSeqNum   uint32
+}
+
+func newSecurity(exportedSessionKey []byte, client bool) *Security {
+	signingMagic, verifyingMagic := clientSigningMagic, serverSigningMagic
+	sealingMagic, unsealingMagic := clientSealingMagic, serverSealingMagic
+
+	if !client {
+		signingMagic, verifyingMagic = verifyingMagic, signingMagic
+		sealingMagic, unsealingMagic = unsealingMagic, sealingMagic
+	}
+
+	sealer, _ := rc4.NewCipher(deriveKey(exportedSessionKey, sealingMagic))
+	unsealer, _ := rc4.NewCipher(deriveKey(exportedSessionKey, unsealingMagic))
+
+	return &Security{
+		signingKey:   deriveKey(exportedSessionKey, signingMagic),
+		verifyingKey: deriveKey(exportedSessionKey, verifyingMagic),
+		sealer:       sealer,
+		unsealer:     unsealer,
+	}
+}
+
+func deriveKey(exportedSessionKey []byte, magic string) []byte {
+	sum := md5.Sum(append(append([]byte{}, exportedSessionKey...), magic...))
+
+	return sum[:]
+}
+
+// Clone returns an independent copy of the security context. SPNEGO uses it
+// to compute mechListMIC without touching the state of the first application message.
+func (s *Security) Clone() *Security {
+	sealer, unsealer := *s.sealer, *s.unsealer
+
+	clone := *s
+	clone.sealer = &sealer
+	clone.unsealer = &unsealer
+
+	return &clone
+}
+
+// Seal encrypts the message and returns it along with its signature.
+func (s *Security) Seal(message []byte) ([]byte, []byte) {
+	sealed := make([]byte, len(message))
+	s.sealer.XORKeyStream(sealed, message)
+
+	return sealed, s.Sign(message)
+}
+
+// Unseal decrypts the message and verifies its signature.
+func (s *Security) Unseal(sealed, signature []byte) ([]byte, error) {
+	message := make([]byte, len(sealed))
+	s.unsealer.XORKeyStream(message, sealed)
+
+	if err := s.Verify(message, signature); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// Sign returns the NTLMSSP_MESSAGE_SIGNATURE of the message.
+func (s *Security) Sign(message []byte) []byte {
+	signature := mac(s.sealer, s.signingKey, s.seqNum, message)
+	s.seqNum++
+
+	return signature
+}
+
+// Verify checks the NTLMSSP_MESSAGE_SIGNATURE of the message received from the peer.
+func (s *Security) Verify(message, signature []byte) error {
+	expected := mac(s.unsealer, s.verifyingKey, s.peerSeqNum, message)
+	s.peerSeqNum++
+
+	if len(signature) != 16 {
+		return ErrInvalidChecksum
+	}
+
+	if !bytes.Equal(expected[12:], signature[12:]) {
+		return ErrInvalidSequenceNum
+	}
+
+	if !bytes.Equal(expected, signature) {
+		return ErrInvalidChecksum
+	}
+
+	return nil
+}
+
+func mac(handle *rc4.Cipher, signingKey []byte, seqNum uint32, message []byte) []byte {
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, seqNum)
+
+	checksum := make([]byte, 8)
+	handle.XORKeyStream(checksum, hmacMD5(signingKey, seq, message)[:8])
+
+	signature := make([]byte, 0, 16)
+	signature = append(signature, 0x01, 0x00, 0x00, 0x00) // Version
+	signature = append(signature, checksum...)
+	signature = append(signature, seq...)
+
+	return signature
+}
@@ -0,0 +1,109 @@
+package ntlm
+
+// NegotiateFlag NTLM NEGOTIATE flags.
+type NegotiateFlag uint32
+
+const (
+	// NegotiateFlagUnicode NTLMSSP_NEGOTIATE_UNICODE
+	NegotiateFlagUnicode NegotiateFlag = 0x00000001
+
+	// NegotiateFlagOEM NTLM_NEGOTIATE_OEM
+	NegotiateFlagOEM NegotiateFlag = 0x00000002
+
+	// NegotiateFlagRequestTarget NTLMSSP_REQUEST_TARGET
+	NegotiateFlagRequestTarget NegotiateFlag = 0x00000004
+
+	// NegotiateFlagSign NTLMSSP_NEGOTIATE_SIGN
+	NegotiateFlagSign NegotiateFlag = 0x00000010
+
+	// NegotiateFlagSeal NTLMSSP_NEGOTIATE_SEAL
+	NegotiateFlagSeal NegotiateFlag = 0x00000020
+
+	// NegotiateFlagNTLM NTLMSSP_NEGOTIATE_NTLM
+	NegotiateFlagNTLM NegotiateFlag = 0x00000200
+
+	// NegotiateFlagAlwaysSign NTLMSSP_NEGOTIATE_ALWAYS_SIGN
+	NegotiateFlagAlwaysSign NegotiateFlag = 0x00008000
+
+	// NegotiateFlagExtendedSessionSecurity NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY
+	NegotiateFlagExtendedSessionSecurity NegotiateFlag = 0x00080000
+
+	// NegotiateFlagTargetInfo NTLMSSP_NEGOTIATE_TARGET_INFO
+	NegotiateFlagTargetInfo NegotiateFlag = 0x00800000
+
+	// NegotiateFlagVersion NTLMSSP_NEGOTIATE_VERSION
+	NegotiateFlagVersion NegotiateFlag = 0x02000000
+
+	// NegotiateFlag128 NTLMSSP_NEGOTIATE_128
+	NegotiateFlag128 NegotiateFlag = 0x20000000
+
+	// NegotiateFlagKeyExchange NTLMSSP_NEGOTIATE_KEY_EXCH
+	NegotiateFlagKeyExchange NegotiateFlag = 0x40000000
+
+	// NegotiateFlag56 NTLMSSP_NEGOTIATE_56
+	NegotiateFlag56 NegotiateFlag = 0x80000000
+)
+
+func (f NegotiateFlag) IsSet(flag NegotiateFlag) bool {
+	return f&flag == flag
+}
+
+// MessageType NTLM message type.
+type MessageType uint32
+
+const (
+	// MessageTypeNegotiate NtLmNegotiate
+	MessageTypeNegotiate MessageType = 0x00000001
+
+	// MessageTypeChallenge NtLmChallenge
+	MessageTypeChallenge MessageType = 0x00000002
+
+	// MessageTypeAuthenticate NtLmAuthenticate
+	MessageTypeAuthenticate MessageType = 0x00000003
+)
+
+// AvID AV_PAIR AvId.
+type AvID uint16
+
+const (
+	// AvIDEOL MsvAvEOL
+	AvIDEOL AvID = 0x0000
+
+	// AvIDNbComputerName MsvAvNbComputerName
+	AvIDNbComputerName AvID = 0x0001
+
+	// AvIDNbDomainName MsvAvNbDomainName
+	AvIDNbDomainName AvID = 0x0002
+
+	// AvIDDNSComputerName MsvAvDnsComputerName
+	AvIDDNSComputerName AvID = 0x0003
+
+	// AvIDDNSDomainName MsvAvDnsDomainName
+	AvIDDNSDomainName AvID = 0x0004
+
+	// AvIDDNSTreeName MsvAvDnsTreeName
+	AvIDDNSTreeName AvID = 0x0005
+
+	// AvIDFlags MsvAvFlags
+	AvIDFlags AvID = 0x0006
+
+	// AvIDTimestamp MsvAvTimestamp
+	AvIDTimestamp AvID = 0x0007
+
+	// AvIDSingleHost MsvAvSingleHost
+	AvIDSingleHost AvID = 0x0008
+
+	// AvIDTargetName MsvAvTargetName
+	AvIDTargetName AvID = 0x0009
+
+	// AvIDChannelBindings MsvChannelBindings
+	AvIDChannelBindings AvID = 0x000A
+)
+
+// avFlagMICProvided MsvAvFlags: the client is providing message integrity in the MIC field
+const avFlagMICProvided = 0x00000002
+
+const signature = "NTLMSSP\x00"
+
+// version NTLM VERSION structure: Windows 7 SP1, NTLMSSP_REVISION_W2K3.
+var version = []byte{0x06, 0x01, 0xb1, 0x1d, 0x00, 0x00, 0x00, 0x0f}
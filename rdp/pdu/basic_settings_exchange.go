@@ -9,10 +9,7 @@ import (
 	"github.com/lunnik9/rdp/rdp/utf16"
 )
 
-const (
-	rdpVersion5Plus             = 0x00080004
-	keyboardTypeIBM101or102Keys = 0x00000004
-)
+const rdpVersion5Plus = 0x00080004
 
 // earlyCapabilityFlags
 const (
@@ -54,25 +51,22 @@ type ClientCoreData struct {
 	ServerSelectedProtocol uint32
 }
 
-func newClientCoreData(selectedProtocol uint32, desktopWidth, desktopHeight uint16) *ClientCoreData {
+func newClientCoreData(selectedProtocol uint32, settings *ClientSettings) *ClientCoreData {
 	data := ClientCoreData{
 		Version:                rdpVersion5Plus,
-		DesktopWidth:           desktopWidth,
-		DesktopHeight:          desktopHeight,
-		ColorDepth:             0xCA01,     // RNS_UD_COLOR_8BPP
-		SASSequence:            0xAA03,     // RNS_UD_SAS_DEL
-		KeyboardLayout:         0x00000409, // US
+		DesktopWidth:           settings.DesktopWidth,
+		DesktopHeight:          settings.DesktopHeight,
+		ColorDepth:             0xCA01, // RNS_UD_COLOR_8BPP
+		SASSequence:            0xAA03, // RNS_UD_SAS_DEL
+		KeyboardLayout:         settings.KeyboardLayout,
 		ClientBuild:            0xece,
 		ClientName:             [32]byte{},
-		KeyboardType:           keyboardTypeIBM101or102Keys,
-		KeyboardSubType:        0x00000000,
-		KeyboardFunctionKey:    12,
+		KeyboardType:           settings.KeyboardType,
+		KeyboardSubType:        settings.KeyboardSubType,
+		KeyboardFunctionKey:    settings.KeyboardFunctionKey,
 		ImeFileName:            [64]byte{},
-		PostBeta2ColorDepth:    0xCA03, // RNS_UD_COLOR_16BPP_565
 		ClientProductId:        0x0001,
 		SerialNumber:           0x00000000,
-		HighColorDepth:         0x0010, // HIGH_COLOR_16BPP
-		SupportedColorDepths:   0x0002, // RNS_UD_16BPP_SUPPORT
 		EarlyCapabilityFlags:   ECFSupportErrInfoPDU,
 		ClientDigProductId:     [64]byte{},
 		ConnectionType:         0x00,
@@ -80,11 +74,36 @@ func newClientCoreData(selectedProtocol uint32, desktopWidth, desktopHeight uint
 		ServerSelectedProtocol: selectedProtocol,
 	}
 
-	copy(data.ClientName[:], utf16.Encode(projectName))
+	data.setColorDepth(settings.ColorDepth)
+
+	copy(data.ClientName[:30], utf16.Encode(settings.ClientName))
 
 	return &data
 }
 
+// setColorDepth requests the color depth of the session, 32 bpp by earlyCapabilityFlags.
+func (data *ClientCoreData) setColorDepth(colorDepth uint16) {
+	switch colorDepth {
+	case 15:
+		data.PostBeta2ColorDepth = 0xCA02  // RNS_UD_COLOR_16BPP_555
+		data.HighColorDepth = 0x000F       // HIGH_COLOR_15BPP
+		data.SupportedColorDepths = 0x0004 // RNS_UD_15BPP_SUPPORT
+	case 24:
+		data.PostBeta2ColorDepth = 0xCA04  // RNS_UD_COLOR_24BPP
+		data.HighColorDepth = 0x0018       // HIGH_COLOR_24BPP
+		data.SupportedColorDepths = 0x0001 // RNS_UD_24BPP_SUPPORT
+	case 32:
+		data.PostBeta2ColorDepth = 0xCA04  // RNS_UD_COLOR_24BPP
+		data.HighColorDepth = 0x0018       // HIGH_COLOR_24BPP
+		data.SupportedColorDepths = 0x0008 // RNS_UD_32BPP_SUPPORT
+		data.EarlyCapabilityFlags |= ECFWant32BPPSession
+	default:
+		data.PostBeta2ColorDepth = 0xCA03  // RNS_UD_COLOR_16BPP_565
+		data.HighColorDepth = 0x0010       // HIGH_COLOR_16BPP
+		data.SupportedColorDepths = 0x0002 // RNS_UD_16BPP_SUPPORT
+	}
+}
+
 const (
 	// EncryptionFlag40Bit ENCRYPTION_FLAG_40BIT
 	EncryptionFlag40Bit uint32 = 0x00000001
@@ -104,12 +123,16 @@ type ClientSecurityData struct {
 	ExtEncryptionMethods uint32
 }
 
-func newClientSecurityData() *ClientSecurityData {
+func newClientSecurityData(selectedProtocol uint32) *ClientSecurityData {
 	data := ClientSecurityData{
 		EncryptionMethods:    0,
 		ExtEncryptionMethods: 0,
 	}
 
+	if NegotiationProtocol(selectedProtocol).IsRDP() { // Standard RDP Security
+		data.EncryptionMethods = EncryptionMethodFlag40Bit | EncryptionMethodFlag56Bit | EncryptionMethodFlag128Bit | EncryptionMethodFlagFIPS
+	}
+
 	return &data
 }
 
@@ -123,11 +146,39 @@ type ClientNetworkData struct {
 	ChannelDefArray []ChannelDefinitionStructure
 }
 
+const (
+	// ClusterRedirectionSupported REDIRECTION_SUPPORTED
+	ClusterRedirectionSupported uint32 = 0x00000001
+
+	// ClusterRedirectedSessionIDFieldValid REDIRECTED_SESSIONID_FIELD_VALID
+	ClusterRedirectedSessionIDFieldValid uint32 = 0x00000002
+
+	// ClusterRedirectionVersion4 REDIRECTION_VERSION4 in ServerSessionRedirectionVersionMask
+	ClusterRedirectionVersion4 uint32 = 0x03 << 2
+
+	// ClusterRedirectedSmartcard REDIRECTED_SMARTCARD
+	ClusterRedirectedSmartcard uint32 = 0x00000040
+)
+
 type ClientClusterData struct {
 	Flags               uint32
 	RedirectedSessionID uint32
 }
 
+// newClientClusterData supports the Server Redirection and asks for the console or an existing session.
+func newClientClusterData(settings *ClientSettings) *ClientClusterData {
+	data := ClientClusterData{
+		Flags: ClusterRedirectionSupported | ClusterRedirectionVersion4,
+	}
+
+	if settings.ConsoleSession || settings.SessionID != 0 {
+		data.Flags |= ClusterRedirectedSessionIDFieldValid
+		data.RedirectedSessionID = settings.SessionID // 0 is the console session
+	}
+
+	return &data
+}
+
 type ClientUserDataSet struct {
 	ClientCoreData     *ClientCoreData
 	ClientSecurityData *ClientSecurityData
@@ -135,13 +186,12 @@ type ClientUserDataSet struct {
 	ClientClusterData  *ClientClusterData
 }
 
-func NewClientUserDataSet(selectedProtocol uint32,
-	desktopWidth, desktopHeight uint16,
-	channelNames []string) *ClientUserDataSet {
+func NewClientUserDataSet(selectedProtocol uint32, settings *ClientSettings, channelNames []string) *ClientUserDataSet {
 	return &ClientUserDataSet{
-		ClientCoreData:     newClientCoreData(selectedProtocol, desktopWidth, desktopHeight),
-		ClientSecurityData: newClientSecurityData(),
+		ClientCoreData:     newClientCoreData(selectedProtocol, settings),
+		ClientSecurityData: newClientSecurityData(selectedProtocol),
 		ClientNetworkData:  newClientNetworkData(channelNames),
+		ClientClusterData:  newClientClusterData(settings),
 	}
 }
 
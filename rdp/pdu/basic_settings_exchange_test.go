@@ -9,7 +9,7 @@ import (
 func Test_NewClientUserDataSet(t *testing.T) {
 	r := require.New(t)
 
-	input := NewClientUserDataSet(0x00000000, 1280, 1024, []string{"rdpdr", "cliprdp", "rdpsnd"})
+	input := NewClientUserDataSet(0x00000000, NewClientSettings(1280, 1024), []string{"rdpdr", "cliprdp", "rdpsnd"})
 	input.ClientCoreData.ColorDepth = 0xca01
 	input.ClientCoreData.SASSequence = 0xaa03
 	input.ClientCoreData.KeyboardLayout = 0x409
@@ -62,3 +62,25 @@ func Test_NewClientUserDataSet(t *testing.T) {
 
 	r.Equal(expected, input.Serialize())
 }
+
+func Test_NewClientUserDataSet_ClientClusterData(t *testing.T) {
+	for _, tc := range []struct {
+		consoleSession bool
+		sessionID      uint32
+		expected       []byte
+	}{
+		{false, 0, []byte{0x04, 0xc0, 0x0c, 0x00, 0x0d, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{true, 0, []byte{0x04, 0xc0, 0x0c, 0x00, 0x0f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{false, 3, []byte{0x04, 0xc0, 0x0c, 0x00, 0x0f, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00}},
+	} {
+		settings := NewClientSettings(1024, 768)
+		settings.ConsoleSession = tc.consoleSession
+		settings.SessionID = tc.sessionID
+
+		userDataSet := NewClientUserDataSet(0x00000000, settings, nil)
+		require.Equal(t, tc.expected, userDataSet.ClientClusterData.Serialize())
+
+		wire := userDataSet.Serialize()
+		require.Equal(t, tc.expected, wire[216:216+12]) // CS_CLUSTER follows CS_CORE
+	}
+}
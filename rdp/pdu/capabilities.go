@@ -18,8 +18,8 @@ func NewGeneralCapabilitySet() CapabilitySet {
 	return CapabilitySet{
 		CapabilitySetType: CapabilitySetTypeGeneral,
 		GeneralCapabilitySet: &GeneralCapabilitySet{
-			OSMajorType: 0x0008,                   // Chrome OS platform
-			ExtraFlags:  0x0001 | 0x0004 | 0x0400, // required: FASTPATH_OUTPUT_SUPPORTED, LONG_CREDENTIALS_SUPPORTED, NO_BITMAP_COMPRESSION_HDR
+			OSMajorType: 0x0008,                                     // Chrome OS platform
+			ExtraFlags:  0x0001 | 0x0004 | 0x0400 | 0x0010 | 0x0008, // required: FASTPATH_OUTPUT_SUPPORTED, LONG_CREDENTIALS_SUPPORTED, NO_BITMAP_COMPRESSION_HDR; ENC_SALTED_CHECKSUM, AUTORECONNECT_SUPPORTED
 		},
 	}
 }
@@ -109,6 +109,17 @@ func (s *GeneralCapabilitySet) Deserialize(wire io.Reader) error {
 	return nil
 }
 
+const (
+	// DrawAllowDynamicColorFidelity DRAW_ALLOW_DYNAMIC_COLOR_FIDELITY
+	DrawAllowDynamicColorFidelity uint8 = 0x02
+
+	// DrawAllowColorSubsampling DRAW_ALLOW_COLOR_SUBSAMPLING
+	DrawAllowColorSubsampling uint8 = 0x04
+
+	// DrawAllowSkipAlpha DRAW_ALLOW_SKIP_ALPHA
+	DrawAllowSkipAlpha uint8 = 0x08
+)
+
 type BitmapCapabilitySet struct {
 	PreferredBitsPerPixel uint16
 	Receive1BitPerPixel   uint16
@@ -120,16 +131,24 @@ type BitmapCapabilitySet struct {
 	DrawingFlags          uint8
 }
 
-func NewBitmapCapabilitySet(desktopWidth, desktopHeight uint16) CapabilitySet {
+func NewBitmapCapabilitySet(settings *ClientSettings) CapabilitySet {
+	var drawingFlags uint8
+
+	if settings.Planar { // the RDP 6.0 Bitmap Compression of 32 bpp bitmaps with all its reductions
+		drawingFlags = DrawAllowDynamicColorFidelity | DrawAllowColorSubsampling | DrawAllowSkipAlpha
+	}
+
 	return CapabilitySet{
 		CapabilitySetType: CapabilitySetTypeBitmap,
 		BitmapCapabilitySet: &BitmapCapabilitySet{
-			PreferredBitsPerPixel: 0x0018, // HIGH_COLOR_24BPP
+			PreferredBitsPerPixel: settings.ColorDepth,
 			Receive1BitPerPixel:   0x0001,
 			Receive4BitsPerPixel:  0x0001,
 			Receive8BitsPerPixel:  0x0001,
-			DesktopWidth:          desktopWidth,
-			DesktopHeight:         desktopHeight,
+			DesktopWidth:          settings.DesktopWidth,
+			DesktopHeight:         settings.DesktopHeight,
+			DesktopResizeFlag:     0x0001, // the server may resize the desktop on reactivation
+			DrawingFlags:          drawingFlags,
 		},
 	}
 }
@@ -621,14 +640,15 @@ type InputCapabilitySet struct {
 	ImeFileName         [64]byte
 }
 
-func NewInputCapabilitySet() CapabilitySet {
+func NewInputCapabilitySet(settings *ClientSettings) CapabilitySet {
 	return CapabilitySet{
 		CapabilitySetType: CapabilitySetTypeInput,
 		InputCapabilitySet: &InputCapabilitySet{
 			InputFlags:          0x0001 | 0x0004 | 0x0010 | 0x0020, // INPUT_FLAG_SCANCODES, INPUT_FLAG_MOUSEX, INPUT_FLAG_UNICODE, INPUT_FLAG_FASTPATH_INPUT2
-			KeyboardLayout:      0x00000409,                        // US
-			KeyboardType:        0x00000004,                        // IBM enhanced (101- or 102-key) keyboard
-			KeyboardFunctionKey: 12,
+			KeyboardLayout:      settings.KeyboardLayout,
+			KeyboardType:        settings.KeyboardType,
+			KeyboardSubType:     settings.KeyboardSubType,
+			KeyboardFunctionKey: settings.KeyboardFunctionKey,
 		},
 	}
 }
@@ -1291,10 +1311,12 @@ type MultifragmentUpdateCapabilitySet struct {
 	MaxRequestSize uint32
 }
 
-func NewMultifragmentUpdateCapabilitySet() CapabilitySet {
+func NewMultifragmentUpdateCapabilitySet(settings *ClientSettings) CapabilitySet {
 	return CapabilitySet{
-		CapabilitySetType:                CapabilitySetTypeMultifragmentUpdate,
-		MultifragmentUpdateCapabilitySet: &MultifragmentUpdateCapabilitySet{},
+		CapabilitySetType: CapabilitySetTypeMultifragmentUpdate,
+		MultifragmentUpdateCapabilitySet: &MultifragmentUpdateCapabilitySet{
+			MaxRequestSize: settings.MaxRequestSize,
+		},
 	}
 }
 
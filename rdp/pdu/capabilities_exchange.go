@@ -203,10 +203,21 @@ func (set *CapabilitySet) DeserializeQuick(wire io.Reader) error {
 	}
 
 	data := make([]byte, lengthCapability-4)
-	if _, err = wire.Read(data); err != nil {
+	if _, err = io.ReadFull(wire, data); err != nil {
 		return err
 	}
 
+	switch set.CapabilitySetType {
+	case CapabilitySetTypeGeneral: // extraFlags are required for ENC_SALTED_CHECKSUM
+		set.GeneralCapabilitySet = &GeneralCapabilitySet{}
+
+		return set.GeneralCapabilitySet.Deserialize(bytes.NewReader(data))
+	case CapabilitySetTypeBitmap: // the desktop size chosen by the server
+		set.BitmapCapabilitySet = &BitmapCapabilitySet{}
+
+		return set.BitmapCapabilitySet.Deserialize(bytes.NewReader(data))
+	}
+
 	return nil
 }
 
@@ -317,6 +328,11 @@ func (pdu *ServerDemandActive) Deserialize(wire io.Reader) error {
 		return err
 	}
 
+	// the broker redirects the client instead of the capabilities exchange
+	if pdu.ShareControlHeader.PDUType.IsServerRedirect() {
+		return ErrServerRedirection
+	}
+
 	err = binary.Read(wire, binary.LittleEndian, &pdu.ShareID)
 	if err != nil {
 		return err
@@ -376,7 +392,7 @@ type ClientConfirmActive struct {
 	CapabilitySets     []CapabilitySet
 }
 
-func NewClientConfirmActive(shareID uint32, userId, desktopWidth, desktopHeight uint16, withRemoteApp bool) *ClientConfirmActive {
+func NewClientConfirmActive(shareID uint32, userId uint16, settings *ClientSettings, withRemoteApp bool) *ClientConfirmActive {
 	pdu := ClientConfirmActive{
 		ShareControlHeader: ShareControlHeader{
 			PDUType:   TypeConfirmActive,
@@ -386,17 +402,17 @@ func NewClientConfirmActive(shareID uint32, userId, desktopWidth, desktopHeight
 		SourceDescriptor: []byte(projectName),
 		CapabilitySets: []CapabilitySet{
 			NewGeneralCapabilitySet(),
-			NewBitmapCapabilitySet(desktopWidth, desktopHeight),
+			NewBitmapCapabilitySet(settings),
 			NewOrderCapabilitySet(),
 			NewBitmapCacheCapabilitySetRev1(),
 			NewPointerCapabilitySet(),
-			NewInputCapabilitySet(),
+			NewInputCapabilitySet(settings),
 			NewBrushCapabilitySet(),
 			NewGlyphCacheCapabilitySet(),
 			NewOffscreenBitmapCacheCapabilitySet(),
 			NewVirtualChannelCapabilitySet(),
 			NewSoundCapabilitySet(),
-			NewMultifragmentUpdateCapabilitySet(),
+			NewMultifragmentUpdateCapabilitySet(settings),
 		},
 	}
 
@@ -32,7 +32,7 @@ func TestServerDemandActivePDU_Deserialize(t *testing.T) {
 func TestClientConfirmActivePDU_Serialize(t *testing.T) {
 	const userID uint16 = 1007
 
-	confirmActive := NewClientConfirmActive(66538, userID, 1280, 1024, false)
+	confirmActive := NewClientConfirmActive(66538, userID, NewClientSettings(1280, 1024), false)
 	confirmActive.SourceDescriptor = []byte("MSTSC\x00")
 	confirmActive.CapabilitySets = []CapabilitySet{
 		{
@@ -0,0 +1,103 @@
+package pdu
+
+import "errors"
+
+var (
+	ErrInvalidColorDepth  = errors.New("color depth must be 15, 16, 24 or 32 bits per pixel")
+	ErrClientNameTooLong  = errors.New("client name longer than 15 characters")
+	ErrInvalidCompression = errors.New("invalid compression type")
+	ErrConsoleSessionID   = errors.New("console session with a session ID")
+	ErrPlanarColorDepth   = errors.New("planar codec requires 32 bits per pixel")
+	ErrMaxRequestSize     = errors.New("max request size exceeds the 16-bit size of an update")
+)
+
+const (
+	// KeyboardLayoutUS US English keyboard layout
+	KeyboardLayoutUS uint32 = 0x00000409
+
+	// KeyboardTypeIBMEnhanced IBM enhanced (101- or 102-key) keyboard
+	KeyboardTypeIBMEnhanced uint32 = 0x00000004
+
+	// KeyboardTypeJapanese Japanese keyboard
+	KeyboardTypeJapanese uint32 = 0x00000007
+)
+
+// MaxRequestSizeDefault the reassembled fastpath updates fit the 16-bit size of a single update,
+// it is the largest max request size as well.
+const MaxRequestSizeDefault uint32 = 0xFFFF
+
+// performance flags of the Extended Info Packet
+const (
+	PerfDisableWallpaper         uint32 = 0x00000001
+	PerfDisableFullWindowDrag    uint32 = 0x00000002
+	PerfDisableMenuAnimations    uint32 = 0x00000004
+	PerfDisableTheming           uint32 = 0x00000008
+	PerfDisableCursorShadow      uint32 = 0x00000020
+	PerfDisableCursorSettings    uint32 = 0x00000040
+	PerfEnableFontSmoothing      uint32 = 0x00000080
+	PerfEnableDesktopComposition uint32 = 0x00000100
+)
+
+// ClientSettings the client PDUs of the connection sequence are built from:
+// Client Core Data, Client Info PDU and Confirm Active PDU.
+type ClientSettings struct {
+	DesktopWidth        uint16
+	DesktopHeight       uint16
+	ColorDepth          uint16 // 15, 16, 24 or 32 bits per pixel
+	ClientName          string // up to 15 characters
+	KeyboardLayout      uint32
+	KeyboardType        uint32
+	KeyboardSubType     uint32
+	KeyboardFunctionKey uint32
+	PerformanceFlags    uint32
+	Compression         bool   // bulk compression of the server data
+	CompressionType     uint32 // CompressionType8K to CompressionTypeRDP61
+	ConsoleSession      bool   // attach to the console session
+	SessionID           uint32 // existing session to attach to, 0 for a new session
+	Planar              bool   // 32 bpp bitmaps of the RDP 6.0 Bitmap Compression are decoded
+	MaxRequestSize      uint32 // of the fastpath updates reassembled from fragments
+}
+
+// NewClientSettings returns the default settings: 16 bits per pixel, US layout of the IBM enhanced keyboard.
+func NewClientSettings(desktopWidth, desktopHeight uint16) *ClientSettings {
+	return &ClientSettings{
+		DesktopWidth:        desktopWidth,
+		DesktopHeight:       desktopHeight,
+		ColorDepth:          16,
+		ClientName:          projectName,
+		KeyboardLayout:      KeyboardLayoutUS,
+		KeyboardType:        KeyboardTypeIBMEnhanced,
+		KeyboardFunctionKey: 12,
+		MaxRequestSize:      MaxRequestSizeDefault,
+	}
+}
+
+func (s *ClientSettings) Validate() error {
+	switch s.ColorDepth {
+	case 15, 16, 24, 32: // pass
+	default:
+		return ErrInvalidColorDepth
+	}
+
+	if len([]rune(s.ClientName)) > 15 {
+		return ErrClientNameTooLong
+	}
+
+	if s.CompressionType > CompressionTypeRDP61 {
+		return ErrInvalidCompression
+	}
+
+	if s.ConsoleSession && s.SessionID != 0 {
+		return ErrConsoleSessionID
+	}
+
+	if s.Planar && s.ColorDepth != 32 {
+		return ErrPlanarColorDepth
+	}
+
+	if s.MaxRequestSize > MaxRequestSizeDefault {
+		return ErrMaxRequestSize
+	}
+
+	return nil
+}
@@ -78,6 +78,32 @@ const (
 	NegotiationProtocolHybridEx NegotiationProtocol = 0x00000008
 )
 
+var negotiationProtocolNames = []struct {
+	protocol NegotiationProtocol
+	name     string
+}{
+	{NegotiationProtocolSSL, "PROTOCOL_SSL"},
+	{NegotiationProtocolHybrid, "PROTOCOL_HYBRID"},
+	{NegotiationProtocolRDSTLS, "PROTOCOL_RDSTLS"},
+	{NegotiationProtocolHybridEx, "PROTOCOL_HYBRID_EX"},
+}
+
+func (p NegotiationProtocol) String() string {
+	if p.IsRDP() {
+		return "PROTOCOL_RDP"
+	}
+
+	var names []string
+
+	for _, n := range negotiationProtocolNames {
+		if p&n.protocol == n.protocol {
+			names = append(names, n.name)
+		}
+	}
+
+	return strings.Join(names, " | ")
+}
+
 func (p NegotiationProtocol) IsRDP() bool {
 	return p == NegotiationProtocolRDP
 }
@@ -210,14 +236,19 @@ func (f NegotiationResponseFlag) IsRedirectedAuthModeSupported() bool {
 func (f NegotiationResponseFlag) String() string {
 	var features []string
 
-	switch {
-	case f.IsExtendedClientDataSupported():
+	if f.IsExtendedClientDataSupported() {
 		features = append(features, "EXTENDED_CLIENT_DATA_SUPPORTED")
-	case f.IsGFXProtocolSupported():
+	}
+
+	if f.IsGFXProtocolSupported() {
 		features = append(features, "DYNVC_GFX_PROTOCOL_SUPPORTED")
-	case f.IsRestrictedAdminModeSupported():
+	}
+
+	if f.IsRestrictedAdminModeSupported() {
 		features = append(features, "RESTRICTED_ADMIN_MODE_SUPPORTED")
-	case f.IsRedirectedAuthModeSupported():
+	}
+
+	if f.IsRedirectedAuthModeSupported() {
 		features = append(features, "REDIRECTED_AUTHENTICATION_MODE_SUPPORTED")
 	}
 
@@ -262,12 +293,38 @@ func (c NegotiationFailureCode) String() string {
 
 // ClientConnectionRequest Client X.224 Connection Request PDU
 type ClientConnectionRequest struct {
-	RoutingToken       string // one of RoutingToken or Cookie ending CR+LF
-	Cookie             string
+	RoutingToken       []byte             // opaque, e.g. load balance info of a redirection, takes precedence over Cookie
+	Cookie             string             // mstshash cookie, usually the user name
 	NegotiationRequest NegotiationRequest // RDP Negotiation Request
 	CorrelationInfo    CorrelationInfo    // Correlation Info
 }
 
+const (
+	// MaxRoutingTokenLength of the routing token with the terminating CR+LF, the RDP Negotiation Request
+	// and the Correlation Info follow it in the user data of the X.224 Connection Request.
+	MaxRoutingTokenLength = 200
+
+	// MaxCookieLength of the mstshash cookie within MaxRoutingTokenLength.
+	MaxCookieLength = MaxRoutingTokenLength - len("Cookie: mstshash=\r\n")
+)
+
+// Validate rejects the routing token and the cookie which do not fit or run past their CR+LF terminator.
+func (pdu *ClientConnectionRequest) Validate() error {
+	if len(pdu.RoutingToken) != 0 {
+		token := bytes.TrimSuffix(pdu.RoutingToken, []byte("\r\n"))
+
+		if len(token)+2 > MaxRoutingTokenLength || bytes.ContainsAny(token, "\r\n") {
+			return ErrInvalidRoutingToken
+		}
+	}
+
+	if len(pdu.Cookie) > MaxCookieLength || strings.ContainsAny(pdu.Cookie, "\r\n") {
+		return ErrInvalidCookie
+	}
+
+	return nil
+}
+
 func (pdu *ClientConnectionRequest) Serialize() []byte {
 	const (
 		CRLF         = "\r\n"
@@ -276,9 +333,13 @@ func (pdu *ClientConnectionRequest) Serialize() []byte {
 
 	buf := new(bytes.Buffer)
 
-	// routingToken or cookie
-	if pdu.RoutingToken != "" {
-		buf.WriteString(strings.Trim(pdu.RoutingToken, CRLF) + CRLF)
+	// routingToken or cookie, both terminated by CR+LF
+	if len(pdu.RoutingToken) != 0 {
+		buf.Write(pdu.RoutingToken)
+
+		if !bytes.HasSuffix(pdu.RoutingToken, []byte(CRLF)) {
+			buf.WriteString(CRLF)
+		}
 	} else if pdu.Cookie != "" {
 		buf.WriteString(cookieHeader + strings.Trim(pdu.Cookie, CRLF) + CRLF)
 	}
@@ -311,6 +372,10 @@ func (pdu *ServerConnectionConfirm) FailureCode() NegotiationFailureCode {
 
 func (pdu *ServerConnectionConfirm) Deserialize(wire io.Reader) error {
 	err := binary.Read(wire, binary.LittleEndian, &pdu.Type)
+	if err == io.EOF { // no RDP Negotiation Response, the server supports only PROTOCOL_RDP
+		return nil
+	}
+
 	if err != nil {
 		return err
 	}
@@ -332,3 +397,27 @@ func (pdu *ServerConnectionConfirm) Deserialize(wire io.Reader) error {
 
 	return nil
 }
+
+// EarlyUserAuthorizationResult Early User Authorization Result PDU,
+// sent by the server after CredSSP when PROTOCOL_HYBRID_EX is selected.
+type EarlyUserAuthorizationResult uint32
+
+const (
+	// EarlyUserAuthorizationResultSuccess AUTHZ_SUCCESS
+	EarlyUserAuthorizationResultSuccess EarlyUserAuthorizationResult = 0x00000000
+
+	// EarlyUserAuthorizationResultAccessDenied AUTHZ_ACCESS_DENIED
+	EarlyUserAuthorizationResultAccessDenied EarlyUserAuthorizationResult = 0x00000005
+)
+
+func (r EarlyUserAuthorizationResult) IsSuccess() bool {
+	return r == EarlyUserAuthorizationResultSuccess
+}
+
+func (r EarlyUserAuthorizationResult) IsAccessDenied() bool {
+	return r == EarlyUserAuthorizationResultAccessDenied
+}
+
+func (r *EarlyUserAuthorizationResult) Deserialize(wire io.Reader) error {
+	return binary.Read(wire, binary.LittleEndian, r)
+}
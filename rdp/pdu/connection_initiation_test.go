@@ -44,3 +44,91 @@ func TestServerConnectionConfirmPDU_Deserialize(t *testing.T) {
 	require.NoError(t, actual.Deserialize(input))
 	require.Equal(t, expected, actual)
 }
+
+func TestEarlyUserAuthorizationResult_Deserialize(t *testing.T) {
+	var actual EarlyUserAuthorizationResult
+
+	require.NoError(t, actual.Deserialize(bytes.NewBuffer([]byte{0x05, 0x00, 0x00, 0x00})))
+	require.True(t, actual.IsAccessDenied())
+
+	require.NoError(t, actual.Deserialize(bytes.NewBuffer([]byte{0x00, 0x00, 0x00, 0x00})))
+	require.True(t, actual.IsSuccess())
+
+	require.Error(t, actual.Deserialize(bytes.NewBuffer([]byte{0x00, 0x00})))
+}
+
+func TestServerConnectionConfirmPDU_DeserializeLegacy(t *testing.T) {
+	var actual ServerConnectionConfirm
+
+	require.NoError(t, actual.Deserialize(bytes.NewBuffer(nil)))
+	require.False(t, actual.Type.IsFailure())
+	require.True(t, actual.SelectedProtocol().IsRDP())
+}
+
+func TestClientConnectionRequestPDU_SerializeRoutingToken(t *testing.T) {
+	var req ClientConnectionRequest
+
+	req.Cookie = "eltons" // ignored
+	req.RoutingToken = []byte("Cookie: msts=3640205228.15629.0000")
+	req.NegotiationRequest.RequestedProtocols = NegotiationProtocolSSL
+
+	expected := append([]byte("Cookie: msts=3640205228.15629.0000\r\n"), 0x01, 0x00, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00)
+
+	require.Equal(t, expected, req.Serialize())
+
+	req.RoutingToken = append(req.RoutingToken, '\r', '\n')
+
+	require.Equal(t, expected, req.Serialize())
+}
+
+func TestClientConnectionRequest_Validate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		req         ClientConnectionRequest
+		expectedErr error
+	}{
+		{
+			name: "cookie",
+			req:  ClientConnectionRequest{Cookie: "eltons"},
+		},
+		{
+			name: "routing token",
+			req:  ClientConnectionRequest{RoutingToken: []byte("Cookie: msts=3640205228.15629.0000\r\n")},
+		},
+		{
+			name: "routing token without terminator",
+			req:  ClientConnectionRequest{RoutingToken: []byte("Cookie: msts=3640205228.15629.0000")},
+		},
+		{
+			name:        "cookie with CR+LF",
+			req:         ClientConnectionRequest{Cookie: "eltons\r\nX-Injected: 1"},
+			expectedErr: ErrInvalidCookie,
+		},
+		{
+			name:        "cookie with LF",
+			req:         ClientConnectionRequest{Cookie: "elt\nons"},
+			expectedErr: ErrInvalidCookie,
+		},
+		{
+			name:        "cookie too long",
+			req:         ClientConnectionRequest{Cookie: string(bytes.Repeat([]byte{'a'}, MaxCookieLength+1))},
+			expectedErr: ErrInvalidCookie,
+		},
+		{
+			name:        "routing token with embedded CR+LF",
+			req:         ClientConnectionRequest{RoutingToken: []byte("Cookie: msts=1\r\n\x01\x00\x08\x00\r\n")},
+			expectedErr: ErrInvalidRoutingToken,
+		},
+		{
+			name:        "routing token too long",
+			req:         ClientConnectionRequest{RoutingToken: bytes.Repeat([]byte{'a'}, MaxRoutingTokenLength-1)},
+			expectedErr: ErrInvalidRoutingToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorIs(t, tc.req.Validate(), tc.expectedErr)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package pdu
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -21,6 +22,9 @@ const (
 
 	// TypeData PDUTYPE_DATAPDU
 	TypeData Type = 0x17
+
+	// TypeServerRedirect PDUTYPE_SERVER_REDIR_PKT
+	TypeServerRedirect Type = 0x0A
 )
 
 func (t Type) IsDemandActive() bool {
@@ -39,6 +43,10 @@ func (t Type) IsData() bool {
 	return t == TypeData
 }
 
+func (t Type) IsServerRedirect() bool {
+	return t == TypeServerRedirect
+}
+
 type ShareControlHeader struct {
 	TotalLength uint16
 	PDUType     Type
@@ -185,6 +193,10 @@ func (header *ShareDataHeader) Deserialize(wire io.Reader) error {
 		return ErrDeactiateAll
 	}
 
+	if header.ShareControlHeader.PDUType.IsServerRedirect() {
+		return ErrServerRedirection
+	}
+
 	err = binary.Read(wire, binary.LittleEndian, &header.ShareID)
 	if err != nil {
 		return err
@@ -230,6 +242,8 @@ type Data struct {
 	FontListPDUData    *FontListPDUData
 	FontMapPDUData     *FontMapPDUData
 	ErrorInfoPDUData   *ErrorInfoPDUData
+	ServerRedirection  *EnhancedSecurityServerRedirection
+	SaveSessionInfo    *SaveSessionInfoPDUData
 }
 
 func (pdu *Data) Serialize() []byte {
@@ -258,7 +272,14 @@ func (pdu *Data) Serialize() []byte {
 func (pdu *Data) Deserialize(wire io.Reader) error {
 	var err error
 
-	if err = pdu.ShareDataHeader.Deserialize(wire); err != nil {
+	err = pdu.ShareDataHeader.Deserialize(wire)
+	if errors.Is(err, ErrServerRedirection) {
+		pdu.ServerRedirection = &EnhancedSecurityServerRedirection{}
+
+		return pdu.ServerRedirection.Deserialize(wire)
+	}
+
+	if err != nil {
 		return err
 	}
 
@@ -279,8 +300,10 @@ func (pdu *Data) Deserialize(wire io.Reader) error {
 		pdu.ErrorInfoPDUData = &ErrorInfoPDUData{}
 
 		return pdu.ErrorInfoPDUData.Deserialize(wire)
-	case pdu.ShareDataHeader.PDUType2.IsSaveSessionInfo(): // ignore
-		return nil
+	case pdu.ShareDataHeader.PDUType2.IsSaveSessionInfo():
+		pdu.SaveSessionInfo = &SaveSessionInfoPDUData{}
+
+		return pdu.SaveSessionInfo.Deserialize(wire)
 	}
 
 	return fmt.Errorf("unknown data pdu: %d", pdu.ShareDataHeader.PDUType2)
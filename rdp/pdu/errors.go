@@ -5,4 +5,11 @@ import "errors"
 var (
 	ErrInvalidCorrelationID = errors.New("invalid correlationId")
 	ErrDeactiateAll         = errors.New("deactivate all")
+	ErrServerRedirection    = errors.New("server redirection")
+	ErrInvalidRedirection   = errors.New("invalid server redirection packet")
+	ErrInvalidCookie        = errors.New("mstshash cookie contains CR or LF or is too long")
+	ErrInvalidRoutingToken  = errors.New("routing token contains CR or LF before the terminator or is too long")
+
+	ErrInvalidAutoReconnectCookie = errors.New("invalid auto-reconnect cookie")
+	ErrUnknownLicensingMessage    = errors.New("unknown licensing message")
 )
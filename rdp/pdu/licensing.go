@@ -1,11 +1,89 @@
 package pdu
 
 import (
+	"bytes"
 	"encoding/binary"
-	"errors"
 	"io"
+)
+
+// LicensingMessageType Licensing Preamble bMsgType.
+type LicensingMessageType uint8
+
+const (
+	// LicensingMessageTypeLicenseRequest LICENSE_REQUEST
+	LicensingMessageTypeLicenseRequest LicensingMessageType = 0x01
+
+	// LicensingMessageTypePlatformChallenge PLATFORM_CHALLENGE
+	LicensingMessageTypePlatformChallenge LicensingMessageType = 0x02
+
+	// LicensingMessageTypeNewLicense NEW_LICENSE
+	LicensingMessageTypeNewLicense LicensingMessageType = 0x03
+
+	// LicensingMessageTypeUpgradeLicense UPGRADE_LICENSE
+	LicensingMessageTypeUpgradeLicense LicensingMessageType = 0x04
+
+	// LicensingMessageTypeLicenseInfo LICENSE_INFO
+	LicensingMessageTypeLicenseInfo LicensingMessageType = 0x12
+
+	// LicensingMessageTypeNewLicenseRequest NEW_LICENSE_REQUEST
+	LicensingMessageTypeNewLicenseRequest LicensingMessageType = 0x13
+
+	// LicensingMessageTypePlatformChallengeResponse PLATFORM_CHALLENGE_RESPONSE
+	LicensingMessageTypePlatformChallengeResponse LicensingMessageType = 0x15
+
+	// LicensingMessageTypeErrorAlert ERROR_ALERT
+	LicensingMessageTypeErrorAlert LicensingMessageType = 0xFF
+)
+
+// LicensingBlobType Licensing Binary Blob wBlobType.
+type LicensingBlobType uint16
+
+const (
+	// LicensingBlobTypeAny BB_ANY_BLOB
+	LicensingBlobTypeAny LicensingBlobType = 0x0000
+
+	// LicensingBlobTypeData BB_DATA_BLOB
+	LicensingBlobTypeData LicensingBlobType = 0x0001
+
+	// LicensingBlobTypeRandom BB_RANDOM_BLOB
+	LicensingBlobTypeRandom LicensingBlobType = 0x0002
+
+	// LicensingBlobTypeCertificate BB_CERTIFICATE_BLOB
+	LicensingBlobTypeCertificate LicensingBlobType = 0x0003
+
+	// LicensingBlobTypeError BB_ERROR_BLOB
+	LicensingBlobTypeError LicensingBlobType = 0x0004
+
+	// LicensingBlobTypeEncryptedData BB_ENCRYPTED_DATA_BLOB
+	LicensingBlobTypeEncryptedData LicensingBlobType = 0x0009
+
+	// LicensingBlobTypeKeyExchangeAlg BB_KEY_EXCHG_ALG_BLOB
+	LicensingBlobTypeKeyExchangeAlg LicensingBlobType = 0x000D
+
+	// LicensingBlobTypeScope BB_SCOPE_BLOB
+	LicensingBlobTypeScope LicensingBlobType = 0x000E
+
+	// LicensingBlobTypeClientUserName BB_CLIENT_USER_NAME_BLOB
+	LicensingBlobTypeClientUserName LicensingBlobType = 0x000F
+
+	// LicensingBlobTypeClientMachineName BB_CLIENT_MACHINE_NAME_BLOB
+	LicensingBlobTypeClientMachineName LicensingBlobType = 0x0010
+)
+
+const (
+	// LicensingErrorCodeStatusValidClient STATUS_VALID_CLIENT
+	LicensingErrorCodeStatusValidClient uint32 = 0x00000007
+
+	// LicensingStateTransitionNoTransition ST_NO_TRANSITION
+	LicensingStateTransitionNoTransition uint32 = 0x00000002
 
-	"github.com/lunnik9/rdp/rdp/headers"
+	// KeyExchangeAlgRSA KEY_EXCHANGE_ALG_RSA
+	KeyExchangeAlgRSA uint32 = 0x00000001
+
+	// licensingPreambleVersion PREAMBLE_VERSION_3_0
+	licensingPreambleVersion uint8 = 0x03
+
+	licensingMACLen = 16
 )
 
 type LicensingBinaryBlob struct {
@@ -14,9 +92,34 @@ type LicensingBinaryBlob struct {
 	BlobData []byte
 }
 
+func newLicensingBinaryBlob(blobType LicensingBlobType, data []byte) LicensingBinaryBlob {
+	return LicensingBinaryBlob{
+		BlobType: uint16(blobType),
+		BlobLen:  uint16(len(data)),
+		BlobData: data,
+	}
+}
+
+func (b *LicensingBinaryBlob) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, b.BlobType)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(b.BlobData)))
+	buf.Write(b.BlobData)
+
+	return buf.Bytes()
+}
+
 func (b *LicensingBinaryBlob) Deserialize(wire io.Reader) error {
-	binary.Read(wire, binary.LittleEndian, &b.BlobType)
-	binary.Read(wire, binary.LittleEndian, &b.BlobLen)
+	err := binary.Read(wire, binary.LittleEndian, &b.BlobType)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &b.BlobLen)
+	if err != nil {
+		return err
+	}
 
 	if b.BlobLen == 0 {
 		return nil
@@ -24,7 +127,7 @@ func (b *LicensingBinaryBlob) Deserialize(wire io.Reader) error {
 
 	b.BlobData = make([]byte, b.BlobLen)
 
-	if _, err := wire.Read(b.BlobData); err != nil {
+	if _, err = io.ReadFull(wire, b.BlobData); err != nil {
 		return err
 	}
 
@@ -38,50 +141,356 @@ type LicensingErrorMessage struct {
 }
 
 func (m *LicensingErrorMessage) Deserialize(wire io.Reader) error {
-	binary.Read(wire, binary.LittleEndian, &m.ErrorCode)
-	binary.Read(wire, binary.LittleEndian, &m.StateTransition)
+	err := binary.Read(wire, binary.LittleEndian, &m.ErrorCode)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &m.StateTransition)
+	if err != nil {
+		return err
+	}
 
 	return m.ErrorInfo.Deserialize(wire)
 }
 
+// IsValidClient reports whether the server does not require a license (STATUS_VALID_CLIENT, ST_NO_TRANSITION).
+func (m *LicensingErrorMessage) IsValidClient() bool {
+	return m.ErrorCode == LicensingErrorCodeStatusValidClient && m.StateTransition == LicensingStateTransitionNoTransition
+}
+
 type LicensingPreamble struct {
-	MsgType uint8
+	MsgType LicensingMessageType
 	Flags   uint8
 	MsgSize uint16
 }
 
+func (p *LicensingPreamble) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, p.MsgType)
+	_ = binary.Write(buf, binary.LittleEndian, p.Flags)
+	_ = binary.Write(buf, binary.LittleEndian, p.MsgSize)
+
+	return buf.Bytes()
+}
+
 func (p *LicensingPreamble) Deserialize(wire io.Reader) error {
-	binary.Read(wire, binary.LittleEndian, &p.MsgType)
-	binary.Read(wire, binary.LittleEndian, &p.Flags)
-	binary.Read(wire, binary.LittleEndian, &p.MsgSize)
+	err := binary.Read(wire, binary.LittleEndian, &p.MsgType)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	err = binary.Read(wire, binary.LittleEndian, &p.Flags)
+	if err != nil {
+		return err
+	}
+
+	return binary.Read(wire, binary.LittleEndian, &p.MsgSize)
+}
+
+// wrapLicensingPreamble prepends the Licensing Preamble to the client licensing message.
+func wrapLicensingPreamble(msgType LicensingMessageType, message []byte) []byte {
+	preamble := LicensingPreamble{
+		MsgType: msgType,
+		Flags:   licensingPreambleVersion,
+		MsgSize: uint16(4 + len(message)),
+	}
+
+	return append(preamble.Serialize(), message...)
 }
 
-type ServerLicenseError struct {
-	Preamble           LicensingPreamble
-	ValidClientMessage LicensingErrorMessage
+// ProductInfo Product Information (PRODUCT_INFO).
+type ProductInfo struct {
+	Version     uint32
+	CompanyName []byte
+	ProductID   []byte
 }
 
-func (pdu *ServerLicenseError) Deserialize(wire io.Reader) error {
-	securityFlag, err := headers.UnwrapSecurityFlag(wire)
+func (i *ProductInfo) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &i.Version)
 	if err != nil {
 		return err
 	}
 
-	if securityFlag != 0x0080 { // SEC_LICENSE_PKT
-		return errors.New("bad license header")
+	if i.CompanyName, err = readLicensingBytes(wire); err != nil {
+		return err
 	}
 
-	err = pdu.Preamble.Deserialize(wire)
+	i.ProductID, err = readLicensingBytes(wire)
+
+	return err
+}
+
+// ServerLicenseRequest Server License Request (SERVER_LICENSE_REQUEST).
+type ServerLicenseRequest struct {
+	ServerRandom      [32]byte
+	ProductInfo       ProductInfo
+	KeyExchangeList   LicensingBinaryBlob
+	ServerCertificate LicensingBinaryBlob
+	ScopeList         []LicensingBinaryBlob
+}
+
+func (r *ServerLicenseRequest) Deserialize(wire io.Reader) error {
+	_, err := io.ReadFull(wire, r.ServerRandom[:])
 	if err != nil {
 		return err
 	}
 
-	err = pdu.ValidClientMessage.Deserialize(wire)
+	if err = r.ProductInfo.Deserialize(wire); err != nil {
+		return err
+	}
+
+	if err = r.KeyExchangeList.Deserialize(wire); err != nil {
+		return err
+	}
+
+	if err = r.ServerCertificate.Deserialize(wire); err != nil {
+		return err
+	}
+
+	var scopeCount uint32
+	if err = binary.Read(wire, binary.LittleEndian, &scopeCount); err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < scopeCount; i++ {
+		var scope LicensingBinaryBlob
+		if err = scope.Deserialize(wire); err != nil {
+			return err
+		}
+
+		r.ScopeList = append(r.ScopeList, scope)
+	}
+
+	return nil
+}
+
+// ServerPlatformChallenge Server Platform Challenge (SERVER_PLATFORM_CHALLENGE).
+type ServerPlatformChallenge struct {
+	ConnectFlags               uint32
+	EncryptedPlatformChallenge LicensingBinaryBlob
+	MACData                    [licensingMACLen]byte
+}
+
+func (c *ServerPlatformChallenge) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &c.ConnectFlags)
 	if err != nil {
 		return err
 	}
 
+	if err = c.EncryptedPlatformChallenge.Deserialize(wire); err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(wire, c.MACData[:])
+
+	return err
+}
+
+// ServerNewLicense Server New License (SERVER_NEW_LICENSE) or Server Upgrade License (SERVER_UPGRADE_LICENSE).
+type ServerNewLicense struct {
+	EncryptedLicenseInfo LicensingBinaryBlob
+	MACData              [licensingMACLen]byte
+}
+
+func (l *ServerNewLicense) Deserialize(wire io.Reader) error {
+	if err := l.EncryptedLicenseInfo.Deserialize(wire); err != nil {
+		return err
+	}
+
+	_, err := io.ReadFull(wire, l.MACData[:])
+
+	return err
+}
+
+// NewLicenseInfo New License Information (NEW_LICENSE_INFO), decrypted from the Server New License.
+type NewLicenseInfo struct {
+	Version     uint32
+	Scope       []byte
+	CompanyName []byte
+	ProductID   []byte
+	LicenseInfo []byte
+}
+
+func (i *NewLicenseInfo) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &i.Version)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range []*[]byte{&i.Scope, &i.CompanyName, &i.ProductID, &i.LicenseInfo} {
+		if *field, err = readLicensingBytes(wire); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// ServerLicensing licensing PDU sent by the server, one of the messages is set according to the preamble.
+type ServerLicensing struct {
+	Preamble          LicensingPreamble
+	LicenseRequest    *ServerLicenseRequest
+	PlatformChallenge *ServerPlatformChallenge
+	NewLicense        *ServerNewLicense
+	ErrorMessage      *LicensingErrorMessage
+}
+
+func (pdu *ServerLicensing) Deserialize(wire io.Reader) error {
+	err := pdu.Preamble.Deserialize(wire)
+	if err != nil {
+		return err
+	}
+
+	switch pdu.Preamble.MsgType {
+	case LicensingMessageTypeLicenseRequest:
+		pdu.LicenseRequest = &ServerLicenseRequest{}
+
+		return pdu.LicenseRequest.Deserialize(wire)
+	case LicensingMessageTypePlatformChallenge:
+		pdu.PlatformChallenge = &ServerPlatformChallenge{}
+
+		return pdu.PlatformChallenge.Deserialize(wire)
+	case LicensingMessageTypeNewLicense, LicensingMessageTypeUpgradeLicense:
+		pdu.NewLicense = &ServerNewLicense{}
+
+		return pdu.NewLicense.Deserialize(wire)
+	case LicensingMessageTypeErrorAlert:
+		pdu.ErrorMessage = &LicensingErrorMessage{}
+
+		return pdu.ErrorMessage.Deserialize(wire)
+	}
+
+	return ErrUnknownLicensingMessage
+}
+
+// ClientNewLicenseRequest Client New License Request (CLIENT_NEW_LICENSE_REQUEST).
+type ClientNewLicenseRequest struct {
+	PlatformID               uint32
+	ClientRandom             []byte
+	EncryptedPreMasterSecret []byte
+	ClientUserName           string
+	ClientMachineName        string
+}
+
+func (r *ClientNewLicenseRequest) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, KeyExchangeAlgRSA) // PreferredKeyExchangeAlg
+	_ = binary.Write(buf, binary.LittleEndian, r.PlatformID)
+	buf.Write(r.ClientRandom)
+
+	for _, blob := range []LicensingBinaryBlob{
+		newLicensingBinaryBlob(LicensingBlobTypeRandom, r.EncryptedPreMasterSecret),
+		newLicensingBinaryBlob(LicensingBlobTypeClientUserName, append([]byte(r.ClientUserName), 0x00)),
+		newLicensingBinaryBlob(LicensingBlobTypeClientMachineName, append([]byte(r.ClientMachineName), 0x00)),
+	} {
+		buf.Write(blob.Serialize())
+	}
+
+	return wrapLicensingPreamble(LicensingMessageTypeNewLicenseRequest, buf.Bytes())
+}
+
+// ClientLicenseInfo Client License Information (CLIENT_LICENSE_INFO), presents the stored license.
+type ClientLicenseInfo struct {
+	PlatformID               uint32
+	ClientRandom             []byte
+	EncryptedPreMasterSecret []byte
+	LicenseInfo              []byte
+	EncryptedHWID            []byte
+	MACData                  []byte
+}
+
+func (i *ClientLicenseInfo) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, KeyExchangeAlgRSA) // PreferredKeyExchangeAlg
+	_ = binary.Write(buf, binary.LittleEndian, i.PlatformID)
+	buf.Write(i.ClientRandom)
+
+	for _, blob := range []LicensingBinaryBlob{
+		newLicensingBinaryBlob(LicensingBlobTypeRandom, i.EncryptedPreMasterSecret),
+		newLicensingBinaryBlob(LicensingBlobTypeData, i.LicenseInfo),
+		newLicensingBinaryBlob(LicensingBlobTypeEncryptedData, i.EncryptedHWID),
+	} {
+		buf.Write(blob.Serialize())
+	}
+
+	buf.Write(i.MACData)
+
+	return wrapLicensingPreamble(LicensingMessageTypeLicenseInfo, buf.Bytes())
+}
+
+// ClientPlatformChallengeResponse Client Platform Challenge Response (CLIENT_PLATFORM_CHALLENGE_RESPONSE).
+type ClientPlatformChallengeResponse struct {
+	EncryptedPlatformChallengeResponse []byte
+	EncryptedHWID                      []byte
+	MACData                            []byte
+}
+
+func (r *ClientPlatformChallengeResponse) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	for _, blob := range []LicensingBinaryBlob{
+		newLicensingBinaryBlob(LicensingBlobTypeEncryptedData, r.EncryptedPlatformChallengeResponse),
+		newLicensingBinaryBlob(LicensingBlobTypeEncryptedData, r.EncryptedHWID),
+	} {
+		buf.Write(blob.Serialize())
+	}
+
+	buf.Write(r.MACData)
+
+	return wrapLicensingPreamble(LicensingMessageTypePlatformChallengeResponse, buf.Bytes())
+}
+
+// PlatformChallengeResponseData Platform Challenge Response Data (PLATFORM_CHALLENGE_RESPONSE_DATA).
+type PlatformChallengeResponseData struct {
+	Challenge []byte
+}
+
+func (d *PlatformChallengeResponseData) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0x0100)) // wVersion
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0xFF00)) // wClientType OTHER_PLATFORM_CHALLENGE_TYPE
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0x0003)) // wLicenseDetailLevel LICENSE_DETAIL_DETAIL
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(d.Challenge)))
+	buf.Write(d.Challenge)
+
+	return buf.Bytes()
+}
+
+// ClientHardwareID Client Hardware Identification (CLIENT_HARDWARE_ID).
+type ClientHardwareID struct {
+	PlatformID uint32
+	Data       [16]byte
+}
+
+func (id *ClientHardwareID) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, id.PlatformID)
+	buf.Write(id.Data[:])
+
+	return buf.Bytes()
+}
+
+// readLicensingBytes reads a field preceded by its 32-bit length.
+func readLicensingBytes(wire io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(wire, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+
+	if length > 0xFFFF { // licensing PDUs are never longer
+		return nil, ErrUnknownLicensingMessage
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(wire, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,202 @@
+package pdu
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"io"
+)
+
+// InfoType Save Session Info PDU infoType.
+type InfoType uint32
+
+const (
+	// InfoTypeLogon INFOTYPE_LOGON
+	InfoTypeLogon InfoType = 0x00000000
+
+	// InfoTypeLogonLong INFOTYPE_LOGON_LONG
+	InfoTypeLogonLong InfoType = 0x00000001
+
+	// InfoTypeLogonPlainNotify INFOTYPE_LOGON_PLAINNOTIFY
+	InfoTypeLogonPlainNotify InfoType = 0x00000002
+
+	// InfoTypeLogonExtendedInfo INFOTYPE_LOGON_EXTENDED_INFO
+	InfoTypeLogonExtendedInfo InfoType = 0x00000003
+)
+
+func (t InfoType) IsLogonExtendedInfo() bool {
+	return t == InfoTypeLogonExtendedInfo
+}
+
+// SaveSessionInfoPDUData Save Session Info PDU Data (TS_SAVE_SESSION_INFO_PDU_DATA),
+// only the extended logon info is parsed.
+type SaveSessionInfoPDUData struct {
+	InfoType          InfoType
+	LogonInfoExtended *LogonInfoExtended
+}
+
+func (pdu *SaveSessionInfoPDUData) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &pdu.InfoType)
+	if err != nil {
+		return err
+	}
+
+	if !pdu.InfoType.IsLogonExtendedInfo() {
+		return nil
+	}
+
+	pdu.LogonInfoExtended = &LogonInfoExtended{}
+
+	return pdu.LogonInfoExtended.Deserialize(wire)
+}
+
+const (
+	// logonExFieldAutoReconnectCookie LOGON_EX_AUTORECONNECTCOOKIE
+	logonExFieldAutoReconnectCookie uint32 = 0x00000001
+
+	// logonExFieldLogonErrors LOGON_EX_LOGONERRORS
+	logonExFieldLogonErrors uint32 = 0x00000002
+)
+
+// LogonInfoExtended Logon Info Extended (TS_LOGON_INFO_EXTENDED).
+type LogonInfoExtended struct {
+	AutoReconnect *ServerAutoReconnect
+	LogonErrors   *LogonErrorsInfo
+}
+
+func (i *LogonInfoExtended) Deserialize(wire io.Reader) error {
+	var (
+		length        uint16
+		fieldsPresent uint32
+		err           error
+	)
+
+	if err = binary.Read(wire, binary.LittleEndian, &length); err != nil {
+		return err
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &fieldsPresent); err != nil {
+		return err
+	}
+
+	// logon fields in the order of the flags
+	if fieldsPresent&logonExFieldAutoReconnectCookie == logonExFieldAutoReconnectCookie {
+		i.AutoReconnect = &ServerAutoReconnect{}
+
+		if err = deserializeLogonField(wire, i.AutoReconnect.Deserialize); err != nil {
+			return err
+		}
+	}
+
+	if fieldsPresent&logonExFieldLogonErrors == logonExFieldLogonErrors {
+		i.LogonErrors = &LogonErrorsInfo{}
+
+		if err = deserializeLogonField(wire, i.LogonErrors.Deserialize); err != nil {
+			return err
+		}
+	}
+
+	// the rest is padding
+	return nil
+}
+
+// deserializeLogonField parses Logon Info Field (TS_LOGON_INFO_FIELD).
+func deserializeLogonField(wire io.Reader, deserialize func(io.Reader) error) error {
+	var cbFieldData uint32
+	if err := binary.Read(wire, binary.LittleEndian, &cbFieldData); err != nil {
+		return err
+	}
+
+	// cbFieldData of the server is not trusted with the allocation
+	fieldData, err := io.ReadAll(io.LimitReader(wire, int64(cbFieldData)))
+	if err != nil {
+		return err
+	}
+
+	if len(fieldData) < int(cbFieldData) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return deserialize(bytes.NewReader(fieldData))
+}
+
+// ServerAutoReconnect Server Auto-Reconnect Packet (ARC_SC_PRIVATE_PACKET).
+type ServerAutoReconnect struct {
+	Version       uint32
+	LogonID       uint32
+	ArcRandomBits [16]byte
+}
+
+func (p *ServerAutoReconnect) Deserialize(wire io.Reader) error {
+	var cbLen uint32
+
+	err := binary.Read(wire, binary.LittleEndian, &cbLen)
+	if err != nil {
+		return err
+	}
+
+	if cbLen != 0x1C {
+		return ErrInvalidAutoReconnectCookie
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(wire, binary.LittleEndian, &p.LogonID)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(wire, p.ArcRandomBits[:])
+
+	return err
+}
+
+// LogonErrorsInfo Logon Errors Info (TS_LOGON_ERRORS_INFO).
+type LogonErrorsInfo struct {
+	ErrorNotificationType uint32
+	ErrorNotificationData uint32
+}
+
+func (i *LogonErrorsInfo) Deserialize(wire io.Reader) error {
+	err := binary.Read(wire, binary.LittleEndian, &i.ErrorNotificationType)
+	if err != nil {
+		return err
+	}
+
+	return binary.Read(wire, binary.LittleEndian, &i.ErrorNotificationData)
+}
+
+// ClientAutoReconnect Client Auto-Reconnect Packet (ARC_CS_PRIVATE_PACKET).
+type ClientAutoReconnect struct {
+	LogonID          uint32
+	SecurityVerifier [16]byte
+}
+
+// NewClientAutoReconnect answers the server cookie with the HMAC-MD5 of the client random keyed by the random bits.
+func NewClientAutoReconnect(cookie *ServerAutoReconnect, clientRandom []byte) *ClientAutoReconnect {
+	mac := hmac.New(md5.New, cookie.ArcRandomBits[:])
+	mac.Write(clientRandom)
+
+	p := ClientAutoReconnect{
+		LogonID: cookie.LogonID,
+	}
+
+	copy(p.SecurityVerifier[:], mac.Sum(nil))
+
+	return &p
+}
+
+func (p *ClientAutoReconnect) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint32(0x1C)) // cbLen
+	_ = binary.Write(buf, binary.LittleEndian, uint32(1))    // Version AUTO_RECONNECT_VERSION_1
+	_ = binary.Write(buf, binary.LittleEndian, p.LogonID)
+	buf.Write(p.SecurityVerifier[:])
+
+	return buf.Bytes()
+}
@@ -0,0 +1,84 @@
+package pdu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestData_DeserializeSaveSessionInfo(t *testing.T) {
+	input := bytes.NewBuffer([]byte{
+		0x00, 0x00, 0x17, 0x00, 0xea, 0x03, 0xea, 0x03, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x26, 0x00,
+		0x00, 0x00, // share data header PDUTYPE2_SAVE_SESSION_INFO
+		0x03, 0x00, 0x00, 0x00, // INFOTYPE_LOGON_EXTENDED_INFO
+		0x32, 0x00, // length
+		0x03, 0x00, 0x00, 0x00, // LOGON_EX_AUTORECONNECTCOOKIE | LOGON_EX_LOGONERRORS
+		0x1c, 0x00, 0x00, 0x00, // cbFieldData
+		0x1c, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, // cbLen, Version, LogonId
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, // ArcRandomBits
+		0x08, 0x00, 0x00, 0x00, // cbFieldData
+		0xfe, 0xff, 0xff, 0xff, 0x02, 0x00, 0x00, 0x00, // LOGON_MSG_SESSION_CONTINUE
+	})
+	input.Write(make([]byte, 570)) // pad
+
+	var actual Data
+
+	require.NoError(t, actual.Deserialize(input))
+	require.NotNil(t, actual.SaveSessionInfo)
+	require.True(t, actual.SaveSessionInfo.InfoType.IsLogonExtendedInfo())
+
+	info := actual.SaveSessionInfo.LogonInfoExtended
+
+	require.Equal(t, &ServerAutoReconnect{
+		Version:       1,
+		LogonID:       2,
+		ArcRandomBits: [16]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+	}, info.AutoReconnect)
+	require.Equal(t, &LogonErrorsInfo{ErrorNotificationType: 0xfffffffe, ErrorNotificationData: 2}, info.LogonErrors)
+}
+
+func TestSaveSessionInfoPDUData_DeserializeLogon(t *testing.T) {
+	input := bytes.NewBuffer([]byte{0x02, 0x00, 0x00, 0x00}) // INFOTYPE_LOGON_PLAINNOTIFY
+	input.Write(make([]byte, 576))
+
+	var actual SaveSessionInfoPDUData
+
+	require.NoError(t, actual.Deserialize(input))
+	require.Nil(t, actual.LogonInfoExtended)
+}
+
+func TestLogonInfoExtended_DeserializeFieldTooLong(t *testing.T) {
+	input := bytes.NewBuffer([]byte{
+		0x32, 0x00, // length
+		0x01, 0x00, 0x00, 0x00, // LOGON_EX_AUTORECONNECTCOOKIE
+		0xff, 0xff, 0xff, 0xff, // cbFieldData beyond the packet
+		0x1c, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+	})
+
+	var actual LogonInfoExtended
+
+	require.ErrorIs(t, actual.Deserialize(input), io.ErrUnexpectedEOF)
+}
+
+func TestNewClientAutoReconnect(t *testing.T) {
+	cookie := ServerAutoReconnect{
+		Version:       1,
+		LogonID:       2,
+		ArcRandomBits: [16]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+	}
+
+	expected, err := hex.DecodeString("1c00000001000000" + "02000000" + "b639c8731638618b707972aa6e96cf90")
+	require.NoError(t, err)
+
+	actual := NewClientAutoReconnect(&cookie, make([]byte, 32))
+
+	require.Equal(t, expected, actual.Serialize())
+
+	packet := ExtendedInfoPacket{AutoReconnectCookie: actual}
+	serialized := packet.Serialize()
+
+	require.Equal(t, append([]byte{0x1c, 0x00}, expected...), serialized[len(serialized)-30:])
+}
@@ -74,7 +74,8 @@ const (
 )
 
 type ExtendedInfoPacket struct {
-	PerformanceFlags uint32
+	PerformanceFlags    uint32
+	AutoReconnectCookie *ClientAutoReconnect
 }
 
 func (p *ExtendedInfoPacket) Serialize() []byte {
@@ -89,6 +90,13 @@ func (p *ExtendedInfoPacket) Serialize() []byte {
 	binary.Write(buf, binary.LittleEndian, uint32(0))      // ClientSessionId
 	binary.Write(buf, binary.LittleEndian, p.PerformanceFlags)
 
+	if p.AutoReconnectCookie != nil {
+		cookie := p.AutoReconnectCookie.Serialize()
+
+		binary.Write(buf, binary.LittleEndian, uint16(len(cookie))) // cbAutoReconnectCookie
+		buf.Write(cookie)
+	}
+
 	return buf.Bytes()
 }
 
@@ -98,6 +106,7 @@ type ClientInfoPacket struct {
 	Domain         string
 	Username       string
 	Password       string
+	PasswordCookie []byte // password cookie of a server redirection, replaces Password
 	AlternateShell string
 	WorkingDir     string
 	ExtraInfo      ExtendedInfoPacket
@@ -131,6 +140,15 @@ func (p *ClientInfoPacket) Serialize() []byte {
 		cbPassword = uint16(len(password) - 2)
 	}
 
+	if len(p.PasswordCookie) > 0 {
+		password = p.PasswordCookie
+		if !bytes.HasSuffix(password, []byte{0x00, 0x00}) {
+			password = append(password[:len(password):len(password)], 0x00, 0x00)
+		}
+
+		cbPassword = uint16(len(password) - 2)
+	}
+
 	if len(p.AlternateShell) > 0 {
 		alternateShell = utf16.Encode(strings.Trim(p.AlternateShell, " ") + "\x00")
 		cbAlternateShell = uint16(len(alternateShell) - 2)
@@ -235,20 +253,24 @@ const (
 	CompressionTypeRDP61 uint32 = 0x3
 )
 
-func NewClientInfo(domain, username, password string) *ClientInfo {
-	return &ClientInfo{
+func NewClientInfo(domain, username, password string, settings *ClientSettings) *ClientInfo {
+	pdu := ClientInfo{
 		InfoPacket: ClientInfoPacket{
-			Flags:     InfoFlagMouse | InfoFlagUnicode | InfoFlagAutoLogon | InfoFlagDisableCtrlAltDel | InfoFlagEnableWindowsKey,
-			Domain:    domain,
-			Username:  username,
-			Password:  password,
+			Flags:    InfoFlagMouse | InfoFlagUnicode | InfoFlagAutoLogon | InfoFlagDisableCtrlAltDel | InfoFlagEnableWindowsKey,
+			Domain:   domain,
+			Username: username,
+			Password: password,
 			ExtraInfo: ExtendedInfoPacket{
-				//// PERF_DISABLE_WALLPAPER, PERF_DISABLE_FULLWINDOWDRAG, PERF_DISABLE_MENUANIMATIONS,
-				//// PERF_DISABLE_THEMING, PERF_DISABLE_CURSOR_SHADOW, PERF_DISABLE_CURSORSETTINGS
-				//PerformanceFlags: 0x00000001 | 0x00000002 | 0x00000004 | 0x00000008 | 0x00000020 | 0x00000040,
+				PerformanceFlags: settings.PerformanceFlags,
 			},
 		},
 	}
+
+	if settings.Compression {
+		pdu.InfoPacket.Flags |= InfoFlagCompression | InfoFlag(settings.CompressionType<<9&CompressionTypeMask)
+	}
+
+	return &pdu
 }
 
 func (pdu *ClientInfo) Serialize() []byte {
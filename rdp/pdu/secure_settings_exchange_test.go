@@ -7,7 +7,7 @@ import (
 )
 
 func TestNewClientInfoPDU_Serialize(t *testing.T) {
-	req := NewClientInfo("192.168.1.2", "User", "p@$$w0rd")
+	req := NewClientInfo("192.168.1.2", "User", "p@$$w0rd", NewClientSettings(1280, 1024))
 	req.InfoPacket.Flags = 0x00010153
 	req.InfoPacket.ExtraInfo.PerformanceFlags = 0x0
 
@@ -35,3 +35,16 @@ func TestNewClientInfoPDU_Serialize(t *testing.T) {
 
 	require.Equal(t, expected, actual)
 }
+
+func TestClientInfoPacket_SerializePasswordCookie(t *testing.T) {
+	packet := ClientInfoPacket{
+		Password:       "p@$$w0rd",
+		PasswordCookie: []byte{0x01, 0x02, 0x03, 0x04},
+	}
+
+	actual := packet.Serialize()
+
+	require.Equal(t, []byte{0x04, 0x00}, actual[12:14]) // cbPassword
+	require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04, 0x00, 0x00}, actual[22:28])
+	require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, packet.PasswordCookie)
+}
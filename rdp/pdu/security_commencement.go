@@ -0,0 +1,20 @@
+package pdu
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// ClientSecurityExchange Client Security Exchange PDU data, without the security header.
+type ClientSecurityExchange struct {
+	EncryptedClientRandom []byte // including 8 bytes of padding
+}
+
+func (pdu *ClientSecurityExchange) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(pdu.EncryptedClientRandom)))
+	buf.Write(pdu.EncryptedClientRandom)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,228 @@
+package pdu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+// RedirectionFlag Server Redirection Packet RedirFlags.
+type RedirectionFlag uint32
+
+const (
+	// RedirectionFlagTargetNetAddress LB_TARGET_NET_ADDRESS
+	RedirectionFlagTargetNetAddress RedirectionFlag = 0x00000001
+
+	// RedirectionFlagLoadBalanceInfo LB_LOAD_BALANCE_INFO
+	RedirectionFlagLoadBalanceInfo RedirectionFlag = 0x00000002
+
+	// RedirectionFlagUsername LB_USERNAME
+	RedirectionFlagUsername RedirectionFlag = 0x00000004
+
+	// RedirectionFlagDomain LB_DOMAIN
+	RedirectionFlagDomain RedirectionFlag = 0x00000008
+
+	// RedirectionFlagPassword LB_PASSWORD
+	RedirectionFlagPassword RedirectionFlag = 0x00000010
+
+	// RedirectionFlagDontStoreUsername LB_DONTSTOREUSERNAME
+	RedirectionFlagDontStoreUsername RedirectionFlag = 0x00000020
+
+	// RedirectionFlagSmartcardLogon LB_SMARTCARD_LOGON
+	RedirectionFlagSmartcardLogon RedirectionFlag = 0x00000040
+
+	// RedirectionFlagNoRedirect LB_NOREDIRECT
+	RedirectionFlagNoRedirect RedirectionFlag = 0x00000080
+
+	// RedirectionFlagTargetFQDN LB_TARGET_FQDN
+	RedirectionFlagTargetFQDN RedirectionFlag = 0x00000100
+
+	// RedirectionFlagTargetNetBiosName LB_TARGET_NETBIOS_NAME
+	RedirectionFlagTargetNetBiosName RedirectionFlag = 0x00000200
+
+	// RedirectionFlagTargetNetAddresses LB_TARGET_NET_ADDRESSES
+	RedirectionFlagTargetNetAddresses RedirectionFlag = 0x00000800
+
+	// RedirectionFlagClientTSVURL LB_CLIENT_TSV_URL
+	RedirectionFlagClientTSVURL RedirectionFlag = 0x00001000
+
+	// RedirectionFlagServerTSVCapable LB_SERVER_TSV_CAPABLE
+	RedirectionFlagServerTSVCapable RedirectionFlag = 0x00002000
+
+	// RedirectionFlagPasswordIsPKEncrypted LB_PASSWORD_IS_PK_ENCRYPTED
+	RedirectionFlagPasswordIsPKEncrypted RedirectionFlag = 0x00004000
+
+	// RedirectionFlagRedirectionGUID LB_REDIRECTION_GUID
+	RedirectionFlagRedirectionGUID RedirectionFlag = 0x00008000
+
+	// RedirectionFlagTargetCertificate LB_TARGET_CERTIFICATE
+	RedirectionFlagTargetCertificate RedirectionFlag = 0x00010000
+)
+
+func (f RedirectionFlag) IsSet(flag RedirectionFlag) bool {
+	return f&flag == flag
+}
+
+// ServerRedirectionPacket Server Redirection Packet (RDP_SERVER_REDIRECTION_PACKET).
+type ServerRedirectionPacket struct {
+	SessionID          uint32
+	RedirFlags         RedirectionFlag
+	TargetNetAddress   string
+	LoadBalanceInfo    []byte // opaque routing token for the X.224 Connection Request
+	Username           string
+	Domain             string
+	Password           []byte // opaque password cookie for the Client Info PDU
+	TargetFQDN         string
+	TargetNetBiosName  string
+	TsvURL             string
+	RedirectionGUID    []byte
+	TargetCertificate  []byte
+	TargetNetAddresses []string
+}
+
+func (p *ServerRedirectionPacket) Deserialize(wire io.Reader) error {
+	var (
+		flags, length uint16
+		err           error
+	)
+
+	if err = binary.Read(wire, binary.LittleEndian, &flags); err != nil {
+		return err
+	}
+
+	if flags != 0x0400 { // SEC_REDIRECTION_PKT
+		return ErrInvalidRedirection
+	}
+
+	if err = binary.Read(wire, binary.LittleEndian, &length); err != nil {
+		return err
+	}
+
+	if length < 12 {
+		return ErrInvalidRedirection
+	}
+
+	data := make([]byte, length-4)
+	if _, err = io.ReadFull(wire, data); err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(data)
+
+	if err = binary.Read(r, binary.LittleEndian, &p.SessionID); err != nil {
+		return err
+	}
+
+	if err = binary.Read(r, binary.LittleEndian, &p.RedirFlags); err != nil {
+		return err
+	}
+
+	var field []byte
+
+	for _, f := range []struct {
+		flag  RedirectionFlag
+		parse func([]byte) error
+	}{
+		{RedirectionFlagTargetNetAddress, stringField(&p.TargetNetAddress)},
+		{RedirectionFlagLoadBalanceInfo, bytesField(&p.LoadBalanceInfo)},
+		{RedirectionFlagUsername, stringField(&p.Username)},
+		{RedirectionFlagDomain, stringField(&p.Domain)},
+		{RedirectionFlagPassword, bytesField(&p.Password)},
+		{RedirectionFlagTargetFQDN, stringField(&p.TargetFQDN)},
+		{RedirectionFlagTargetNetBiosName, stringField(&p.TargetNetBiosName)},
+		{RedirectionFlagClientTSVURL, stringField(&p.TsvURL)},
+		{RedirectionFlagRedirectionGUID, bytesField(&p.RedirectionGUID)},
+		{RedirectionFlagTargetCertificate, bytesField(&p.TargetCertificate)},
+		{RedirectionFlagTargetNetAddresses, p.deserializeTargetNetAddresses},
+	} {
+		if !p.RedirFlags.IsSet(f.flag) {
+			continue
+		}
+
+		if field, err = readLengthPrefixed(r); err != nil {
+			return err
+		}
+
+		if err = f.parse(field); err != nil {
+			return err
+		}
+	}
+
+	// the rest is padding
+	return nil
+}
+
+// deserializeTargetNetAddresses parses Target Net Addresses (TARGET_NET_ADDRESSES).
+func (p *ServerRedirectionPacket) deserializeTargetNetAddresses(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var addressCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &addressCount); err != nil {
+		return err
+	}
+
+	p.TargetNetAddresses = make([]string, 0, addressCount)
+
+	for i := uint32(0); i < addressCount; i++ {
+		address, err := readLengthPrefixed(r)
+		if err != nil {
+			return err
+		}
+
+		p.TargetNetAddresses = append(p.TargetNetAddresses, utf16.Decode(address))
+	}
+
+	return nil
+}
+
+// readLengthPrefixed reads a field preceded by its 32-bit length.
+func readLengthPrefixed(r *bytes.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+
+	if int64(length) > int64(r.Len()) {
+		return nil, ErrInvalidRedirection
+	}
+
+	field := make([]byte, length)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+func stringField(s *string) func([]byte) error {
+	return func(data []byte) error {
+		*s = utf16.Decode(data)
+
+		return nil
+	}
+}
+
+func bytesField(b *[]byte) func([]byte) error {
+	return func(data []byte) error {
+		*b = data
+
+		return nil
+	}
+}
+
+// EnhancedSecurityServerRedirection Enhanced Security Server Redirection PDU
+// following the Share Control Header with PDUTYPE_SERVER_REDIR_PKT.
+type EnhancedSecurityServerRedirection struct {
+	RedirectionPacket ServerRedirectionPacket
+}
+
+func (pdu *EnhancedSecurityServerRedirection) Deserialize(wire io.Reader) error {
+	var pad2Octets uint16
+	if err := binary.Read(wire, binary.LittleEndian, &pad2Octets); err != nil {
+		return err
+	}
+
+	return pdu.RedirectionPacket.Deserialize(wire)
+}
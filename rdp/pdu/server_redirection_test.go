@@ -0,0 +1,93 @@
+package pdu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+func TestServerRedirectionPacket_Deserialize(t *testing.T) {
+	field := func(buf *bytes.Buffer, data []byte) {
+		_ = binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	addresses := new(bytes.Buffer)
+	_ = binary.Write(addresses, binary.LittleEndian, uint32(2))
+	field(addresses, utf16.Encode("10.0.0.2\x00"))
+	field(addresses, utf16.Encode("fe80::2\x00"))
+
+	body := new(bytes.Buffer)
+	_ = binary.Write(body, binary.LittleEndian, uint32(7)) // sessionID
+	_ = binary.Write(body, binary.LittleEndian, RedirectionFlagTargetNetAddress|RedirectionFlagLoadBalanceInfo|
+		RedirectionFlagUsername|RedirectionFlagDomain|RedirectionFlagPassword|RedirectionFlagTargetFQDN|
+		RedirectionFlagClientTSVURL|RedirectionFlagTargetNetAddresses)
+	field(body, utf16.Encode("10.0.0.2\x00"))
+	field(body, []byte("Cookie: msts=3640205228.15629.0000\r\n"))
+	field(body, utf16.Encode("user\x00"))
+	field(body, utf16.Encode("DOMAIN\x00"))
+	field(body, []byte{0x01, 0x02, 0x03, 0x04})
+	field(body, utf16.Encode("host2.domain.local\x00"))
+	field(body, utf16.Encode("tsv://MS Terminal Services Plugin.1.Sessions\x00"))
+	field(body, addresses.Bytes())
+
+	input := new(bytes.Buffer)
+	_ = binary.Write(input, binary.LittleEndian, uint16(0x0000)) // pad2Octets
+	_ = binary.Write(input, binary.LittleEndian, uint16(0x0400)) // SEC_REDIRECTION_PKT
+	_ = binary.Write(input, binary.LittleEndian, uint16(4+body.Len()))
+	input.Write(body.Bytes())
+	input.Write(make([]byte, 8)) // pad
+
+	var actual EnhancedSecurityServerRedirection
+
+	require.NoError(t, actual.Deserialize(input))
+
+	packet := actual.RedirectionPacket
+
+	require.Equal(t, uint32(7), packet.SessionID)
+	require.Equal(t, "10.0.0.2", packet.TargetNetAddress)
+	require.Equal(t, []byte("Cookie: msts=3640205228.15629.0000\r\n"), packet.LoadBalanceInfo)
+	require.Equal(t, "user", packet.Username)
+	require.Equal(t, "DOMAIN", packet.Domain)
+	require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, packet.Password)
+	require.Equal(t, "host2.domain.local", packet.TargetFQDN)
+	require.Equal(t, "", packet.TargetNetBiosName)
+	require.Equal(t, "tsv://MS Terminal Services Plugin.1.Sessions", packet.TsvURL)
+	require.Equal(t, []string{"10.0.0.2", "fe80::2"}, packet.TargetNetAddresses)
+}
+
+func TestServerRedirectionPacket_DeserializeInvalid(t *testing.T) {
+	var actual ServerRedirectionPacket
+
+	require.ErrorIs(t, actual.Deserialize(bytes.NewBuffer([]byte{0x00, 0x00, 0x0c, 0x00})), ErrInvalidRedirection)
+
+	input := []byte{
+		0x00, 0x04, 0x10, 0x00, // flags, length
+		0x01, 0x00, 0x00, 0x00, // sessionID
+		0x02, 0x00, 0x00, 0x00, // LB_LOAD_BALANCE_INFO
+		0xff, 0x00, 0x00, 0x00, // length beyond the packet
+	}
+
+	require.ErrorIs(t, actual.Deserialize(bytes.NewBuffer(input)), ErrInvalidRedirection)
+}
+
+func TestData_DeserializeServerRedirection(t *testing.T) {
+	input := []byte{
+		0x18, 0x00, 0x0a, 0x00, 0xea, 0x03, // share control header PDUTYPE_SERVER_REDIR_PKT
+		0x00, 0x00, // pad2Octets
+		0x00, 0x04, 0x10, 0x00, // flags, length
+		0x01, 0x00, 0x00, 0x00, // sessionID
+		0x80, 0x00, 0x00, 0x00, // LB_NOREDIRECT
+		0x00, 0x00, 0x00, 0x00, // pad
+	}
+
+	var actual Data
+
+	require.NoError(t, actual.Deserialize(bytes.NewBuffer(input)))
+	require.NotNil(t, actual.ServerRedirection)
+	require.True(t, actual.ServerRedirection.RedirectionPacket.RedirFlags.IsSet(RedirectionFlagNoRedirect))
+}
@@ -0,0 +1,243 @@
+// Package planar decodes the RDP 6.0 Bitmap Compression (MS-RDPEGDI 2.2.2.5.1) of 32 bpp bitmaps.
+package planar
+
+import (
+	"errors"
+)
+
+var (
+	ErrChromaSubsamplingWithoutColorLoss = errors.New("chroma subsampling without color loss reduction")
+	ErrTruncated                         = errors.New("truncated bitmap stream")
+	ErrInvalidRunLength                  = errors.New("run beyond the scanline")
+	ErrDestinationTooSmall               = errors.New("destination too small")
+)
+
+// FormatHeader of the RDP6_BITMAP_STREAM.
+type FormatHeader uint8
+
+const (
+	// FormatHeaderColorLossLevel CLL, 0 for RGB planes, 1 to 7 for AYCoCg ones
+	FormatHeaderColorLossLevel FormatHeader = 0x07
+
+	// FormatHeaderChromaSubsampling CS
+	FormatHeaderChromaSubsampling FormatHeader = 0x08
+
+	// FormatHeaderRLE RLE
+	FormatHeaderRLE FormatHeader = 0x10
+
+	// FormatHeaderNoAlpha NA
+	FormatHeaderNoAlpha FormatHeader = 0x20
+)
+
+func (h FormatHeader) ColorLossLevel() int {
+	return int(h & FormatHeaderColorLossLevel)
+}
+
+func (h FormatHeader) IsSet(flag FormatHeader) bool {
+	return h&flag == flag
+}
+
+// plane of one color component.
+type plane struct {
+	width, height int
+	data          []byte
+}
+
+// Decompress decodes the bitmap stream of the width x height bitmap into dst, 4 bytes per pixel: blue, green,
+// red and alpha. The scanlines keep the order of the stream, bottom-up for the bitmap updates.
+func Decompress(src []byte, width, height int, dst []byte) error {
+	if len(dst) < width*height*4 {
+		return ErrDestinationTooSmall
+	}
+
+	if len(src) < 1 {
+		return ErrTruncated
+	}
+
+	header := FormatHeader(src[0])
+	src = src[1:]
+
+	cll := header.ColorLossLevel()
+	cs := header.IsSet(FormatHeaderChromaSubsampling)
+
+	if cs && cll == 0 {
+		return ErrChromaSubsamplingWithoutColorLoss
+	}
+
+	chromaWidth, chromaHeight := width, height
+	if cs {
+		chromaWidth, chromaHeight = (width+1)/2, (height+1)/2
+	}
+
+	planes := []*plane{
+		{width: width, height: height},             // LumaOrRedPlane
+		{width: chromaWidth, height: chromaHeight}, // OrangeChromaOrGreenPlane
+		{width: chromaWidth, height: chromaHeight}, // GreenChromaOrBluePlane
+	}
+
+	if !header.IsSet(FormatHeaderNoAlpha) {
+		planes = append([]*plane{{width: width, height: height}}, planes...) // AlphaPlane
+	}
+
+	for _, p := range planes {
+		var err error
+
+		if header.IsSet(FormatHeaderRLE) {
+			src, err = p.decompressRLE(src)
+		} else {
+			src, err = p.readRaw(src)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// the Pad byte of the raw planes is not needed
+
+	alpha := &plane{width: width, height: height}
+	if !header.IsSet(FormatHeaderNoAlpha) {
+		alpha, planes = planes[0], planes[1:]
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b byte
+
+			if cll == 0 {
+				r, g, b = planes[0].at(x, y), planes[1].at(x, y), planes[2].at(x, y)
+			} else {
+				cx, cy := x, y
+				if cs {
+					cx, cy = x/2, y/2
+				}
+
+				r, g, b = ycocgToRGB(planes[0].at(x, y), planes[1].at(cx, cy), planes[2].at(cx, cy), cll)
+			}
+
+			a := byte(0xFF)
+			if alpha.data != nil {
+				a = alpha.at(x, y)
+			}
+
+			i := (y*width + x) * 4
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = b, g, r, a
+		}
+	}
+
+	return nil
+}
+
+func (p *plane) at(x, y int) byte {
+	return p.data[y*p.width+x]
+}
+
+// readRaw reads the RDP6_RAW_PLANES plane.
+func (p *plane) readRaw(src []byte) ([]byte, error) {
+	size := p.width * p.height
+	if len(src) < size {
+		return nil, ErrTruncated
+	}
+
+	p.data = src[:size]
+
+	return src[size:], nil
+}
+
+// decompressRLE decodes the RDP6_RLE_SEGMENTS of the plane scanlines. The first scanline holds absolute values,
+// the next ones the deltas to the scanline above.
+func (p *plane) decompressRLE(src []byte) ([]byte, error) {
+	p.data = make([]byte, p.width*p.height)
+
+	for y := 0; y < p.height; y++ {
+		scanline := p.data[y*p.width : (y+1)*p.width]
+
+		var value byte // the last raw value is repeated by the run
+
+		for x := 0; x < p.width; {
+			if len(src) < 1 {
+				return nil, ErrTruncated
+			}
+
+			controlByte := src[0]
+			src = src[1:]
+
+			runLength, rawBytes := int(controlByte&0x0F), int(controlByte>>4)
+
+			switch runLength {
+			case 1:
+				runLength, rawBytes = rawBytes+16, 0
+			case 2:
+				runLength, rawBytes = rawBytes+32, 0
+			}
+
+			if x+rawBytes+runLength > p.width {
+				return nil, ErrInvalidRunLength
+			}
+
+			if len(src) < rawBytes {
+				return nil, ErrTruncated
+			}
+
+			for _, raw := range src[:rawBytes] {
+				value = raw
+
+				if y > 0 {
+					value = delta(raw)
+				}
+
+				scanline[x] = p.value(x, y, value)
+				x++
+			}
+
+			src = src[rawBytes:]
+
+			for ; runLength > 0; runLength-- {
+				scanline[x] = p.value(x, y, value)
+				x++
+			}
+		}
+	}
+
+	return src, nil
+}
+
+// value applies the delta to the value above, except on the first scanline.
+func (p *plane) value(x, y int, value byte) byte {
+	if y == 0 {
+		return value
+	}
+
+	return p.data[(y-1)*p.width+x] + value
+}
+
+// delta decodes the sign of the delta value kept in the least significant bit.
+func delta(raw byte) byte {
+	if raw&1 != 0 {
+		return -((raw >> 1) + 1)
+	}
+
+	return raw >> 1
+}
+
+// ycocgToRGB restores the color loss reduction of the chroma values and converts them back to RGB.
+func ycocgToRGB(y, co, cg byte, cll int) (byte, byte, byte) {
+	// shifting by cll-1 also halves Co and Cg of the conversion
+	coHalf := int(int8(co << (cll - 1)))
+	cgHalf := int(int8(cg << (cll - 1)))
+
+	t := int(y) - cgHalf
+
+	return clamp(t + coHalf), clamp(int(y) + cgHalf), clamp(t - coHalf)
+}
+
+func clamp(v int) byte {
+	switch {
+	case v < 0:
+		return 0
+	case v > 0xFF:
+		return 0xFF
+	}
+
+	return byte(v)
+}
@@ -0,0 +1,81 @@
+package planar
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompress(t *testing.T) {
+	// 5x3 bitmap with the chroma of 2x2 blocks in multiples of 4, lossless for CLL 3 and chroma subsampling
+	const expected = "343454053838582d6c7044558084587d565a5ea53535550f3e3e5e376d71455f82865a87575b5fafe0b8d019cca4bc417090a0697090a091849484b9"
+
+	for _, tc := range []struct {
+		name    string
+		input   string
+		noAlpha bool
+	}{
+		{"CLL=0", "00052d557da50f375f87af19416991b9545844585e555e455a5fd0bca0a084343870845a353e71865bb8a490909434386c8056353e6d8257e0cc70708400", false},
+		{"CLL=0 NA", "20545844585e555e455a5fd0bca0a084343870845a353e71865bb8a490909434386c8056353e6d8257e0cc70708400", true},
+		{"CLL=0 RLE", "1050052d557da51414141450545844585e50020c02040250f6bcb68c4a50343870845a50020c02040250f9cc3e14725034386c805650020c02040250a9e306235a", false},
+		{"CLL=0 RLE NA", "3050545844585e50020c02040250f6bcb68c4a50343870845a50020c02040250f9cc3e14725034386c805650020c02040250a9e306235a", true},
+		{"CLL=3", "03052d557da50f375f87af19416991b93c4064785a3d46657a5bc8b48c8c8c0404fbfb010404fbfb01fefe060600fefe030300fefe030300fcfc01010200", false},
+		{"CLL=3 NA", "233c4064785a3d46657a5bc8b48c8c8c0404fbfb010404fbfb01fefe060600fefe030300fefe030300fcfc01010200", true},
+		{"CLL=3 RLE", "1350052d557da514141414503c4064785a50020c02040250e9dc4e2462500404fbfb0105500b0b16160150fefe0303000513031004", false},
+		{"CLL=3 RLE NA", "33503c4064785a50020c02040250e9dc4e2462500404fbfb0105500b0b16160150fefe0303000513031004", true},
+		{"CLL=3 CS", "0b052d557da50f375f87af19416991b93c4064785a3d46657a5bc8b48c8c8c04fb01fe0600fe0300fc010200", false},
+		{"CLL=3 CS NA", "2b3c4064785a3d46657a5bc8b48c8c8c04fb01fe0600fe0300fc010200", true},
+		{"CLL=3 CS RLE", "1b50052d557da514141414503c4064785a50020c02040250e9dc4e24623004fb01300b160130fe030030030304", false},
+		{"CLL=3 CS RLE NA", "3b503c4064785a50020c02040250e9dc4e24623004fb01300b160130fe030030030304", true},
+	} {
+		input, err := hex.DecodeString(tc.input)
+		require.NoError(t, err)
+
+		output := make([]byte, 5*3*4)
+		require.NoError(t, Decompress(input, 5, 3, output), tc.name)
+
+		expectedOutput, err := hex.DecodeString(expected)
+		require.NoError(t, err)
+
+		if tc.noAlpha {
+			for i := 3; i < len(expectedOutput); i += 4 {
+				expectedOutput[i] = 0xFF
+			}
+		}
+
+		require.Equal(t, expectedOutput, output, tc.name)
+	}
+}
+
+func TestDecompress_LongRuns(t *testing.T) {
+	input := []byte{
+		0x30,                   // RLE, NA
+		0x1F, 0x10, 0x41, 0x42, // raw value and run of 15, run of 4+16, deltas of zero in run of 4+32
+		0x1F, 0x20, 0x41, 0x42,
+		0x1F, 0x30, 0x41, 0x42,
+	}
+
+	output := make([]byte, 36*2*4)
+	require.NoError(t, Decompress(input, 36, 2, output))
+	require.Equal(t, bytes.Repeat([]byte{0x30, 0x20, 0x10, 0xFF}, 36*2), output)
+}
+
+func TestDecompress_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		input  []byte
+		dstLen int
+		err    error
+	}{
+		{"chroma subsampling of RGB", []byte{0x28, 0x00, 0x00, 0x00}, 4, ErrChromaSubsamplingWithoutColorLoss},
+		{"empty", nil, 4, ErrTruncated},
+		{"truncated raw plane", []byte{0x20, 0x01, 0x02}, 4, ErrTruncated},
+		{"truncated segment", []byte{0x30, 0x10}, 4, ErrTruncated},
+		{"run beyond scanline", []byte{0x30, 0x02}, 4, ErrInvalidRunLength},
+		{"destination too small", []byte{0x20, 0x01, 0x02, 0x03, 0x00}, 3, ErrDestinationTooSmall},
+	} {
+		require.ErrorIs(t, Decompress(tc.input, 1, 1, make([]byte, tc.dstLen)), tc.err, tc.name)
+	}
+}
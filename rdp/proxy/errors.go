@@ -0,0 +1,14 @@
+package proxy
+
+import "errors"
+
+var (
+	ErrUnsupportedScheme     = errors.New("unsupported proxy scheme")
+	ErrAuthenticationFailed  = errors.New("proxy authentication failed")
+	ErrNoAcceptableMethod    = errors.New("no acceptable SOCKS5 authentication method")
+	ErrInvalidSOCKS5Reply    = errors.New("invalid SOCKS5 reply")
+	ErrConnectFailed         = errors.New("proxy failed to connect to the target")
+	ErrCredentialsTooLong    = errors.New("SOCKS5 username or password longer than 255 bytes")
+	ErrTargetHostnameTooLong = errors.New("target hostname longer than 255 bytes")
+	ErrInvalidProxyURL       = errors.New("invalid proxy URL")
+)
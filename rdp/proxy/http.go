@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// connectHTTPS runs HTTP CONNECT over TLS to the proxy.
+func (d *Dialer) connectHTTPS(conn net.Conn, address string) (net.Conn, error) {
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxyURL.Hostname()})
+
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("proxy TLS handshake: %w", err)
+	}
+
+	return d.connectHTTP(tlsConn, address)
+}
+
+// connectHTTP runs HTTP CONNECT, RFC 9110 9.3.6, with the Basic proxy authorization.
+func (d *Dialer) connectHTTP(conn net.Conn, address string) (net.Conn, error) {
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", address, address)
+
+	if username, password, ok := d.credentials(); ok {
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		fmt.Fprintf(buf, "Proxy-Authorization: Basic %s\r\n", credentials)
+	}
+
+	buf.WriteString("\r\n")
+
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, fmt.Errorf("CONNECT response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return nil, ErrAuthenticationFailed
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("%w: %s", ErrConnectFailed, resp.Status)
+	}
+
+	if r.Buffered() == 0 {
+		return conn, nil
+	}
+
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+// bufferedConn returns the data the server sent right after the CONNECT response first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
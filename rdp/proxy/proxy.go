@@ -0,0 +1,128 @@
+// Package proxy dials RDP servers through SOCKS5 and HTTP CONNECT proxies.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/lunnik9/rdp/rdp/dialctx"
+)
+
+// Forward opens the connections to the proxy server, *net.Dialer is a forward dialer.
+type Forward interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+const (
+	defaultTimeout = 5 * time.Second
+
+	// handshakeTimeout of the proxy negotiation
+	handshakeTimeout = 10 * time.Second
+)
+
+// Dialer opens connections through the proxy of the URL: socks5://, socks5h://, http:// or https://,
+// with the credentials of the URL user info. The target host of socks5:// is resolved locally,
+// socks5h:// leaves the resolution to the proxy.
+type Dialer struct {
+	proxyURL *url.URL
+	forward  Forward
+}
+
+// NewDialer returns the dialer through proxyURL, which dials directly when proxyURL is nil.
+// The proxy server is dialed by forward, TCP with a timeout by default.
+func NewDialer(proxyURL *url.URL, forward Forward) *Dialer {
+	if forward == nil {
+		forward = &net.Dialer{Timeout: defaultTimeout}
+	}
+
+	return &Dialer{
+		proxyURL: proxyURL,
+		forward:  forward,
+	}
+}
+
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext dials through the proxy, the cancellation of ctx interrupts the dial and the proxy handshake.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.proxyURL == nil {
+		return dialctx.Dial(ctx, d.forward, network, address)
+	}
+
+	var (
+		connect     func(conn net.Conn, address string) (net.Conn, error)
+		defaultPort string
+	)
+
+	switch d.proxyURL.Scheme {
+	case "socks5":
+		resolved, err := resolve(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("target: %w", err)
+		}
+
+		address, connect, defaultPort = resolved, d.connectSOCKS5, "1080"
+	case "socks5h":
+		connect, defaultPort = d.connectSOCKS5, "1080"
+	case "http":
+		connect, defaultPort = d.connectHTTP, "80"
+	case "https":
+		connect, defaultPort = d.connectHTTPS, "443"
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedScheme, d.proxyURL.Scheme)
+	}
+
+	proxyAddress := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		proxyAddress = net.JoinHostPort(d.proxyURL.Hostname(), defaultPort)
+	}
+
+	conn, err := dialctx.Dial(ctx, d.forward, network, proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("proxy connect: %w", err)
+	}
+
+	if err = conn.SetDeadline(dialctx.Deadline(ctx, handshakeTimeout)); err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	stop := dialctx.InterruptOnDone(ctx, conn)
+	tunnel, err := connect(conn, address)
+	stop()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	return tunnel, nil
+}
+
+// credentials returns the user info of the proxy URL.
+func (d *Dialer) credentials() (username, password string, ok bool) {
+	if d.proxyURL.User == nil {
+		return "", "", false
+	}
+
+	password, _ = d.proxyURL.User.Password()
+
+	return d.proxyURL.User.Username(), password, true
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Rules pick the proxy of the target, as ALL_PROXY and NO_PROXY do.
+type Rules struct {
+	Proxy   *url.URL
+	NoProxy []string // hosts with subdomains, .domain suffixes, IPs and CIDRs with optional ports, * for all
+}
+
+// FromEnvironment returns the rules of ALL_PROXY and NO_PROXY, the lowercase variables are honored as well.
+func FromEnvironment() (*Rules, error) {
+	rules := Rules{
+		NoProxy: strings.Split(getenv("NO_PROXY", "no_proxy"), ","),
+	}
+
+	if allProxy := getenv("ALL_PROXY", "all_proxy"); allProxy != "" {
+		proxyURL, err := ParseURL(allProxy)
+		if err != nil {
+			return nil, fmt.Errorf("ALL_PROXY: %w", err)
+		}
+
+		rules.Proxy = proxyURL
+	}
+
+	return &rules, nil
+}
+
+func getenv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// ParseURL parses the proxy URL, http is the default scheme as in curl.
+func ParseURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProxyURL, err)
+	}
+
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("%w: no host", ErrInvalidProxyURL)
+	}
+
+	return proxyURL, nil
+}
+
+// ProxyFor returns the proxy of the target address or nil when it is dialed directly.
+func (r *Rules) ProxyFor(address string) *url.URL {
+	if r.Proxy == nil || r.bypass(address) {
+		return nil
+	}
+
+	return r.Proxy
+}
+
+// Dialer returns the dialer of the target address.
+func (r *Rules) Dialer(address string, forward Forward) *Dialer {
+	return NewDialer(r.ProxyFor(address), forward)
+}
+
+// bypass reports whether the address matches NO_PROXY.
+func (r *Rules) bypass(address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, ""
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip := net.ParseIP(host)
+
+	for _, entry := range r.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		}
+
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+
+			continue
+		}
+
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = entry, ""
+		}
+
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		entryHost = strings.TrimSuffix(strings.Trim(entryHost, "[]"), ".")
+
+		switch {
+		case ip != nil:
+			if entryIP := net.ParseIP(entryHost); entryIP != nil && entryIP.Equal(ip) {
+				return true
+			}
+		case strings.HasPrefix(entryHost, "."): // subdomains only
+			if strings.HasSuffix(host, entryHost) {
+				return true
+			}
+		case host == entryHost || strings.HasSuffix(host, "."+entryHost):
+			return true
+		}
+	}
+
+	return false
+}
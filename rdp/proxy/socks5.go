@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5MethodNoAuth       = 0x00
+	socks5MethodUserPass     = 0x02
+	socks5MethodNoAcceptable = 0xFF
+
+	socks5UserPassVersion = 0x01
+
+	socks5CommandConnect = 0x01
+
+	socks5AddressIPv4   = 0x01
+	socks5AddressDomain = 0x03
+	socks5AddressIPv6   = 0x04
+)
+
+var socks5ReplyMap = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// connectSOCKS5 runs the SOCKS5 CONNECT, RFC 1928, with the username/password authentication of RFC 1929.
+// The target is sent as the IP address or the domain name of address.
+func (d *Dialer) connectSOCKS5(conn net.Conn, address string) (net.Conn, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("target port: %w", err)
+	}
+
+	username, password, withCredentials := d.credentials()
+
+	methods := []byte{socks5MethodNoAuth}
+	if withCredentials {
+		methods = append(methods, socks5MethodUserPass)
+	}
+
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err = conn.Write(greeting); err != nil {
+		return nil, err
+	}
+
+	choice := make([]byte, 2)
+	if _, err = io.ReadFull(conn, choice); err != nil {
+		return nil, err
+	}
+
+	if choice[0] != socks5Version {
+		return nil, ErrInvalidSOCKS5Reply
+	}
+
+	switch choice[1] {
+	case socks5MethodNoAuth: // pass
+	case socks5MethodUserPass:
+		if err = socks5Authenticate(conn, username, password); err != nil {
+			return nil, err
+		}
+	case socks5MethodNoAcceptable:
+		return nil, ErrNoAcceptableMethod
+	default:
+		return nil, ErrInvalidSOCKS5Reply
+	}
+
+	request := []byte{socks5Version, socks5CommandConnect, 0x00}
+
+	switch ip := net.ParseIP(host); {
+	case ip == nil:
+		if len(host) > 255 {
+			return nil, ErrTargetHostnameTooLong
+		}
+
+		request = append(request, socks5AddressDomain, byte(len(host)))
+		request = append(request, host...)
+	case ip.To4() != nil:
+		request = append(request, socks5AddressIPv4)
+		request = append(request, ip.To4()...)
+	default:
+		request = append(request, socks5AddressIPv6)
+		request = append(request, ip.To16()...)
+	}
+
+	request = append(request, byte(port>>8), byte(port))
+
+	if _, err = conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	return conn, socks5ReadReply(conn)
+}
+
+// resolve replaces the host of address with its IP address, IPv4 preferred.
+func resolve(ctx context.Context, address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+
+	if net.ParseIP(host) != nil {
+		return address, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	ip := addrs[0].IP // not empty without an error
+
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			ip = addr.IP
+
+			break
+		}
+	}
+
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+// socks5Authenticate runs the username/password sub-negotiation, RFC 1929.
+func socks5Authenticate(conn net.Conn, username, password string) error {
+	if len(username) > 255 || len(password) > 255 {
+		return ErrCredentialsTooLong
+	}
+
+	request := []byte{socks5UserPassVersion, byte(len(username))}
+	request = append(request, username...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	status := make([]byte, 2)
+	if _, err := io.ReadFull(conn, status); err != nil {
+		return err
+	}
+
+	if status[1] != 0x00 {
+		return ErrAuthenticationFailed
+	}
+
+	return nil
+}
+
+// socks5ReadReply reads the reply to CONNECT, the bound address is skipped.
+func socks5ReadReply(conn net.Conn) error {
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	if reply[0] != socks5Version {
+		return ErrInvalidSOCKS5Reply
+	}
+
+	if reply[1] != 0x00 {
+		if name, ok := socks5ReplyMap[reply[1]]; ok {
+			return fmt.Errorf("%w: %s", ErrConnectFailed, name)
+		}
+
+		return fmt.Errorf("%w: SOCKS5 reply 0x%02x", ErrConnectFailed, reply[1])
+	}
+
+	var addressLen int
+
+	switch reply[3] {
+	case socks5AddressIPv4:
+		addressLen = net.IPv4len
+	case socks5AddressIPv6:
+		addressLen = net.IPv6len
+	case socks5AddressDomain:
+		var domainLen uint8
+		if err := binary.Read(conn, binary.BigEndian, &domainLen); err != nil {
+			return err
+		}
+
+		addressLen = int(domainLen)
+	default:
+		return ErrInvalidSOCKS5Reply
+	}
+
+	_, err := io.ReadFull(conn, make([]byte, addressLen+2)) // BND.ADDR and BND.PORT
+
+	return err
+}
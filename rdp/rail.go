@@ -20,7 +20,7 @@ const (
 	RailStateExecuteApp
 )
 
-func (c *client) handleRail(wire io.Reader) error {
+func (c *Client) handleRail(wire io.Reader) error {
 	if c.remoteApp == nil {
 		return nil
 	}
@@ -319,19 +319,19 @@ func (pdu *RailPDUClientSystemParamUpdate) Serialize() []byte {
 	return buf.Bytes()
 }
 
-func (c *client) railHandshake(*RailPDU) error {
+func (c *Client) railHandshake(*RailPDU) error {
 	var (
 		err error
 	)
 
 	clientHandshake := NewRailHandshakePDU()
-	err = c.mcsLayer.Send(c.userID, c.channelIDMap["rail"], clientHandshake.Serialize())
+	err = c.secLayer.Send(c.userID, c.channelIDMap["rail"], clientHandshake.Serialize())
 	if err != nil {
 		return err
 	}
 
 	clientInfo := NewRailClientInfoPDU()
-	err = c.mcsLayer.Send(c.userID, c.channelIDMap["rail"], clientInfo.Serialize())
+	err = c.secLayer.Send(c.userID, c.channelIDMap["rail"], clientInfo.Serialize())
 	if err != nil {
 		return err
 	}
@@ -408,12 +408,12 @@ func (pdu *RailPDUClientExecute) Serialize() []byte {
 	return buf.Bytes()
 }
 
-func (c *client) railStartRemoteApp() error {
+func (c *Client) railStartRemoteApp() error {
 	c.railState = RailStateExecuteApp
 
 	clientExecute := NewRailClientExecutePDU(c.remoteApp.App, c.remoteApp.WorkingDir, c.remoteApp.Args)
 
-	return c.mcsLayer.Send(c.userID, c.channelIDMap["rail"], clientExecute.Serialize())
+	return c.secLayer.Send(c.userID, c.channelIDMap["rail"], clientExecute.Serialize())
 }
 
 type RailPDUExecResult struct {
@@ -463,7 +463,7 @@ func (pdu *RailPDUExecResult) Deserialize(wire io.Reader) error {
 	return nil
 }
 
-func (c *client) railReceiveRemoteAppStatus(*RailPDU) error {
+func (c *Client) railReceiveRemoteAppStatus(*RailPDU) error {
 	c.railState = RailStateWaitForData
 
 	// TODO: implement
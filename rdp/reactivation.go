@@ -0,0 +1,41 @@
+package rdp
+
+import (
+	"fmt"
+	"log"
+)
+
+// reactivate runs the deactivation-reactivation sequence, MS-RDPBCGR 1.3.1.3: the capabilities
+// are exchanged over the new share and the connection is finalized again, the desktop may be resized.
+func (c *Client) reactivate() error {
+	log.Println("RDP: Deactivate All")
+
+	if err := c.capabilitiesExchange(); err != nil {
+		return fmt.Errorf("capabilities exchange: %w", err)
+	}
+
+	if err := c.connectionFinalization(); err != nil {
+		return fmt.Errorf("connection finalization: %w", err)
+	}
+
+	log.Printf("RDP: reactivated: shareID = %d, desktop = %dx%d\n", c.shareID, c.desktopWidth, c.desktopHeight)
+
+	if c.desktopSizeHandler != nil {
+		c.desktopSizeHandler(c.desktopWidth, c.desktopHeight)
+	}
+
+	return nil
+}
+
+// DesktopSize returns the size of the desktop chosen by the server.
+func (c *Client) DesktopSize() (width, height uint16) {
+	return c.desktopWidth, c.desktopHeight
+}
+
+// WithDesktopSizeHandler sets the handler called from GetUpdate when the server reactivates
+// the session with the new desktop size.
+func WithDesktopSizeHandler(handler func(width, height uint16)) Option {
+	return func(c *Client) {
+		c.desktopSizeHandler = handler
+	}
+}
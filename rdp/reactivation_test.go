@@ -0,0 +1,82 @@
+package rdp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/fastpath"
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+func TestClient_GetUpdateReactivation(t *testing.T) {
+	// Server Demand Active with shareID = 0x000103ea and the desktop of 1024x768
+	demandActive, err := hex.DecodeString("030001d802f08068000103eb7081c9c9011100ea03ea0301000400b301524450001100000009000800ea03000001001800010003000002000000001d04000000000000010114000c0002000000400600000a0008000600000008000a000100190019001b00060001000e0008000100000002001c00100001000100010000040003000001000100001e010000001d00600004b91b8dca0f004f15589fae2d1a87e2d6000300010103122f777672bd6344afb3b73c9c6f788600040000000000a651439c3535ae42910ccdfce5760b5800040000000000d4cc44278a9d744e803c0ecbeea19c5400040000000000030058000000000000000000000000000000000040420f0001001400000001000000aa000101010101000000010000010000000101010101010101000101010100000000a106060040420f0040420f00010000000000000012000800010000000d00580075030000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000017000800ff00000018000b0002000000030c001a000800a79400001c000c0052000000000000001e0008000000000000000000")
+	require.NoError(t, err)
+
+	const shareID = 0x000103ea
+
+	deactivateAll := new(bytes.Buffer)
+	_ = binary.Write(deactivateAll, binary.LittleEndian, uint16(13))     // totalLength
+	_ = binary.Write(deactivateAll, binary.LittleEndian, uint16(0x0016)) // PDUTYPE_DEACTIVATEALLPDU
+	_ = binary.Write(deactivateAll, binary.LittleEndian, uint16(1002))   // pduSource
+	_ = binary.Write(deactivateAll, binary.LittleEndian, uint32(0x000103e9))
+	_ = binary.Write(deactivateAll, binary.LittleEndian, uint16(1)) // lengthSourceDescriptor
+	deactivateAll.WriteByte(0)
+
+	fontMap := pdu.NewFontList(shareID, 1002).Serialize()
+	fontMap[14] = byte(pdu.Type2Fontmap) // the same layout as the Font List
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		_, _ = io.Copy(io.Discard, serverConn) // Confirm Active and finalization PDUs
+	}()
+
+	go func() {
+		for _, frame := range [][]byte{
+			serverFrame(deactivateAll.Bytes()),
+			demandActive,
+			serverFrame(pdu.NewSynchronize(shareID, 1002).Serialize()),
+			serverFrame(pdu.NewControl(shareID, 1002, pdu.ControlActionCooperate).Serialize()),
+			serverFrame(pdu.NewControl(shareID, 1002, pdu.ControlActionGrantedControl).Serialize()),
+			serverFrame(fontMap),
+			{0x00, 0x05, byte(fastpath.UpdateCodeSynchronize), 0x00, 0x00},
+		} {
+			if _, err := serverConn.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := Client{
+		desktopWidth:  800,
+		desktopHeight: 600,
+		channelIDMap:  map[string]uint16{"global": 1003},
+		userID:        1007,
+	}
+	c.setConn(clientConn)
+
+	require.NoError(t, clientConn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	var width, height uint16
+
+	WithDesktopSizeHandler(func(w, h uint16) {
+		width, height = w, h
+	})(&c)
+
+	update, err := c.GetUpdate()
+	require.NoError(t, err)
+	require.NotNil(t, update)
+
+	require.Equal(t, uint32(shareID), c.shareID)
+	require.Equal(t, uint16(1024), width)
+	require.Equal(t, uint16(768), height)
+}
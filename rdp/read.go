@@ -1,5 +1,5 @@
 package rdp
 
-func (c *client) Read(b []byte) (int, error) {
+func (c *Client) Read(b []byte) (int, error) {
 	return c.buffReader.Read(b)
 }
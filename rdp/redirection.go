@@ -0,0 +1,100 @@
+package rdp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+const (
+	maxRedirections = 5
+	defaultRDPPort  = "3389"
+)
+
+// errRedirected stops the connection sequence or the session, so Connect or GetUpdate reconnects to the redirection target.
+var errRedirected = errors.New("redirected")
+
+// handleServerRedirection remembers the Server Redirection Packet for Connect or GetUpdate to follow,
+// informational packets with LB_NOREDIRECT are ignored.
+func (c *Client) handleServerRedirection(packet *pdu.ServerRedirectionPacket) error {
+	log.Printf("RDP: Server Redirection: sessionID = %d, redirFlags = 0x%08x\n", packet.SessionID, uint32(packet.RedirFlags))
+
+	if packet.RedirFlags.IsSet(pdu.RedirectionFlagNoRedirect) {
+		return nil
+	}
+
+	c.redirection = packet
+
+	return errRedirected
+}
+
+// redirect closes the connection and switches to the redirection target, which gets the load balance info
+// as the routing token and the redirected credentials.
+func (c *Client) redirect() error {
+	packet := c.redirection
+	c.redirection = nil
+
+	_ = c.conn.Close()
+
+	if target := redirectionTarget(packet); target != "" {
+		_, port, err := net.SplitHostPort(c.hostname)
+		if err != nil {
+			port = defaultRDPPort
+		}
+
+		c.hostname = net.JoinHostPort(target, port)
+	}
+
+	c.routingToken = packet.LoadBalanceInfo
+	c.settings.ConsoleSession, c.settings.SessionID = false, packet.SessionID // the target reconnects to the session
+
+	if packet.RedirFlags.IsSet(pdu.RedirectionFlagUsername) {
+		c.username = packet.Username
+	}
+
+	if packet.RedirFlags.IsSet(pdu.RedirectionFlagDomain) {
+		c.domain = packet.Domain
+	}
+
+	if packet.RedirFlags.IsSet(pdu.RedirectionFlagPassword) {
+		c.passwordCookie = packet.Password
+	}
+
+	c.resetSession()
+
+	log.Println("RDP: redirecting to " + c.hostname)
+
+	return nil
+}
+
+// followRedirection connects to the redirection target of the Server Redirection PDU received
+// after the connection finalization, the inputs are dropped meanwhile.
+func (c *Client) followRedirection() error {
+	c.setReconnecting(true)
+	defer c.setReconnecting(false)
+
+	if err := c.redirect(); err != nil {
+		return err
+	}
+
+	return c.Connect(context.Background())
+}
+
+// redirectionTarget returns the address to reconnect to or empty string to reconnect to the same server.
+func redirectionTarget(packet *pdu.ServerRedirectionPacket) string {
+	switch {
+	case packet.RedirFlags.IsSet(pdu.RedirectionFlagTargetNetAddress) && packet.TargetNetAddress != "":
+		return packet.TargetNetAddress
+	case packet.RedirFlags.IsSet(pdu.RedirectionFlagTargetNetAddresses) && len(packet.TargetNetAddresses) != 0:
+		return packet.TargetNetAddresses[0]
+	case packet.RedirFlags.IsSet(pdu.RedirectionFlagTargetFQDN) && packet.TargetFQDN != "":
+		return packet.TargetFQDN
+	case packet.RedirFlags.IsSet(pdu.RedirectionFlagTargetNetBiosName) && packet.TargetNetBiosName != "":
+		return packet.TargetNetBiosName
+	}
+
+	return ""
+}
@@ -0,0 +1,215 @@
+package rdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+	"github.com/lunnik9/rdp/rdp/utf16"
+)
+
+func TestClient_redirect(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer target.Close()
+
+	_, port, err := net.SplitHostPort(target.Addr().String())
+	require.NoError(t, err)
+
+	broker, _ := net.Pipe()
+
+	c := Client{
+		hostname: net.JoinHostPort("broker.invalid", port),
+		conn:     broker,
+		username: "user",
+		password: "P@ssw0rd",
+		cookie:   "user",
+		shareID:  0x103ea,
+		settings: pdu.ClientSettings{ConsoleSession: true},
+	}
+
+	err = c.handleServerRedirection(&pdu.ServerRedirectionPacket{
+		SessionID: 7,
+		RedirFlags: pdu.RedirectionFlagTargetNetAddress | pdu.RedirectionFlagLoadBalanceInfo |
+			pdu.RedirectionFlagDomain | pdu.RedirectionFlagPassword,
+		TargetNetAddress: "127.0.0.1",
+		LoadBalanceInfo:  []byte("Cookie: msts=3640205228.15629.0000\r\n"),
+		Domain:           "DOMAIN",
+		Password:         []byte{0x01, 0x02, 0x03, 0x04},
+	})
+	require.ErrorIs(t, err, errRedirected)
+
+	require.NoError(t, c.redirect())
+
+	c.dialer = &net.Dialer{}
+	require.NoError(t, c.dial(context.Background()))
+
+	defer c.Close()
+
+	require.Equal(t, target.Addr().String(), c.hostname)
+	require.Equal(t, []byte("Cookie: msts=3640205228.15629.0000\r\n"), c.routingToken)
+	require.Equal(t, "DOMAIN", c.domain)
+	require.Equal(t, "user", c.username)
+	require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, c.passwordCookie)
+	require.Zero(t, c.shareID)
+	require.Nil(t, c.redirection)
+	require.Equal(t, pdu.ClientSettings{SessionID: 7}, c.settings)
+}
+
+// redirectionTargetDialer records the address and the X.224 Connection Request of the redirection target,
+// which closes the connection then.
+type redirectionTargetDialer struct {
+	address string
+	request chan []byte
+}
+
+func (d *redirectionTargetDialer) Dial(_, address string) (net.Conn, error) {
+	d.address = address
+
+	clientConn, serverConn := net.Pipe()
+
+	go func() {
+		defer serverConn.Close()
+
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(serverConn, header); err != nil {
+			return
+		}
+
+		request := make([]byte, binary.BigEndian.Uint16(header[2:])-4)
+		if _, err := io.ReadFull(serverConn, request); err != nil {
+			return
+		}
+
+		d.request <- request
+	}()
+
+	return clientConn, nil
+}
+
+func TestClient_GetUpdateRedirection(t *testing.T) {
+	field := func(buf *bytes.Buffer, data []byte) {
+		_ = binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	body := new(bytes.Buffer)
+	_ = binary.Write(body, binary.LittleEndian, uint32(7)) // sessionID
+	_ = binary.Write(body, binary.LittleEndian, pdu.RedirectionFlagTargetNetAddress|pdu.RedirectionFlagLoadBalanceInfo)
+	field(body, utf16.Encode("127.0.0.1\x00"))
+	field(body, []byte("Cookie: msts=3640205228.15629.0000\r\n"))
+
+	redirection := new(bytes.Buffer)
+	_ = binary.Write(redirection, binary.LittleEndian, uint16(14+body.Len())) // totalLength
+	_ = binary.Write(redirection, binary.LittleEndian, uint16(pdu.TypeServerRedirect))
+	_ = binary.Write(redirection, binary.LittleEndian, uint16(1002))   // pduSource
+	_ = binary.Write(redirection, binary.LittleEndian, uint16(0x0000)) // pad2Octets
+	_ = binary.Write(redirection, binary.LittleEndian, uint16(0x0400)) // SEC_REDIRECTION_PKT
+	_ = binary.Write(redirection, binary.LittleEndian, uint16(4+body.Len()))
+	redirection.Write(body.Bytes())
+
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	go func() {
+		_, _ = serverConn.Write(serverFrame(redirection.Bytes()))
+	}()
+
+	dialer := &redirectionTargetDialer{request: make(chan []byte, 1)}
+
+	c := Client{
+		hostname:          "broker.invalid:3390",
+		dialer:            dialer,
+		username:          "user",
+		cookie:            "user",
+		securityProtocols: []pdu.NegotiationProtocol{pdu.NegotiationProtocolRDP},
+		channelIDMap:      map[string]uint16{"global": 1003},
+		shareID:           0x103ea,
+		userID:            1007,
+	}
+	c.setConn(clientConn)
+
+	require.NoError(t, clientConn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	_, err := c.GetUpdate()
+	require.ErrorContains(t, err, "server redirection: connection initiation") // the target closes the connection
+
+	require.Equal(t, "127.0.0.1:3390", dialer.address)
+	require.Contains(t, string(<-dialer.request), "Cookie: msts=3640205228.15629.0000\r\n")
+	require.Equal(t, uint32(7), c.settings.SessionID)
+	require.Zero(t, c.shareID)
+}
+
+func TestClient_handleServerRedirectionNoRedirect(t *testing.T) {
+	var c Client
+
+	require.NoError(t, c.handleServerRedirection(&pdu.ServerRedirectionPacket{
+		RedirFlags:       pdu.RedirectionFlagNoRedirect | pdu.RedirectionFlagTargetNetAddress,
+		TargetNetAddress: "10.0.0.2",
+	}))
+	require.Nil(t, c.redirection)
+}
+
+func Test_redirectionTarget(t *testing.T) {
+	testCases := []struct {
+		name     string
+		packet   pdu.ServerRedirectionPacket
+		expected string
+	}{
+		{
+			name: "load balance info only",
+			packet: pdu.ServerRedirectionPacket{
+				RedirFlags: pdu.RedirectionFlagLoadBalanceInfo,
+			},
+		},
+		{
+			name: "net address",
+			packet: pdu.ServerRedirectionPacket{
+				RedirFlags:       pdu.RedirectionFlagTargetNetAddress | pdu.RedirectionFlagTargetFQDN,
+				TargetNetAddress: "10.0.0.2",
+				TargetFQDN:       "host2.domain.local",
+			},
+			expected: "10.0.0.2",
+		},
+		{
+			name: "net addresses",
+			packet: pdu.ServerRedirectionPacket{
+				RedirFlags:         pdu.RedirectionFlagTargetNetAddresses | pdu.RedirectionFlagTargetFQDN,
+				TargetNetAddresses: []string{"fe80::2", "10.0.0.2"},
+				TargetFQDN:         "host2.domain.local",
+			},
+			expected: "fe80::2",
+		},
+		{
+			name: "fqdn",
+			packet: pdu.ServerRedirectionPacket{
+				RedirFlags:        pdu.RedirectionFlagTargetFQDN | pdu.RedirectionFlagTargetNetBiosName,
+				TargetFQDN:        "host2.domain.local",
+				TargetNetBiosName: "HOST2",
+			},
+			expected: "host2.domain.local",
+		},
+		{
+			name: "netbios name",
+			packet: pdu.ServerRedirectionPacket{
+				RedirFlags:        pdu.RedirectionFlagTargetNetBiosName,
+				TargetNetBiosName: "HOST2",
+			},
+			expected: "HOST2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, redirectionTarget(&tc.packet))
+		})
+	}
+}
@@ -1,11 +1,13 @@
 package rdp
 
-func (c *client) SetRemoteApp(app, args, workingDir string) {
-	c.remoteApp = &RemoteApp{
-		App:        app,
-		WorkingDir: workingDir,
-		Args:       args,
+func WithRemoteApp(app, args, workingDir string) Option {
+	return func(c *Client) {
+		c.remoteApp = &RemoteApp{
+			App:        app,
+			WorkingDir: workingDir,
+			Args:       args,
+		}
+		c.channels = append(c.channels, "rail")
+		c.railState = RailStateUninitialized
 	}
-	c.channels = append(c.channels, "rail")
-	c.railState = RailStateUninitialized
 }
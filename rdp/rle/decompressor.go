@@ -0,0 +1,357 @@
+package rle
+
+const (
+	maskRegularRunLength = 0x1F
+	maskLiteRunLength    = 0x0F
+
+	maskSpecialForegroundBackground1 = 0x03
+	maskSpecialForegroundBackground2 = 0x05
+)
+
+type decompressor struct {
+	// source bytes of compressed picture
+	pbSrcBuffer []byte
+
+	// destination bytes of decompressed rgb picture
+	pbDestBuffer []byte
+
+	// rowDelta one pixels row (width * bytes per pixel)
+	rowDelta int
+
+	// pixelSize bytes per pixel of the color depth
+	pixelSize int
+
+	// white pixel of the color depth, black is always zero
+	white Pixel
+
+	src  int
+	dest int
+}
+
+func newDecompressor(colorDepth int, pbSrcBuffer []byte, pbDestBuffer []byte, rowDelta int) *decompressor {
+	d := decompressor{
+		pbSrcBuffer:  pbSrcBuffer,
+		pbDestBuffer: pbDestBuffer,
+		rowDelta:     rowDelta,
+	}
+
+	switch colorDepth {
+	case 8:
+		d.pixelSize, d.white = 1, 0xFF // palette entry #255
+	case 15:
+		d.pixelSize, d.white = 2, 0x7FFF
+	case 16:
+		d.pixelSize, d.white = 2, WhitePixel
+	case 24:
+		d.pixelSize, d.white = 3, 0xFFFFFF
+	}
+
+	return &d
+}
+
+// Decompress follows RleDecompress of MS-RDPBCGR 3.1.9, it returns false on unknown order codes,
+// a truncated source or a destination too small for the picture.
+func (d *decompressor) Decompress() bool {
+	if d.pixelSize == 0 || d.rowDelta <= 0 {
+		return false
+	}
+
+	fgPel := d.white
+	insertFgPel := false
+	firstLine := true
+
+	for d.src < len(d.pbSrcBuffer) {
+		// watch out for the end of the first scanline
+		if firstLine && d.dest >= d.rowDelta {
+			firstLine = false
+			insertFgPel = false
+		}
+
+		code := extractCodeID(d.pbSrcBuffer[d.src])
+
+		runLength, ok := d.readRunLength(code)
+		if !ok {
+			return false
+		}
+
+		// a follow-on background run order needs a foreground pel inserted
+		if code == RegularBackgroundRun || code == MegaMegaBackgroundRun {
+			if insertFgPel && runLength > 0 {
+				if !d.writeForeground(firstLine, fgPel) {
+					return false
+				}
+
+				runLength--
+			}
+
+			for ; runLength > 0; runLength-- {
+				if !d.writeForeground(firstLine, 0) {
+					return false
+				}
+			}
+
+			insertFgPel = true
+
+			continue
+		}
+
+		insertFgPel = false
+
+		switch code {
+		case RegularForegroundRun, MegaMegaForegroundRun, LiteSetForegroundRun, MegaMegaSetForegroundRun:
+			if code == LiteSetForegroundRun || code == MegaMegaSetForegroundRun {
+				if fgPel, ok = d.readPixel(); !ok {
+					return false
+				}
+			}
+
+			for ; runLength > 0; runLength-- {
+				if !d.writeForeground(firstLine, fgPel) {
+					return false
+				}
+			}
+		case LiteDitheredRun, MegaMegaDitheredRun:
+			pixelA, okA := d.readPixel()
+			pixelB, okB := d.readPixel()
+
+			if !okA || !okB {
+				return false
+			}
+
+			for ; runLength > 0; runLength-- {
+				if !d.writePixel(pixelA) || !d.writePixel(pixelB) {
+					return false
+				}
+			}
+		case RegularColorRun, MegaMegaColorRun:
+			pixel, ok := d.readPixel()
+			if !ok {
+				return false
+			}
+
+			for ; runLength > 0; runLength-- {
+				if !d.writePixel(pixel) {
+					return false
+				}
+			}
+		case RegularForegroundBackgroundImageRun, MegaMegaForegroundBackgroundImageRun,
+			LiteSetForegroundForegroundBackgroundImageRun, MegaMegaSetForegroundBackgroundImage:
+			if code == LiteSetForegroundForegroundBackgroundImageRun || code == MegaMegaSetForegroundBackgroundImage {
+				if fgPel, ok = d.readPixel(); !ok {
+					return false
+				}
+			}
+
+			for runLength > 0 {
+				if d.src >= len(d.pbSrcBuffer) {
+					return false
+				}
+
+				bitmask := d.pbSrcBuffer[d.src]
+				d.src++
+
+				cBits := 8
+				if runLength < cBits {
+					cBits = runLength
+				}
+
+				if !d.writeForegroundBackgroundImage(firstLine, bitmask, fgPel, cBits) {
+					return false
+				}
+
+				runLength -= cBits
+			}
+		case RegularColorImageRun, MegaMegaColorImage:
+			byteCount := runLength * d.pixelSize
+
+			if d.src+byteCount > len(d.pbSrcBuffer) || d.dest+byteCount > len(d.pbDestBuffer) {
+				return false
+			}
+
+			copy(d.pbDestBuffer[d.dest:], d.pbSrcBuffer[d.src:d.src+byteCount])
+
+			d.src += byteCount
+			d.dest += byteCount
+		case SpecialForegroundBackground1Run:
+			if !d.writeForegroundBackgroundImage(firstLine, maskSpecialForegroundBackground1, fgPel, 8) {
+				return false
+			}
+		case SpecialForegroundBackground2Run:
+			if !d.writeForegroundBackgroundImage(firstLine, maskSpecialForegroundBackground2, fgPel, 8) {
+				return false
+			}
+		case WhiteRun:
+			if !d.writePixel(d.white) {
+				return false
+			}
+		case BlackRun:
+			if !d.writePixel(0) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// extractCodeID returns the compression order code of the order header.
+func extractCodeID(orderHdr byte) Code {
+	switch {
+	case orderHdr&0xC0 != 0xC0: // REGULAR orders (000x xxxx, 001x xxxx, 010x xxxx, 011x xxxx, 100x xxxx)
+		return Code(orderHdr >> 5)
+	case orderHdr&0xF0 == 0xF0: // MEGA and SPECIAL orders (0xF*)
+		return Code(orderHdr)
+	default: // LITE orders (1100 xxxx, 1101 xxxx, 1110 xxxx)
+		return Code(orderHdr >> 4)
+	}
+}
+
+func isRegularCode(code Code) bool {
+	switch code {
+	case RegularBackgroundRun, RegularForegroundRun, RegularColorRun,
+		RegularForegroundBackgroundImageRun, RegularColorImageRun:
+		return true
+	}
+
+	return false
+}
+
+func isLiteCode(code Code) bool {
+	switch code {
+	case LiteSetForegroundRun, LiteDitheredRun, LiteSetForegroundForegroundBackgroundImageRun:
+		return true
+	}
+
+	return false
+}
+
+func isMegaMegaCode(code Code) bool {
+	switch code {
+	case MegaMegaBackgroundRun, MegaMegaForegroundRun, MegaMegaSetForegroundRun, MegaMegaDitheredRun,
+		MegaMegaColorRun, MegaMegaForegroundBackgroundImageRun, MegaMegaSetForegroundBackgroundImage,
+		MegaMegaColorImage:
+		return true
+	}
+
+	return false
+}
+
+// readRunLength returns the run length of the order and advances over the order header.
+func (d *decompressor) readRunLength(code Code) (int, bool) {
+	orderHdr := d.pbSrcBuffer[d.src]
+	d.src++
+
+	switch {
+	case isRegularCode(code) || isLiteCode(code):
+		mask, extended := byte(maskRegularRunLength), 32
+		if isLiteCode(code) {
+			mask, extended = maskLiteRunLength, 16
+		}
+
+		fgbg := code == RegularForegroundBackgroundImageRun || code == LiteSetForegroundForegroundBackgroundImageRun
+
+		if runLength := int(orderHdr & mask); runLength != 0 {
+			if fgbg {
+				return runLength * 8, true
+			}
+
+			return runLength, true
+		}
+
+		// an extended (MEGA) run
+		if d.src >= len(d.pbSrcBuffer) {
+			return 0, false
+		}
+
+		runLength := int(d.pbSrcBuffer[d.src])
+		d.src++
+
+		if fgbg {
+			return runLength + 1, true
+		}
+
+		return runLength + extended, true
+	case isMegaMegaCode(code):
+		if d.src+2 > len(d.pbSrcBuffer) {
+			return 0, false
+		}
+
+		runLength := int(d.pbSrcBuffer[d.src]) | int(d.pbSrcBuffer[d.src+1])<<8
+		d.src += 2
+
+		return runLength, true
+	}
+
+	return 0, true // special orders
+}
+
+// readPixel reads a pixel of the source.
+func (d *decompressor) readPixel() (Pixel, bool) {
+	if d.src+d.pixelSize > len(d.pbSrcBuffer) {
+		return 0, false
+	}
+
+	var pixel Pixel
+
+	for i := 0; i < d.pixelSize; i++ {
+		pixel |= Pixel(d.pbSrcBuffer[d.src+i]) << (8 * i)
+	}
+
+	d.src += d.pixelSize
+
+	return pixel, true
+}
+
+// writePixel writes the pixel to the destination.
+func (d *decompressor) writePixel(pixel Pixel) bool {
+	if d.dest+d.pixelSize > len(d.pbDestBuffer) {
+		return false
+	}
+
+	for i := 0; i < d.pixelSize; i++ {
+		d.pbDestBuffer[d.dest+i] = byte(pixel >> (8 * i))
+	}
+
+	d.dest += d.pixelSize
+
+	return true
+}
+
+// writeForeground writes the pixel on the first line, below it the pixel is XORed with the one of the previous line.
+func (d *decompressor) writeForeground(firstLine bool, pixel Pixel) bool {
+	if firstLine {
+		return d.writePixel(pixel)
+	}
+
+	above := d.dest - d.rowDelta
+	if above < 0 || above+d.pixelSize > len(d.pbDestBuffer) {
+		return false
+	}
+
+	var xorPixel Pixel
+
+	for i := 0; i < d.pixelSize; i++ {
+		xorPixel |= Pixel(d.pbDestBuffer[above+i]) << (8 * i)
+	}
+
+	return d.writePixel(xorPixel ^ pixel)
+}
+
+// writeForegroundBackgroundImage writes cBits pixels of the bitmask, least significant bit first:
+// the set bits are the foreground.
+func (d *decompressor) writeForegroundBackgroundImage(firstLine bool, bitmask byte, fgPel Pixel, cBits int) bool {
+	for i := 0; i < cBits; i++ {
+		pixel := Pixel(0)
+		if bitmask&(1<<i) != 0 {
+			pixel = fgPel
+		}
+
+		if !d.writeForeground(firstLine, pixel) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,7 +1,14 @@
 package rle
 
+// Decompress decompresses the 16 bpp Interleaved RLE bitmap, rowDelta is the scanline length in bytes.
 func Decompress(pbSrcBuffer []byte, pbDestBuffer []byte, rowDelta int) bool {
-	d := newDecompressor16(pbSrcBuffer, pbDestBuffer, rowDelta)
+	return DecompressColorDepth(16, pbSrcBuffer, pbDestBuffer, rowDelta)
+}
+
+// DecompressColorDepth decompresses the Interleaved RLE bitmap of 8, 15, 16 or 24 bits per pixel.
+// The pixels are little-endian, 8 bpp ones are palette indices.
+func DecompressColorDepth(colorDepth int, pbSrcBuffer []byte, pbDestBuffer []byte, rowDelta int) bool {
+	d := newDecompressor(colorDepth, pbSrcBuffer, pbDestBuffer, rowDelta)
 
 	return d.Decompress()
 }
@@ -8,8 +8,6 @@ import (
 )
 
 func Test_Decompress(t *testing.T) {
-	t.Skip("not implemented")
-
 	testCases := []struct {
 		name     string
 		input    string
@@ -53,3 +51,126 @@ func Test_Decompress(t *testing.T) {
 		})
 	}
 }
+
+func Test_DecompressColorDepth(t *testing.T) {
+	for _, tc := range []struct {
+		colorDepth int
+		pixelSize  int
+		white      Pixel
+	}{
+		{8, 1, 0xFF},
+		{15, 2, 0x7FFF},
+		{16, 2, 0xFFFF},
+		{24, 3, 0xFFFFFF},
+	} {
+		var (
+			mask = tc.white
+
+			p = 0x5A5A5A & mask
+			f = 0x0F0F0F & mask
+			a = 0x111111 & mask
+			b = 0x222222 & mask
+			g = 0x3C3C3C & mask
+			r = []Pixel{0x010203 & mask, 0x040506 & mask, 0x070809 & mask, 0x0A0B0C & mask}
+		)
+
+		pixel := func(pixel Pixel) []byte {
+			return []byte{byte(pixel), byte(pixel >> 8), byte(pixel >> 16)}[:tc.pixelSize]
+		}
+
+		src := []byte{0x62} // REGULAR_COLOR_RUN of 2
+		src = append(src, pixel(p)...)
+		src = append(src, byte(WhiteRun), byte(BlackRun))
+		src = append(src, 0x04) // REGULAR_BG_RUN of 4
+		src = append(src, 0xD1) // LITE_SET_FG_FGBG_IMAGE of 8
+		src = append(src, pixel(f)...)
+		src = append(src, 0x05) // bitmask
+		src = append(src, 0xE2) // LITE_DITHERED_RUN of 2
+		src = append(src, pixel(a)...)
+		src = append(src, pixel(b)...)
+		src = append(src, 0x84) // REGULAR_COLOR_IMAGE of 4
+
+		for _, pel := range r {
+			src = append(src, pixel(pel)...)
+		}
+
+		src = append(src, 0xF6, 0x04, 0x00) // MEGA_MEGA_SET_FG_RUN of 4
+		src = append(src, pixel(g)...)
+		src = append(src, 0x22)             // REGULAR_FG_RUN of 2
+		src = append(src, 0x02)             // REGULAR_BG_RUN of 2
+		src = append(src, 0x02)             // REGULAR_BG_RUN of 2 with the foreground pel inserted
+		src = append(src, 0xF0, 0x02, 0x00) // MEGA_MEGA_BG_RUN of 2 with the foreground pel inserted
+
+		expected := [][]Pixel{
+			{p, p, tc.white, 0},
+			{p, p, tc.white, 0},
+			{p ^ f, p, tc.white ^ f, 0},
+			{p ^ f, p, tc.white ^ f, 0},
+			{a, b, a, b},
+			r,
+			{r[0] ^ g, r[1] ^ g, r[2] ^ g, r[3] ^ g},
+			{r[0], r[1], r[2] ^ g, r[3] ^ g},
+			{r[0] ^ g, r[1], r[2], r[3] ^ g},
+		}
+
+		var expectedBytes []byte
+		for _, row := range expected {
+			for _, pel := range row {
+				expectedBytes = append(expectedBytes, pixel(pel)...)
+			}
+		}
+
+		dest := make([]byte, len(expectedBytes))
+
+		require.True(t, DecompressColorDepth(tc.colorDepth, src, dest, 4*tc.pixelSize), "%d bpp", tc.colorDepth)
+		require.Equal(t, expectedBytes, dest, "%d bpp", tc.colorDepth)
+	}
+}
+
+func Test_DecompressFirstLine(t *testing.T) {
+	src := []byte{
+		byte(SpecialForegroundBackground2Run), // 0x05 bitmask with the white foreground
+		0x02,                                  // REGULAR_BG_RUN of 2 is black on the first line
+		0x02,                                  // REGULAR_BG_RUN of 2 with the foreground pel inserted
+		0xF9,                                  // SPECIAL_FGBG_1, 0x03 bitmask below the first line
+		0x40, 0x02,                            // REGULAR_FGBG_IMAGE of 0x02+1 with 0x07 bitmask
+		0x07,
+		0xF2, 0x03, 0x00, 0x07, // MEGA_MEGA_FGBG_IMAGE of 3 with 0x07 bitmask
+		0x00, 0x02, // REGULAR_BG_RUN of 0x02+32
+	}
+
+	dest := make([]byte, 12*3*2+34*2)
+
+	require.True(t, Decompress(src, dest, 12*2))
+
+	expected := []Pixel{
+		WhitePixel, 0, WhitePixel, 0, 0, 0, 0, 0, 0, 0, WhitePixel, 0,
+		0, WhitePixel, WhitePixel, 0, 0, 0, 0, 0, WhitePixel, WhitePixel, 0, WhitePixel,
+		WhitePixel, 0, WhitePixel, 0, 0, 0, 0, 0, WhitePixel, WhitePixel, 0, WhitePixel,
+	}
+
+	for i, pel := range expected {
+		require.Equal(t, pel, Pixel(dest[2*i])|Pixel(dest[2*i+1])<<8, "pixel %d", i)
+	}
+}
+
+func Test_DecompressMalformed(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		colorDepth int
+		input      []byte
+		destLen    int
+	}{
+		{"unknown order", 16, []byte{0xFB}, 16},
+		{"truncated run length", 16, []byte{0x00}, 16},
+		{"truncated mega mega run length", 16, []byte{0xF3, 0x01}, 16},
+		{"truncated color", 16, []byte{0x61, 0xFF}, 16},
+		{"truncated color image", 24, []byte{0x82, 0x01, 0x02, 0x03}, 16},
+		{"truncated bitmask", 16, []byte{0x41}, 16},
+		{"destination too small", 16, []byte{0x69, 0xFF, 0xFF}, 16},
+		{"color image beyond destination", 8, []byte{0x84, 0x01, 0x02, 0x03, 0x04}, 3},
+		{"unsupported color depth", 32, []byte{byte(WhiteRun)}, 16},
+	} {
+		require.False(t, DecompressColorDepth(tc.colorDepth, tc.input, make([]byte, tc.destLen), 8), tc.name)
+	}
+}
@@ -85,7 +85,8 @@ const (
 	BlackRun Code = 0xFE
 )
 
-type Pixel uint16
+// Pixel of up to 24 bits per pixel.
+type Pixel uint32
 
 const (
 	WhitePixel Pixel = 0xFFFF
@@ -0,0 +1,30 @@
+package sec
+
+// Cipher encrypts and signs data under Standard RDP Security.
+type Cipher interface {
+	// Encrypt returns the data signature followed by the encrypted data,
+	// prefixed by the FIPS header under FIPS encryption.
+	Encrypt(data []byte) []byte
+
+	// Decrypt verifies the data signature in front of the encrypted data and returns the plain data.
+	Decrypt(data []byte, saltedChecksum bool) ([]byte, error)
+
+	// SaltedChecksum reports whether Encrypt produces salted MAC signatures.
+	SaltedChecksum() bool
+
+	// SetSaltedChecksum enables salted MAC signatures (ENC_SALTED_CHECKSUM).
+	SetSaltedChecksum(salted bool)
+}
+
+// NewCipher derives session keys from the client and server randoms
+// for the encryption method selected by the server.
+func NewCipher(method EncryptionMethod, clientRandom, serverRandom []byte) (Cipher, error) {
+	switch method {
+	case EncryptionMethod40Bit, EncryptionMethod56Bit, EncryptionMethod128Bit:
+		return newRC4Cipher(method, clientRandom, serverRandom, false), nil
+	case EncryptionMethodFIPS:
+		return newFIPSCipher(clientRandom, serverRandom, false), nil
+	}
+
+	return nil, ErrUnsupportedEncryption
+}
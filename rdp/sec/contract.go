@@ -0,0 +1,8 @@
+package sec
+
+import "io"
+
+type mcsConn interface {
+	Send(userID, channelID uint16, pduData []byte) error
+	Receive() (uint16, io.Reader, error)
+}
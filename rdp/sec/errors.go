@@ -0,0 +1,10 @@
+package sec
+
+import "errors"
+
+var (
+	ErrInvalidSignature       = errors.New("invalid data signature")
+	ErrUnsupportedEncryption  = errors.New("unsupported encryption method")
+	ErrUnsupportedCertificate = errors.New("unsupported server certificate")
+	ErrEncryptedWithoutCipher = errors.New("encrypted PDU without negotiated encryption")
+)
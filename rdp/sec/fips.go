@@ -0,0 +1,169 @@
+package sec
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"math/bits"
+	"sync"
+)
+
+const (
+	fipsHeaderLen = 4 // length, version and padlen of TS_FIPS_SECURITY_HEADER
+	fipsVersion   = 0x01
+)
+
+var fipsIV = []byte{0x12, 0x34, 0x56, 0x78, 0x90, 0xab, 0xcd, 0xef}
+
+// fipsCipher implements FIPS 140 Standard RDP Security: Triple DES in CBC mode and SHA-1 HMAC signatures.
+type fipsCipher struct {
+	mu sync.Mutex
+
+	hmacKey      []byte
+	encrypter    cipher.BlockMode
+	decrypter    cipher.BlockMode
+	encryptCount uint32
+	decryptCount uint32
+}
+
+// newFIPSCipher generates session keys, MS-RDPBCGR 5.3.5.2 FIPS.
+// The server flag swaps encryption and decryption keys and is used by tests.
+func newFIPSCipher(clientRandom, serverRandom []byte, server bool) *fipsCipher {
+	encryptKeyT := fipsKeyT(clientRandom[16:32], serverRandom[16:32])
+	decryptKeyT := fipsKeyT(clientRandom[:16], serverRandom[:16])
+
+	hmacKey := sha1.New()
+	hmacKey.Write(decryptKeyT)
+	hmacKey.Write(encryptKeyT)
+
+	encryptKey, decryptKey := fipsExpandKey(encryptKeyT), fipsExpandKey(decryptKeyT)
+	if server {
+		encryptKey, decryptKey = decryptKey, encryptKey
+	}
+
+	encryptBlock, _ := des.NewTripleDESCipher(encryptKey)
+	decryptBlock, _ := des.NewTripleDESCipher(decryptKey)
+
+	return &fipsCipher{
+		hmacKey:   hmacKey.Sum(nil),
+		encrypter: cipher.NewCBCEncrypter(encryptBlock, fipsIV),
+		decrypter: cipher.NewCBCDecrypter(decryptBlock, fipsIV),
+	}
+}
+
+// Encrypt returns the FIPS header, the data signature and the encrypted data padded to the block size.
+func (c *fipsCipher) Encrypt(data []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	padLen := (des.BlockSize - len(data)%des.BlockSize) % des.BlockSize
+
+	output := make([]byte, fipsHeaderLen+signatureLen+len(data)+padLen)
+
+	binary.LittleEndian.PutUint16(output, 0x10)
+	output[2] = fipsVersion
+	output[3] = uint8(padLen)
+
+	copy(output[fipsHeaderLen:], c.signature(data, c.encryptCount))
+
+	encrypted := output[fipsHeaderLen+signatureLen:]
+	copy(encrypted, data)
+	c.encrypter.CryptBlocks(encrypted, encrypted)
+
+	c.encryptCount++
+
+	return output
+}
+
+// Decrypt verifies the data signature and returns the plain data without padding.
+// FIPS signatures are never salted.
+func (c *fipsCipher) Decrypt(data []byte, _ bool) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(data) < fipsHeaderLen+signatureLen || (len(data)-fipsHeaderLen-signatureLen)%des.BlockSize != 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	padLen := int(data[3])
+	signature := data[fipsHeaderLen : fipsHeaderLen+signatureLen]
+
+	output := make([]byte, len(data)-fipsHeaderLen-signatureLen)
+	c.decrypter.CryptBlocks(output, data[fipsHeaderLen+signatureLen:])
+
+	count := c.decryptCount
+	c.decryptCount++
+
+	if padLen >= des.BlockSize || padLen > len(output) {
+		return nil, ErrInvalidSignature
+	}
+
+	output = output[:len(output)-padLen]
+
+	if !hmac.Equal(signature, c.signature(output, count)) {
+		return nil, ErrInvalidSignature
+	}
+
+	return output, nil
+}
+
+func (c *fipsCipher) SaltedChecksum() bool {
+	return false
+}
+
+func (c *fipsCipher) SetSaltedChecksum(bool) {}
+
+// signature computes the data signature, MS-RDPBCGR 5.3.6.2 FIPS.
+func (c *fipsCipher) signature(data []byte, count uint32) []byte {
+	mac := hmac.New(sha1.New, c.hmacKey)
+	mac.Write(data)
+	_ = binary.Write(mac, binary.LittleEndian, count)
+
+	return mac.Sum(nil)[:signatureLen]
+}
+
+func fipsKeyT(clientRandom, serverRandom []byte) []byte {
+	sha := sha1.New()
+	sha.Write(clientRandom)
+	sha.Write(serverRandom)
+
+	return sha.Sum(nil)
+}
+
+// fipsExpandKey expands the 160-bit key, extended with its first byte to 168 bits,
+// to a 192-bit Triple DES key inserting a parity bit after every 7 bits.
+// Bits of every byte are reversed before and after the expansion.
+func fipsExpandKey(keyT []byte) []byte {
+	in := make([]byte, 21)
+	for i, b := range append(keyT[:20:20], keyT[0]) {
+		in[i] = bits.Reverse8(b)
+	}
+
+	key := make([]byte, 24)
+
+	for i := range key {
+		p, r := i*7/8, i*7%8
+
+		c := in[p] << r
+		if r > 1 {
+			c |= in[p+1] >> (8 - r)
+		}
+
+		key[i] = oddParity(bits.Reverse8(c & 0xfe))
+	}
+
+	return key
+}
+
+// oddParity sets the least significant bit so the byte has odd parity.
+func oddParity(b byte) byte {
+	b &= 0xfe
+
+	if bits.OnesCount8(b)%2 == 0 {
+		b |= 0x1
+	}
+
+	return b
+}
@@ -0,0 +1,101 @@
+package sec
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fipsRandoms returns the randoms of the known vectors: the HMAC key, the signatures and the Triple DES
+// ciphertexts below were computed with the OpenSSL command line tools from the expanded keys.
+func fipsRandoms() ([]byte, []byte) {
+	clientRandom := make([]byte, 32)
+	serverRandom := make([]byte, 32)
+
+	for i := range clientRandom {
+		clientRandom[i] = byte(i)
+		serverRandom[i] = byte(0x20 + i)
+	}
+
+	return clientRandom, serverRandom
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestFIPSCipher_Keys(t *testing.T) {
+	clientRandom, serverRandom := fipsRandoms()
+
+	encryptKeyT := fipsKeyT(clientRandom[16:32], serverRandom[16:32])
+	decryptKeyT := fipsKeyT(clientRandom[:16], serverRandom[:16])
+
+	require.Equal(t, mustDecodeHex(t, "6708701a086d106b2a767f20017343203b16641a0d490132"), fipsExpandKey(encryptKeyT))
+	require.Equal(t, mustDecodeHex(t, "4f75384f4f520b2561513261256e79490b61130b2a076e26"), fipsExpandKey(decryptKeyT))
+
+	cipher := newFIPSCipher(clientRandom, serverRandom, false)
+	require.Equal(t, mustDecodeHex(t, "bb45ea4383cd332a1f15df0981a4aaec95dc14eb"), cipher.hmacKey)
+}
+
+func TestFIPSCipher_Encrypt(t *testing.T) {
+	clientRandom, serverRandom := fipsRandoms()
+
+	client := newFIPSCipher(clientRandom, serverRandom, false)
+	server := newFIPSCipher(clientRandom, serverRandom, true)
+
+	packets := []struct {
+		data     []byte
+		expected []byte
+	}{
+		{
+			data:     []byte("Plaintext"),
+			expected: mustDecodeHex(t, "10000107dc353706287b60b76f2dd56c936488fe460adb368200b208"),
+		},
+		{
+			data:     []byte("second packet!!"),
+			expected: mustDecodeHex(t, "100001019c99fae9d96e23aa644dc0beec51933bc5427e9b84f05ade"),
+		},
+	}
+
+	for _, packet := range packets {
+		actual := client.Encrypt(packet.data)
+		require.Equal(t, packet.expected, actual)
+
+		decrypted, err := server.Decrypt(actual, false)
+		require.NoError(t, err)
+		require.Equal(t, packet.data, decrypted)
+	}
+}
+
+func TestFIPSCipher_InvalidSignature(t *testing.T) {
+	clientRandom, serverRandom := fipsRandoms()
+
+	client := newFIPSCipher(clientRandom, serverRandom, false)
+	server := newFIPSCipher(clientRandom, serverRandom, true)
+
+	encrypted := client.Encrypt([]byte("Plaintext"))
+	encrypted[fipsHeaderLen] ^= 0xff
+
+	_, err := server.Decrypt(encrypted, false)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = server.Decrypt(encrypted[:fipsHeaderLen+signatureLen+3], false)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestFIPSExpandKey_OddParity(t *testing.T) {
+	clientRandom, serverRandom := fipsRandoms()
+
+	for _, b := range fipsExpandKey(fipsKeyT(clientRandom, serverRandom)) {
+		var ones int
+		for ; b != 0; b >>= 1 {
+			ones += int(b & 1)
+		}
+
+		require.Equal(t, 1, ones%2)
+	}
+}
@@ -0,0 +1,72 @@
+package sec
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"encoding/binary"
+)
+
+// LicensingKeys are the licensing session keys, MS-RDPELE 5.1.3.
+type LicensingKeys struct {
+	macSaltKey    []byte
+	encryptionKey []byte
+}
+
+// NewLicensingKeys derives the MAC salt key and the licensing encryption key
+// from the premaster secret and the randoms of the licensing exchange.
+func NewLicensingKeys(clientRandom, serverRandom, preMasterSecret []byte) *LicensingKeys {
+	masterSecret := make([]byte, 0, 48)
+	sessionKeyBlob := make([]byte, 0, 48)
+
+	for _, i := range [][]byte{{'A'}, {'B', 'B'}, {'C', 'C', 'C'}} {
+		masterSecret = append(masterSecret, saltedHash(preMasterSecret, i, clientRandom, serverRandom)...)
+	}
+
+	// the session key blob salts the master secret with the randoms swapped
+	for _, i := range [][]byte{{'A'}, {'B', 'B'}, {'C', 'C', 'C'}} {
+		sessionKeyBlob = append(sessionKeyBlob, saltedHash(masterSecret, i, serverRandom, clientRandom)...)
+	}
+
+	return &LicensingKeys{
+		macSaltKey:    sessionKeyBlob[:16],
+		encryptionKey: finalHash(sessionKeyBlob[16:32], clientRandom, serverRandom),
+	}
+}
+
+// Encrypt encrypts a licensing message with RC4, every message starts a new key stream.
+func (k *LicensingKeys) Encrypt(data []byte) []byte {
+	cipher, _ := rc4.NewCipher(k.encryptionKey)
+
+	output := make([]byte, len(data))
+	cipher.XORKeyStream(output, data)
+
+	return output
+}
+
+// Decrypt decrypts a licensing message, RC4 is symmetric.
+func (k *LicensingKeys) Decrypt(data []byte) []byte {
+	return k.Encrypt(data)
+}
+
+// MAC computes the 16-byte MAC of the licensing message data, MS-RDPELE 5.1.6.
+func (k *LicensingKeys) MAC(data []byte) []byte {
+	sha := sha1.New()
+	sha.Write(k.macSaltKey)
+	sha.Write(pad1)
+	_ = binary.Write(sha, binary.LittleEndian, uint32(len(data)))
+	sha.Write(data)
+
+	md := md5.New()
+	md.Write(k.macSaltKey)
+	md.Write(pad2)
+	md.Write(sha.Sum(nil))
+
+	return md.Sum(nil)
+}
+
+// VerifyMAC reports whether mac is the MAC of data.
+func (k *LicensingKeys) VerifyMAC(data, mac []byte) bool {
+	return hmac.Equal(k.MAC(data), mac)
+}
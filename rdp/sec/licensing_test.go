@@ -0,0 +1,33 @@
+package sec
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLicensingKeys(t *testing.T) {
+	clientRandom, serverRandom, preMasterSecret := make([]byte, 32), make([]byte, 32), make([]byte, 48)
+
+	for i := range clientRandom {
+		clientRandom[i], serverRandom[i] = byte(i), byte(32+i)
+	}
+
+	for i := range preMasterSecret {
+		preMasterSecret[i] = byte(64 + i)
+	}
+
+	keys := NewLicensingKeys(clientRandom, serverRandom, preMasterSecret)
+
+	require.Equal(t, "d2021da2c79f6e97fbd7ac935d9d79a0", hex.EncodeToString(keys.encryptionKey))
+
+	encrypted := keys.Encrypt([]byte("challenge"))
+	require.Equal(t, "6450a222b9370a7ad3", hex.EncodeToString(encrypted))
+	require.Equal(t, []byte("challenge"), keys.Decrypt(encrypted))
+
+	mac := keys.MAC([]byte("challenge"))
+	require.Equal(t, "e3d21cb948a3aa225f3eaba3fb6ec8d8", hex.EncodeToString(mac))
+	require.True(t, keys.VerifyMAC([]byte("challenge"), mac))
+	require.False(t, keys.VerifyMAC([]byte("challengE"), mac))
+}
@@ -0,0 +1,28 @@
+package sec
+
+type Protocol struct {
+	mcsConn mcsConn
+	cipher  Cipher
+}
+
+func New(mcsConn mcsConn) *Protocol {
+	return &Protocol{
+		mcsConn: mcsConn,
+	}
+}
+
+// Enable turns on Standard RDP Security: every PDU carries a security header
+// and client to server data is encrypted with cipher.
+func (p *Protocol) Enable(cipher Cipher) {
+	p.cipher = cipher
+}
+
+// Enabled reports whether Standard RDP Security is in effect.
+func (p *Protocol) Enabled() bool {
+	return p.cipher != nil
+}
+
+// Cipher returns the negotiated cipher or nil.
+func (p *Protocol) Cipher() Cipher {
+	return p.cipher
+}
@@ -0,0 +1,249 @@
+package sec
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"encoding/binary"
+	"sync"
+)
+
+const (
+	signatureLen      = 8
+	keyUpdateInterval = 4096
+)
+
+var (
+	pad1 = bytes.Repeat([]byte{0x36}, 40)
+	pad2 = bytes.Repeat([]byte{0x5c}, 48)
+)
+
+// rc4Key is the state of one direction of the RC4 stream.
+type rc4Key struct {
+	initial  []byte
+	current  []byte
+	cipher   *rc4.Cipher
+	useCount int    // packets since the last key update
+	count    uint32 // packets processed since the session start, used by the salted MAC
+}
+
+func newRC4Key(key []byte) rc4Key {
+	cipher, _ := rc4.NewCipher(key)
+
+	return rc4Key{
+		initial: key,
+		current: key,
+		cipher:  cipher,
+	}
+}
+
+// update generates a new session key, MS-RDPBCGR 5.3.7.1 Non-FIPS.
+func (k *rc4Key) update(method EncryptionMethod) {
+	sha := sha1.New()
+	sha.Write(k.initial)
+	sha.Write(pad1)
+	sha.Write(k.current)
+
+	md := md5.New()
+	md.Write(k.initial)
+	md.Write(pad2)
+	md.Write(sha.Sum(nil))
+
+	tempKey := md.Sum(nil)[:len(k.initial)]
+
+	cipher, _ := rc4.NewCipher(tempKey)
+
+	newKey := make([]byte, len(tempKey))
+	cipher.XORKeyStream(newKey, tempKey)
+
+	k.current = salt(newKey, method)
+	k.cipher, _ = rc4.NewCipher(k.current)
+	k.useCount = 0
+}
+
+// rc4Cipher implements 40-bit, 56-bit and 128-bit Standard RDP Security.
+type rc4Cipher struct {
+	mu sync.Mutex
+
+	method  EncryptionMethod
+	macKey  []byte
+	salted  bool
+	encrypt rc4Key
+	decrypt rc4Key
+}
+
+// newRC4Cipher generates session keys, MS-RDPBCGR 5.3.5.1 Non-FIPS.
+// The server flag swaps encryption and decryption keys and is used by tests.
+func newRC4Cipher(method EncryptionMethod, clientRandom, serverRandom []byte, server bool) *rc4Cipher {
+	preMasterSecret := make([]byte, 0, 48)
+	preMasterSecret = append(preMasterSecret, clientRandom[:24]...)
+	preMasterSecret = append(preMasterSecret, serverRandom[:24]...)
+
+	masterSecret := make([]byte, 0, 48)
+	sessionKeyBlob := make([]byte, 0, 48)
+
+	for _, i := range [][]byte{{'A'}, {'B', 'B'}, {'C', 'C', 'C'}} {
+		masterSecret = append(masterSecret, saltedHash(preMasterSecret, i, clientRandom, serverRandom)...)
+	}
+
+	for _, i := range [][]byte{{'X'}, {'Y', 'Y'}, {'Z', 'Z', 'Z'}} {
+		sessionKeyBlob = append(sessionKeyBlob, saltedHash(masterSecret, i, clientRandom, serverRandom)...)
+	}
+
+	macKey := sessionKeyBlob[:16]
+	serverEncryptKey := finalHash(sessionKeyBlob[16:32], clientRandom, serverRandom)
+	serverDecryptKey := finalHash(sessionKeyBlob[32:48], clientRandom, serverRandom)
+
+	encryptKey, decryptKey := serverDecryptKey, serverEncryptKey
+	if server {
+		encryptKey, decryptKey = decryptKey, encryptKey
+	}
+
+	return &rc4Cipher{
+		method:  method,
+		macKey:  reduceKey(macKey, method),
+		encrypt: newRC4Key(reduceKey(encryptKey, method)),
+		decrypt: newRC4Key(reduceKey(decryptKey, method)),
+	}
+}
+
+func (c *rc4Cipher) Encrypt(data []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.encrypt.useCount == keyUpdateInterval {
+		c.encrypt.update(c.method)
+	}
+
+	var signature []byte
+	if c.salted {
+		signature = c.signature(data, &c.encrypt.count)
+	} else {
+		signature = c.signature(data, nil)
+	}
+
+	output := make([]byte, signatureLen+len(data))
+	copy(output, signature)
+	c.encrypt.cipher.XORKeyStream(output[signatureLen:], data)
+
+	c.encrypt.useCount++
+	c.encrypt.count++
+
+	return output
+}
+
+func (c *rc4Cipher) Decrypt(data []byte, saltedChecksum bool) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(data) < signatureLen {
+		return nil, ErrInvalidSignature
+	}
+
+	if c.decrypt.useCount == keyUpdateInterval {
+		c.decrypt.update(c.method)
+	}
+
+	output := make([]byte, len(data)-signatureLen)
+	c.decrypt.cipher.XORKeyStream(output, data[signatureLen:])
+
+	var signature []byte
+	if saltedChecksum {
+		signature = c.signature(output, &c.decrypt.count)
+	} else {
+		signature = c.signature(output, nil)
+	}
+
+	c.decrypt.useCount++
+	c.decrypt.count++
+
+	if !hmac.Equal(signature, data[:signatureLen]) {
+		return nil, ErrInvalidSignature
+	}
+
+	return output, nil
+}
+
+func (c *rc4Cipher) SaltedChecksum() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.salted
+}
+
+func (c *rc4Cipher) SetSaltedChecksum(salted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.salted = salted
+}
+
+// signature computes the MAC signature, MS-RDPBCGR 5.3.6.1 Non-FIPS,
+// or the salted MAC signature, MS-RDPBCGR 5.3.6.1.1, when count is set.
+func (c *rc4Cipher) signature(data []byte, count *uint32) []byte {
+	sha := sha1.New()
+	sha.Write(c.macKey)
+	sha.Write(pad1)
+	_ = binary.Write(sha, binary.LittleEndian, uint32(len(data)))
+	sha.Write(data)
+
+	if count != nil {
+		_ = binary.Write(sha, binary.LittleEndian, *count)
+	}
+
+	md := md5.New()
+	md.Write(c.macKey)
+	md.Write(pad2)
+	md.Write(sha.Sum(nil))
+
+	return md.Sum(nil)[:signatureLen]
+}
+
+func saltedHash(secret, i, clientRandom, serverRandom []byte) []byte {
+	sha := sha1.New()
+	sha.Write(i)
+	sha.Write(secret)
+	sha.Write(clientRandom)
+	sha.Write(serverRandom)
+
+	md := md5.New()
+	md.Write(secret)
+	md.Write(sha.Sum(nil))
+
+	return md.Sum(nil)
+}
+
+func finalHash(key, clientRandom, serverRandom []byte) []byte {
+	md := md5.New()
+	md.Write(key)
+	md.Write(clientRandom)
+	md.Write(serverRandom)
+
+	return md.Sum(nil)
+}
+
+// reduceKey truncates a 128-bit key to the key length of the encryption method.
+func reduceKey(key []byte, method EncryptionMethod) []byte {
+	if method == EncryptionMethod128Bit {
+		return key[:16]
+	}
+
+	reduced := make([]byte, 8)
+	copy(reduced, key[:8])
+
+	return salt(reduced, method)
+}
+
+// salt replaces the leading bytes of 40-bit and 56-bit keys with 0xD1269E.
+func salt(key []byte, method EncryptionMethod) []byte {
+	switch method {
+	case EncryptionMethod40Bit:
+		copy(key, []byte{0xd1, 0x26, 0x9e})
+	case EncryptionMethod56Bit:
+		copy(key, []byte{0xd1})
+	}
+
+	return key
+}
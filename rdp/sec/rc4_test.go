@@ -0,0 +1,75 @@
+package sec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRC4Cipher(t *testing.T) {
+	clientRandom := bytes.Repeat([]byte{0x11}, 32)
+	serverRandom := bytes.Repeat([]byte{0x22}, 32)
+
+	for _, method := range []EncryptionMethod{EncryptionMethod40Bit, EncryptionMethod56Bit, EncryptionMethod128Bit} {
+		for _, salted := range []bool{false, true} {
+			client := newRC4Cipher(method, clientRandom, serverRandom, false)
+			server := newRC4Cipher(method, clientRandom, serverRandom, true)
+
+			client.SetSaltedChecksum(salted)
+			server.SetSaltedChecksum(salted)
+
+			// crosses the key update every 4096 packets
+			for i := 0; i < 2*keyUpdateInterval+1; i++ {
+				data := []byte{byte(i), byte(i >> 8), 0xde, 0xad, 0xbe, 0xef}
+
+				encrypted := client.Encrypt(data)
+				require.Len(t, encrypted, signatureLen+len(data))
+
+				actual, err := server.Decrypt(encrypted, salted)
+				require.NoError(t, err, "method %d, salted %v, packet %d", method, salted, i)
+				require.Equal(t, data, actual)
+
+				encrypted = server.Encrypt(data)
+
+				actual, err = client.Decrypt(encrypted, salted)
+				require.NoError(t, err, "method %d, salted %v, packet %d", method, salted, i)
+				require.Equal(t, data, actual)
+			}
+		}
+	}
+}
+
+func TestRC4Cipher_InvalidSignature(t *testing.T) {
+	clientRandom := bytes.Repeat([]byte{0x11}, 32)
+	serverRandom := bytes.Repeat([]byte{0x22}, 32)
+
+	client := newRC4Cipher(EncryptionMethod128Bit, clientRandom, serverRandom, false)
+	server := newRC4Cipher(EncryptionMethod128Bit, clientRandom, serverRandom, true)
+
+	encrypted := client.Encrypt([]byte("plaintext"))
+	encrypted[signatureLen] ^= 0xff
+
+	_, err := server.Decrypt(encrypted, false)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = server.Decrypt([]byte{0x01}, false)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestRC4Cipher_SaltedKeys(t *testing.T) {
+	clientRandom := bytes.Repeat([]byte{0x11}, 32)
+	serverRandom := bytes.Repeat([]byte{0x22}, 32)
+
+	cipher40 := newRC4Cipher(EncryptionMethod40Bit, clientRandom, serverRandom, false)
+	require.Len(t, cipher40.macKey, 8)
+	require.Equal(t, []byte{0xd1, 0x26, 0x9e}, cipher40.macKey[:3])
+	require.Equal(t, []byte{0xd1, 0x26, 0x9e}, cipher40.encrypt.initial[:3])
+
+	cipher56 := newRC4Cipher(EncryptionMethod56Bit, clientRandom, serverRandom, false)
+	require.Len(t, cipher56.decrypt.initial, 8)
+	require.Equal(t, byte(0xd1), cipher56.decrypt.initial[0])
+
+	cipher128 := newRC4Cipher(EncryptionMethod128Bit, clientRandom, serverRandom, false)
+	require.Len(t, cipher128.encrypt.initial, 16)
+}
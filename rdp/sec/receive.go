@@ -0,0 +1,55 @@
+package sec
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lunnik9/rdp/rdp/headers"
+)
+
+// Receive returns channelID and PDU data, reading the security header only when
+// Standard RDP Security is enabled.
+func (p *Protocol) Receive() (uint16, io.Reader, error) {
+	if p.cipher == nil {
+		return p.mcsConn.Receive()
+	}
+
+	channelID, _, wire, err := p.ReceiveWithFlags()
+
+	return channelID, wire, err
+}
+
+// ReceiveWithFlags always reads the security header and decrypts the PDU data when SEC_ENCRYPT is set.
+func (p *Protocol) ReceiveWithFlags() (uint16, Flag, io.Reader, error) {
+	channelID, wire, err := p.mcsConn.Receive()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	securityFlag, err := headers.UnwrapSecurityFlag(wire)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	flags := Flag(securityFlag)
+
+	if !flags.IsSet(FlagEncrypt) {
+		return channelID, flags, wire, nil
+	}
+
+	if p.cipher == nil {
+		return 0, 0, nil, ErrEncryptedWithoutCipher
+	}
+
+	data, err := io.ReadAll(wire)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	data, err = p.cipher.Decrypt(data, flags.IsSet(FlagSecureChecksum))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return channelID, flags, bytes.NewReader(data), nil
+}
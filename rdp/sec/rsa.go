@@ -0,0 +1,113 @@
+package sec
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+// ServerPublicKey returns the RSA public key of the server certificate,
+// either proprietary or the last certificate of the X.509 chain.
+func ServerPublicKey(cert *pdu.ServerCertificate) (*rsa.PublicKey, error) {
+	if cert == nil {
+		return nil, ErrUnsupportedCertificate
+	}
+
+	if cert.ProprietaryCert != nil {
+		blob := cert.ProprietaryCert.PublicKeyBlob
+
+		modulusLen := int(blob.BitLen / 8)
+		if modulusLen == 0 || modulusLen > len(blob.Modulus) {
+			return nil, ErrUnsupportedCertificate
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(reverse(blob.Modulus[:modulusLen])),
+			E: int(blob.PubExp),
+		}, nil
+	}
+
+	chain, err := x509CertificateChain(cert.X509Cert)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := x509.ParseCertificate(chain[len(chain)-1])
+	if err != nil {
+		return nil, fmt.Errorf("server certificate: %w", err)
+	}
+
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrUnsupportedCertificate
+	}
+
+	return publicKey, nil
+}
+
+// EncryptClientRandom encrypts the client random with the server public key.
+// Both the random and the result are little-endian, the result is followed
+// by 8 bytes of padding as the Security Exchange PDU requires.
+func EncryptClientRandom(publicKey *rsa.PublicKey, clientRandom []byte) []byte {
+	m := new(big.Int).SetBytes(reverse(clientRandom))
+	c := new(big.Int).Exp(m, big.NewInt(int64(publicKey.E)), publicKey.N)
+
+	encrypted := make([]byte, publicKey.Size()+8)
+	c.FillBytes(encrypted[:publicKey.Size()])
+
+	copy(encrypted, reverse(encrypted[:publicKey.Size()]))
+
+	return encrypted
+}
+
+// x509CertificateChain splits the X.509 Certificate Chain of the Server Certificate.
+func x509CertificateChain(data []byte) ([][]byte, error) {
+	wire := bytes.NewReader(data)
+
+	var numCertBlobs uint32
+	if err := binary.Read(wire, binary.LittleEndian, &numCertBlobs); err != nil {
+		return nil, err
+	}
+
+	if numCertBlobs == 0 {
+		return nil, ErrUnsupportedCertificate
+	}
+
+	chain := make([][]byte, 0, numCertBlobs)
+
+	for i := uint32(0); i < numCertBlobs; i++ {
+		var cbCert uint32
+		if err := binary.Read(wire, binary.LittleEndian, &cbCert); err != nil {
+			return nil, err
+		}
+
+		if int64(cbCert) > int64(wire.Len()) {
+			return nil, ErrUnsupportedCertificate
+		}
+
+		cert := make([]byte, cbCert)
+		if _, err := io.ReadFull(wire, cert); err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, cert)
+	}
+
+	return chain, nil
+}
+
+func reverse(data []byte) []byte {
+	reversed := make([]byte, len(data))
+
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+
+	return reversed
+}
@@ -0,0 +1,74 @@
+package sec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunnik9/rdp/rdp/pdu"
+)
+
+func TestEncryptClientRandom(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 512)
+	require.NoError(t, err)
+
+	modulus := reverse(privateKey.N.Bytes())
+
+	cert := pdu.ServerCertificate{
+		DwVersion: 1,
+		ProprietaryCert: &pdu.ServerProprietaryCertificate{
+			PublicKeyBlob: pdu.RSAPublicKey{
+				Magic:   0x31415352, // RSA1
+				KeyLen:  uint32(len(modulus) + 8),
+				BitLen:  512,
+				DataLen: 512/8 - 1,
+				PubExp:  uint32(privateKey.E),
+				Modulus: append(modulus, make([]byte, 8)...),
+			},
+		},
+	}
+
+	publicKey, err := ServerPublicKey(&cert)
+	require.NoError(t, err)
+	require.Equal(t, privateKey.PublicKey.N, publicKey.N)
+	require.Equal(t, privateKey.PublicKey.E, publicKey.E)
+
+	clientRandom := make([]byte, 32)
+	_, err = rand.Read(clientRandom)
+	require.NoError(t, err)
+
+	encrypted := EncryptClientRandom(publicKey, clientRandom)
+	require.Len(t, encrypted, 64+8)
+	require.Equal(t, make([]byte, 8), encrypted[64:])
+
+	c := new(big.Int).SetBytes(reverse(encrypted[:64]))
+	m := new(big.Int).Exp(c, privateKey.D, privateKey.N)
+
+	actual := make([]byte, 32)
+	m.FillBytes(actual)
+
+	require.Equal(t, clientRandom, reverse(actual))
+}
+
+func TestX509CertificateChain(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	_ = binary.Write(buf, binary.LittleEndian, uint32(2))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(3))
+	buf.Write([]byte{1, 2, 3})
+	_ = binary.Write(buf, binary.LittleEndian, uint32(2))
+	buf.Write([]byte{4, 5})
+	buf.Write(make([]byte, 8)) // padding
+
+	chain, err := x509CertificateChain(buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{1, 2, 3}, {4, 5}}, chain)
+
+	_, err = x509CertificateChain([]byte{1, 0, 0, 0, 0xff, 0, 0, 0})
+	require.ErrorIs(t, err, ErrUnsupportedCertificate)
+}
@@ -0,0 +1,30 @@
+package sec
+
+import (
+	"github.com/lunnik9/rdp/rdp/headers"
+)
+
+// Send sends pduData, prepending a security header only when Standard RDP Security is enabled.
+func (p *Protocol) Send(userID, channelID uint16, pduData []byte) error {
+	if p.cipher == nil {
+		return p.mcsConn.Send(userID, channelID, pduData)
+	}
+
+	return p.SendWithFlags(userID, channelID, 0, pduData)
+}
+
+// SendWithFlags always prepends a security header with flags.
+// Security exchange and licensing PDUs are never encrypted.
+func (p *Protocol) SendWithFlags(userID, channelID uint16, flags Flag, pduData []byte) error {
+	if p.cipher != nil && !flags.IsSet(FlagExchangePkt) && !flags.IsSet(FlagLicensePkt) {
+		flags |= FlagEncrypt
+
+		if p.cipher.SaltedChecksum() {
+			flags |= FlagSecureChecksum
+		}
+
+		pduData = p.cipher.Encrypt(pduData)
+	}
+
+	return p.mcsConn.Send(userID, channelID, headers.WrapSecurityFlag(uint16(flags), pduData))
+}
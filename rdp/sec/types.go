@@ -0,0 +1,77 @@
+package sec
+
+import "github.com/lunnik9/rdp/rdp/pdu"
+
+// Flag Basic Security Header flags.
+type Flag uint16
+
+const (
+	// FlagExchangePkt SEC_EXCHANGE_PKT
+	FlagExchangePkt Flag = 0x0001
+
+	// FlagTransportReq SEC_TRANSPORT_REQ
+	FlagTransportReq Flag = 0x0002
+
+	// FlagTransportRsp RDP_SEC_TRANSPORT_RSP
+	FlagTransportRsp Flag = 0x0004
+
+	// FlagEncrypt SEC_ENCRYPT
+	FlagEncrypt Flag = 0x0008
+
+	// FlagResetSeqno SEC_RESET_SEQNO
+	FlagResetSeqno Flag = 0x0010
+
+	// FlagIgnoreSeqno SEC_IGNORE_SEQNO
+	FlagIgnoreSeqno Flag = 0x0020
+
+	// FlagInfoPkt SEC_INFO_PKT
+	FlagInfoPkt Flag = 0x0040
+
+	// FlagLicensePkt SEC_LICENSE_PKT
+	FlagLicensePkt Flag = 0x0080
+
+	// FlagLicenseEncryptCS SEC_LICENSE_ENCRYPT_CS
+	FlagLicenseEncryptCS Flag = 0x0200
+
+	// FlagRedirectionPkt SEC_REDIRECTION_PKT
+	FlagRedirectionPkt Flag = 0x0400
+
+	// FlagSecureChecksum SEC_SECURE_CHECKSUM
+	FlagSecureChecksum Flag = 0x0800
+
+	// FlagAutodetectReq SEC_AUTODETECT_REQ
+	FlagAutodetectReq Flag = 0x1000
+
+	// FlagAutodetectRsp SEC_AUTODETECT_RSP
+	FlagAutodetectRsp Flag = 0x2000
+
+	// FlagHeartbeat SEC_HEARTBEAT
+	FlagHeartbeat Flag = 0x4000
+
+	// FlagFlagsHiValid SEC_FLAGSHI_VALID
+	FlagFlagsHiValid Flag = 0x8000
+)
+
+func (f Flag) IsSet(flag Flag) bool {
+	return f&flag == flag
+}
+
+// EncryptionMethod Server Security Data encryptionMethod.
+type EncryptionMethod uint32
+
+const (
+	// EncryptionMethodNone ENCRYPTION_METHOD_NONE
+	EncryptionMethodNone EncryptionMethod = 0x00000000
+
+	// EncryptionMethod40Bit ENCRYPTION_METHOD_40BIT
+	EncryptionMethod40Bit = EncryptionMethod(pdu.EncryptionMethodFlag40Bit)
+
+	// EncryptionMethod128Bit ENCRYPTION_METHOD_128BIT
+	EncryptionMethod128Bit = EncryptionMethod(pdu.EncryptionMethodFlag128Bit)
+
+	// EncryptionMethod56Bit ENCRYPTION_METHOD_56BIT
+	EncryptionMethod56Bit = EncryptionMethod(pdu.EncryptionMethodFlag56Bit)
+
+	// EncryptionMethodFIPS ENCRYPTION_METHOD_FIPS
+	EncryptionMethodFIPS = EncryptionMethod(pdu.EncryptionMethodFlagFIPS)
+)
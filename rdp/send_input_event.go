@@ -2,6 +2,19 @@ package rdp
 
 import "github.com/lunnik9/rdp/rdp/fastpath"
 
-func (c *client) SendInputEvent(data []byte) error {
-	return c.fastPath.Send(fastpath.NewInputEventPDU(data))
+func (c *Client) SendInputEvent(data []byte) error {
+	c.reconnectMu.Lock()
+	reconnecting, fastPath := c.reconnecting, c.fastPath
+	c.reconnectMu.Unlock()
+
+	if reconnecting { // dropped until the session is resumed
+		return nil
+	}
+
+	err := fastPath.Send(fastpath.NewInputEventPDU(data))
+	if err != nil && c.canAutoReconnect(err) {
+		return nil // GetUpdate resumes the session
+	}
+
+	return err
 }
@@ -3,15 +3,26 @@ package rdp
 import (
 	"bufio"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
 	"fmt"
 	"log"
 )
 
-func (c *client) StartTLS() error {
+func (c *Client) StartTLS() error {
 	tlsConn := tls.Client(c.conn, &tls.Config{
-		InsecureSkipVerify: true,
+		InsecureSkipVerify: true, // verified by the certificate policy
 		MinVersion:         tls.VersionTLS10,
 		MaxVersion:         tls.VersionTLS13,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if err := c.certificatePolicy.verify(c.hostname, state.PeerCertificates); err != nil {
+				return err
+			}
+
+			return c.restartPhaseDeadline()
+		},
 	})
 
 	log.Println("TPKT: StartTLS")
@@ -20,8 +31,48 @@ func (c *client) StartTLS() error {
 		return fmt.Errorf("TLS handshake: %w", err)
 	}
 
+	c.reconnectMu.Lock()
 	c.conn = tlsConn
+	c.reconnectMu.Unlock()
+
 	c.buffReader = bufio.NewReaderSize(c.conn, readBufferSize)
+	c.serverCertificates = tlsConn.ConnectionState().PeerCertificates
 
 	return nil
 }
+
+// WithCertificatePolicy sets verification of the server TLS certificate, any certificate is accepted by default.
+func WithCertificatePolicy(policy CertificatePolicy) Option {
+	return func(c *Client) {
+		c.certificatePolicy = policy
+	}
+}
+
+// ServerCertificates returns the server TLS certificate chain after connect.
+func (c *Client) ServerCertificates() []*x509.Certificate {
+	return c.serverCertificates
+}
+
+// serverPublicKey returns the SubjectPublicKey of the server TLS certificate.
+func (c *Client) serverPublicKey() ([]byte, error) {
+	tlsConn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return nil, errors.New("TLS is not started")
+	}
+
+	certificates := tlsConn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return nil, errors.New("no server certificate")
+	}
+
+	var subjectPublicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+
+	if _, err := asn1.Unmarshal(certificates[0].RawSubjectPublicKeyInfo, &subjectPublicKeyInfo); err != nil {
+		return nil, fmt.Errorf("server public key: %w", err)
+	}
+
+	return subjectPublicKeyInfo.PublicKey.Bytes, nil
+}
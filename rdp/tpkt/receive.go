@@ -9,7 +9,7 @@ import (
 func (p *Protocol) Receive() (io.Reader, error) {
 	tpktPacket := make([]byte, headerLen)
 
-	if _, err := p.conn.Read(tpktPacket); err != nil {
+	if _, err := io.ReadFull(p.conn, tpktPacket); err != nil {
 		return nil, err
 	}
 
@@ -20,7 +20,7 @@ func (p *Protocol) Receive() (io.Reader, error) {
 
 	data := make([]byte, dataLen)
 
-	if _, err := p.conn.Read(data); err != nil {
+	if _, err := io.ReadFull(p.conn, data); err != nil {
 		return nil, err
 	}
 
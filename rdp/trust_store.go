@@ -0,0 +1,104 @@
+package rdp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TrustStore keeps SHA-256 fingerprints of server certificates trusted on first use. The servers are keyed
+// by host on the default port 3389 and by [host]:port on the other ports.
+type TrustStore interface {
+	// Fingerprint returns the trusted fingerprint for host or an empty string for an unknown host.
+	Fingerprint(host string) (string, error)
+
+	// Trust remembers the fingerprint for host.
+	Trust(host, fingerprint string) error
+}
+
+// FileTrustStore is a TrustStore persisted to a file with one "host fingerprint" pair per line.
+type FileTrustStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileTrustStore(path string) *FileTrustStore {
+	return &FileTrustStore{
+		path: path,
+	}
+}
+
+func (s *FileTrustStore) Fingerprint(host string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fingerprints, err := s.read()
+	if err != nil {
+		return "", err
+	}
+
+	return fingerprints[host], nil
+}
+
+func (s *FileTrustStore) Trust(host, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fingerprints, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	fingerprints[host] = normalizeFingerprint(fingerprint)
+
+	return s.write(fingerprints)
+}
+
+func (s *FileTrustStore) read() (map[string]string, error) {
+	fingerprints := make(map[string]string)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fingerprints, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		fingerprints[fields[0]] = normalizeFingerprint(fields[1])
+	}
+
+	return fingerprints, scanner.Err()
+}
+
+func (s *FileTrustStore) write(fingerprints map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	for host, fingerprint := range fingerprints {
+		fmt.Fprintf(buf, "%s %s\n", host, fingerprint)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,30 @@
+package rdp
+
+import "strings"
+
+// splitUsername splits the down-level logon name DOMAIN\user and the user principal name user@domain,
+// other names have no domain. Malformed names with an empty part are kept as the user name.
+func splitUsername(username string) (domain, user string) {
+	if i := strings.IndexByte(username, '\\'); i != -1 {
+		if i == len(username)-1 {
+			return "", username
+		}
+
+		return username[:i], username[i+1:]
+	}
+
+	if i := strings.LastIndexByte(username, '@'); i > 0 && i < len(username)-1 {
+		return username[i+1:], username[:i]
+	}
+
+	return "", username
+}
+
+// mstshashCookie returns the user name without the domain truncated to 9 characters, as mstsc sends it.
+func mstshashCookie(username string) string {
+	if runes := []rune(username); len(runes) > 9 {
+		return string(runes[:9])
+	}
+
+	return username
+}
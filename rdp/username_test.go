@@ -0,0 +1,55 @@
+package rdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_splitUsername(t *testing.T) {
+	for _, tc := range []struct {
+		username string
+		domain   string
+		user     string
+	}{
+		{"alice", "", "alice"},
+		{"CORP\\alice", "CORP", "alice"},
+		{"alice@corp.example", "corp.example", "alice"},
+		{".\\alice", ".", "alice"},
+		{"\\alice", "", "alice"},
+		{"CORP\\alice@corp.example", "CORP", "alice@corp.example"},
+		{"CORP\\sub\\alice", "CORP", "sub\\alice"},
+		{"alice@home@corp.example", "corp.example", "alice@home"},
+		{"CORP\\", "", "CORP\\"},
+		{"alice@", "", "alice@"},
+		{"@corp.example", "", "@corp.example"},
+		{"", "", ""},
+	} {
+		domain, user := splitUsername(tc.username)
+		require.Equal(t, tc.domain, domain, tc.username)
+		require.Equal(t, tc.user, user, tc.username)
+	}
+}
+
+func TestNew_Username(t *testing.T) {
+	for _, tc := range []struct {
+		username string
+		domain   string // of the config
+		expected [2]string
+		cookie   string
+	}{
+		{"CORP\\alice", "", [2]string{"CORP", "alice"}, "alice"},
+		{"alice@corp.example", "", [2]string{"corp.example", "alice"}, "alice"},
+		{"CORP\\alice", "OTHER", [2]string{"OTHER", "alice"}, "alice"},
+		{"alice", "CORP", [2]string{"CORP", "alice"}, "alice"},
+		{"CORP\\administrator", "", [2]string{"CORP", "administrator"}, "administr"},
+	} {
+		config := testConfig
+		config.Username, config.Domain = tc.username, tc.domain
+
+		c, err := New(config)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, [2]string{c.domain, c.username}, tc.username)
+		require.Equal(t, tc.cookie, c.cookie, tc.username)
+	}
+}
@@ -0,0 +1,18 @@
+package utf16
+
+import (
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+// Decode decodes little-endian UTF-16 dropping the null terminator.
+func Decode(b []byte) string {
+	codes := make([]uint16, 0, len(b)/2)
+
+	for i := 0; i+1 < len(b); i += 2 {
+		codes = append(codes, binary.LittleEndian.Uint16(b[i:]))
+	}
+
+	return strings.TrimRight(string(utf16.Decode(codes)), "\x00")
+}
@@ -1,5 +1,5 @@
 package rdp
 
-func (c *client) Write(b []byte) (int, error) {
+func (c *Client) Write(b []byte) (int, error) {
 	return c.conn.Write(b)
 }
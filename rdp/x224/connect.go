@@ -14,14 +14,20 @@ type ConnectionRequest struct {
 	DSTREF       uint16
 	SRCREF       uint16
 	ClassOption  uint8
-	VariablePart []byte // unsupported
+	VariablePart []byte // X.224 parameters following the fixed part
 	UserData     []byte
 }
 
+const (
+	x224FixedPartLen = 6   // without length indicator (LI)
+	maxLI            = 254 // 255 is reserved
+
+	maxUserDataLen = maxLI - x224FixedPartLen
+)
+
 func (pdu *ConnectionRequest) Serialize() []byte {
-	const x224FixedPartLen = 6 // without length indicator (LI)
 
-	pdu.li = uint8(x224FixedPartLen + len(pdu.UserData))
+	pdu.li = uint8(x224FixedPartLen + len(pdu.VariablePart) + len(pdu.UserData))
 
 	buf := new(bytes.Buffer)
 
@@ -31,6 +37,7 @@ func (pdu *ConnectionRequest) Serialize() []byte {
 	_ = binary.Write(buf, binary.BigEndian, pdu.SRCREF)
 	_ = binary.Write(buf, binary.BigEndian, pdu.ClassOption)
 
+	buf.Write(pdu.VariablePart)
 	buf.Write(pdu.UserData)
 
 	return buf.Bytes()
@@ -56,7 +63,8 @@ func (pdu *ConnectionConfirm) Deserialize(wire io.Reader) error {
 		return err
 	}
 
-	if pdu.LI != packetLen {
+	// legacy servers send no RDP Negotiation Response
+	if pdu.LI != packetLen && pdu.LI != fixedPartLen {
 		return ErrSmallConnectionConfirmLength
 	}
 
@@ -102,6 +110,10 @@ func (p *Protocol) Connect(userData []byte) (io.Reader, error) {
 		UserData:     userData,
 	}
 
+	if len(userData) > maxUserDataLen {
+		return nil, ErrConnectionRequestTooLong
+	}
+
 	log.Println("X224: Client Connection Request")
 
 	if err = p.tpktConn.Send(req.Serialize()); err != nil {
@@ -52,3 +52,18 @@ func Test_ConnectionConfirm(t *testing.T) {
 	require.NoError(t, actual.Deserialize(input))
 	require.Equal(t, expected, actual)
 }
+
+func Test_ConnectionRequestVariablePart(t *testing.T) {
+	req := ConnectionRequest{
+		CRCDT:        0xE0,                     // TPDU_CONNECTION_REQUEST
+		VariablePart: []byte{0xc0, 0x01, 0x0a}, // TPDU size 1024
+		UserData:     []byte{0x01, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+
+	expected := []byte{
+		0x11, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0, 0x01, 0x0a, 0x01, 0x00, 0x08, 0x00, 0x00, 0x00,
+		0x00, 0x00,
+	}
+
+	require.Equal(t, expected, req.Serialize())
+}
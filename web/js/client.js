@@ -47,6 +47,12 @@ Client.prototype.connect = function () {
 
     this.handleMessage = this.handleMessage.bind(this);
     this.socket.onmessage = (e) => {
+        if (typeof e.data === "string") {
+            this.handleControlMessage(JSON.parse(e.data));
+
+            return;
+        }
+
         e.data.arrayBuffer().then((arrayBuffer) => this.handleMessage(arrayBuffer))
     };
 
@@ -119,6 +125,36 @@ Client.prototype.deinitialize = function () {
     this.ctx.clearRect(0, 0, this.canvas.width, this.canvas.height);
 };
 
+Client.prototype.handleControlMessage = function (message) {
+    if (message.type === "desktopSize") {
+        this.handleDesktopSize(message);
+
+        return;
+    }
+
+    if (message.type !== "certificate") {
+        console.warn("unknown control message:", message.type);
+
+        return;
+    }
+
+    const text = (message.changed ? "WARNING: the server certificate has changed!\n\n" : "") +
+        "The server certificate of " + message.host + " is not trusted: " + message.error + "\n\n" +
+        "Subject: " + message.subject + "\n" +
+        "Issuer: " + message.issuer + "\n" +
+        "Valid until: " + message.notAfter + "\n" +
+        "SHA-256: " + message.fingerprint + "\n\n" +
+        "Accept the certificate?";
+
+    this.socket.send(JSON.stringify({accept: confirm(text)}));
+};
+
+Client.prototype.handleDesktopSize = function (message) {
+    // resizing clears the canvas, the server sends the whole desktop after the reactivation
+    this.canvas.width = message.width;
+    this.canvas.height = message.height;
+};
+
 Client.prototype.handleMessage = function (arrayBuffer) {
     if (!this.connected) {
         return;